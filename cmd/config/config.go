@@ -10,10 +10,93 @@ import (
 type ApiOptions struct {
 	Port    int  `json:"port"`
 	UseGRPC bool `json:"useGRPC"`
+	// port of the gRPC server started next to the HTTP one when useGRPC is set, 50051 by default
+	GRPCPort int `json:"grpcPort"`
+	// seconds a read may take before it's answered with 503, 30 by default, the writes are not timed out
+	RequestTimeout int        `json:"requestTimeout"`
+	TLS            TLSOptions `json:"tls"`
+}
+
+type TLSOptions struct {
+	// PEM files of the certificate and its key, the server uses https when they're set.
+	// They're reloaded when they change on disk.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// PEM file of the CA which signs the client certificates and, on the nodes, the other nodes.
+	// The system roots are used when it's empty.
+	CAFile string `json:"caFile"`
+	// rejects the clients without a certificate signed by caFile
+	RequireClientCert bool `json:"requireClientCert"`
 }
 
 type ServiceOptions struct {
 	ApiOptions *ApiOptions `json:"apiOptions"`
+	// seconds the responses of requests with an Idempotency-Key are remembered
+	IdempotencyWindow int        `json:"idempotencyWindow"`
+	JobOptions        JobOptions `json:"jobOptions"`
+	// words a wildcard or regex query may scan before it's rejected
+	MaxScanWords int `json:"maxScanWords"`
+	// bytes of the body of an upload and of each of its files, 64MB and 16MB by default
+	MaxUploadSize int64        `json:"maxUploadSize"`
+	MaxFileSize   int64        `json:"maxFileSize"`
+	DedupOptions  DedupOptions `json:"dedupOptions"`
+	// also serves the unversioned endpoints, which answer every request with HTTP 200, true when it's not set
+	LegacyAPI        *bool            `json:"legacyApi"`
+	RESPOptions      RESPOptions      `json:"respOptions"`
+	LineOptions      LineOptions      `json:"lineOptions"`
+	RateLimitOptions RateLimitOptions `json:"rateLimitOptions"`
+}
+
+// the old clients keep working with the configs written before the option existed
+func (o *ServiceOptions) LegacyAPIEnabled() bool {
+	return o.LegacyAPI == nil || *o.LegacyAPI
+}
+
+type RateLimitOptions struct {
+	// requests per second of every API key, or client ip without authentication, not limited when it's 0
+	ReadRate float64 `json:"readRate"`
+	// requests a client can send at once, the rate rounded up by default
+	ReadBurst  int     `json:"readBurst"`
+	WriteRate  float64 `json:"writeRate"`
+	WriteBurst int     `json:"writeBurst"`
+	// words a client can register per day (UTC), not limited when it's 0
+	DailyWordQuota int64 `json:"dailyWordQuota"`
+	// daily quotas of some clients by key name or ip, they replace dailyWordQuota
+	Quotas map[string]int64 `json:"quotas"`
+}
+
+type JobOptions struct {
+	// directory where the jobs are saved, they are only kept in memory when it's empty
+	DirPath   string `json:"dirPath"`
+	QueueSize int    `json:"queueSize"`
+	Workers   int    `json:"workers"`
+}
+
+type RESPOptions struct {
+	// serves a subset of the Redis commands, INCR counts a word like the registered texts
+	Enabled bool `json:"enabled"`
+	// 6379 by default
+	Port int `json:"port"`
+}
+
+type LineOptions struct {
+	// tcp port where every line sent is counted, not served when it's 0
+	Port int `json:"port"`
+	// same protocol on a unix socket, not served when it's empty
+	SocketPath string `json:"socketPath"`
+	// milliseconds between the acknowledgements, 1000 by default
+	AckInterval int `json:"ackInterval"`
+}
+
+type DedupOptions struct {
+	// compares the signature of every text with the recent ones before counting it
+	Enabled bool `json:"enabled"`
+	// similarity between 0 and 1 of the word shingles from which a text is a near duplicate, 0.8 by default
+	Threshold float64 `json:"threshold"`
+	// number of recent signatures kept
+	Window int `json:"window"`
+	// "skip" doesn't count the near duplicates, "flag" counts them and reports them
+	Action string `json:"action"`
 }
 
 type WALOptions struct {
@@ -28,20 +111,84 @@ type SnapshotOptions struct {
 	SyncTimer int    `json:"syncTimer"`
 }
 
+type NamespaceOptions struct {
+	// keep the positions of the words, needed by phrase searches
+	Positions bool `json:"positions"`
+}
+
+type IndexOptions struct {
+	Enabled    bool                        `json:"enabled"`
+	Namespaces map[string]NamespaceOptions `json:"namespaces,omitempty"`
+}
+
+type CooccurrenceOptions struct {
+	Enabled bool `json:"enabled"`
+	// "sentence" counts the words of the same sentence, "window" the words at most window-1 tokens apart
+	Mode   string `json:"mode"`
+	Window int    `json:"window"`
+}
+
+type SuggestOptions struct {
+	// keeps a prefix tree of the words for the autocomplete,
+	// every inserted word takes the lock of the tree, so it slows down the ingestion
+	Enabled bool `json:"enabled"`
+}
+
+type SimilarOptions struct {
+	// keeps a BK-tree of the words for the spelling suggestions, the new words take the lock of the tree
+	Enabled bool `json:"enabled"`
+}
+
 type NodeOptions struct {
 	Name              string      `json:"name"`
 	MasterID          string      `json:"masterID,omitempty"`
 	HeartbeatInterval int         `json:"heartbeatInterval"`
 	ApiOptions        *ApiOptions `json:"apiOptions"`
 	LeaderElection    bool        `json:"leaderElection"`
+	JoinOptions       JoinOptions `json:"joinOptions"`
+}
+
+type JoinOptions struct {
+	// shared secret of the cluster, the master registers only the workers which send it
+	// or a join token signed with it. The registration is open when it's empty.
+	Secret string `json:"secret"`
+	// file where the master keeps the secret rotated with the admin api and the revoked workers
+	StateFile string `json:"stateFile"`
+	// join token issued by the master, sent by the worker instead of the secret
+	Token string `json:"token"`
+}
+
+type AuthOptions struct {
+	// the http requests without a valid key or token are rejected
+	Enabled bool     `json:"enabled"`
+	Keys    []APIKey `json:"keys"`
+	// secret of the HS256 bearer tokens, the tokens are rejected when it's empty
+	TokenSecret string `json:"tokenSecret"`
+	// admin key sent by the nodes to each other, it must be the same on every node
+	NodeKey string `json:"nodeKey"`
+}
+
+type APIKey struct {
+	// logged with the requests rejected for the key
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// "reader", "writer" or "admin"
+	Role string `json:"role"`
+	// index namespaces the key can use, every namespace when it's empty
+	Namespaces []string `json:"namespaces,omitempty"`
 }
 
 type Config struct {
-	ServiceOptions  ServiceOptions       `json:"serviceOptions"`
-	SnapshotOptions SnapshotOptions      `json:"snapshotOptions"`
-	WALOptions      WALOptions           `json:"walOptions"`
-	NodeOptions     NodeOptions          `json:"nodeOptions"`
-	LoggerOptions   logger.LoggerOptions `json:"loggerOptions"`
+	ServiceOptions      ServiceOptions       `json:"serviceOptions"`
+	SnapshotOptions     SnapshotOptions      `json:"snapshotOptions"`
+	WALOptions          WALOptions           `json:"walOptions"`
+	IndexOptions        IndexOptions         `json:"indexOptions"`
+	CooccurrenceOptions CooccurrenceOptions  `json:"cooccurrenceOptions"`
+	SuggestOptions      SuggestOptions       `json:"suggestOptions"`
+	SimilarOptions      SimilarOptions       `json:"similarOptions"`
+	NodeOptions         NodeOptions          `json:"nodeOptions"`
+	AuthOptions         AuthOptions          `json:"authOptions"`
+	LoggerOptions       logger.LoggerOptions `json:"loggerOptions"`
 }
 
 func ReadConfig(filePath string) (*Config, error) {
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	httpclient "mem-db/pkg/api/http/client"
+	certs "mem-db/pkg/certs"
+	repo "mem-db/pkg/repository"
+	service "mem-db/pkg/service"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bytes used to decide if a file without a known extension contains text
+const sniffLen = 512
+
+// destination of the imported words
+type importSink interface {
+	Import(name, text string) (int, error)
+	Close() error
+}
+
+// writes the words into the data directory of a stopped node
+type offlineSink struct {
+	importer *repo.Importer
+}
+
+func (s *offlineSink) Import(name, text string) (int, error) {
+	words := service.Tokenize(text)
+	return len(words), s.importer.Insert(words)
+}
+
+func (s *offlineSink) Close() error {
+	return s.importer.Close()
+}
+
+// streams the text to a running node, which tokenizes it
+type httpSink struct {
+	url     string
+	timeout time.Duration
+}
+
+// the same file imported again gets the same key, so a rerun isn't counted twice
+func importIdempotencyKey(name, text string) string {
+	hash := sha256.New()
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(text))
+	return "import-" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *httpSink) Import(name, text string) (int, error) {
+	payload, err := json.Marshal(&service.TextInput{Text: text})
+	if err != nil {
+		return 0, fmt.Errorf("Cannot encode text: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set(service.IdempotencyKeyHeader, importIdempotencyKey(name, text))
+	if err := httpclient.SendRequestWithTimeout("POST", s.url, payload, headers, s.timeout); err != nil {
+		return 0, err
+	}
+	return len(service.Tokenize(text)), nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
+
+type importer struct {
+	sink    importSink
+	options *service.ExtractOptions
+	files   int
+	words   int
+}
+
+func importUsage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "Usage: mem-db import [options] <directory|archive.tar[.gz]|archive.zip>...\n\n")
+		fmt.Fprintf(flags.Output(), "Writes into the data directory from -config when -node is not set.\n\n")
+		flags.PrintDefaults()
+	}
+}
+
+// sends the requests with the CA and the client certificate of the options
+func useImportTLS(options *config.TLSOptions) error {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error"})
+	store, err := certs.NewStore(context.WithValue(context.Background(), log.LoggerKey, logger), options)
+	if err != nil {
+		return fmt.Errorf("Cannot load certificates: %v", err)
+	}
+	if store != nil {
+		httpclient.UseTLS(store)
+	}
+	return nil
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	configFilePath := flags.String("config", "cmd/config/config.json", "config file of the node, used for offline imports")
+	nodeAddress := flags.String("node", "", "address of a running node (host:port or https://host:port) to stream the text to")
+	column := flags.String("column", "", "header name or index of the csv column to import")
+	key := flags.String("key", "", "API key or token of a writer, when the node requires one")
+	caFile := flags.String("cacert", "", "CA which signs the certificate of the node, the system roots are used by default")
+	certFile := flags.String("cert", "", "client certificate, when the node requires one")
+	certKeyFile := flags.String("certkey", "", "key of the client certificate")
+	timeout := flags.Duration("timeout", 5*time.Minute, "maximum time a running node may take to count one file")
+	flags.Usage = importUsage(flags)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("No directory or archive provided")
+	}
+
+	var sink importSink
+	if *nodeAddress != "" {
+		if *key != "" {
+			httpclient.SetDefaultHeader("Authorization", "Bearer "+*key)
+		}
+		tlsOptions := &config.TLSOptions{CAFile: *caFile, CertFile: *certFile, KeyFile: *certKeyFile}
+		if err := useImportTLS(tlsOptions); err != nil {
+			return err
+		}
+
+		address := *nodeAddress
+		if !strings.Contains(address, "://") {
+			if tlsOptions.CAFile != "" || tlsOptions.CertFile != "" {
+				address = "https://" + address
+			} else {
+				address = "http://" + address
+			}
+		}
+		sink = &httpSink{url: address + "/v1/words/register", timeout: *timeout}
+	} else {
+		config, err := config.ReadConfig(*configFilePath)
+		if err != nil {
+			return fmt.Errorf("Error while trying to read application config: %v", err)
+		}
+
+		dbImporter, err := repo.NewImporter(config)
+		if err != nil {
+			return fmt.Errorf("Cannot open data directory: %v", err)
+		}
+		sink = &offlineSink{importer: dbImporter}
+	}
+
+	im := &importer{
+		sink:    sink,
+		options: &service.ExtractOptions{CSVColumn: *column},
+	}
+
+	for _, path := range flags.Args() {
+		if err := im.importPath(path); err != nil {
+			sink.Close()
+			return err
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("Cannot finish import: %v", err)
+	}
+
+	fmt.Printf("Imported %d words from %d files\n", im.words, im.files)
+	return nil
+}
+
+func (im *importer) importPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("Cannot access %s: %v", path, err)
+	}
+
+	if info.IsDir() {
+		return filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			file, err := os.Open(filePath)
+			if err != nil {
+				return fmt.Errorf("Cannot open %s: %v", filePath, err)
+			}
+			defer file.Close()
+
+			return im.importFile(filePath, file)
+		})
+	}
+
+	lowerPath := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lowerPath, ".zip"):
+		return im.importZip(path)
+	case strings.HasSuffix(lowerPath, ".tar"):
+		return im.importTarFile(path, false)
+	case strings.HasSuffix(lowerPath, ".tar.gz"), strings.HasSuffix(lowerPath, ".tgz"):
+		return im.importTarFile(path, true)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	return im.importFile(path, file)
+}
+
+func (im *importer) importZip(path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open zip archive %s: %v", path, err)
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		file, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("Cannot open %s from %s: %v", entry.Name, path, err)
+		}
+		err = im.importFile(entry.Name, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) importTarFile(path string, compressed bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open tar archive %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if compressed {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("Cannot decompress %s: %v", path, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot read tar archive %s: %v", path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := im.importFile(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// binary files are skipped
+func (im *importer) importFile(name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Cannot read %s: %v", name, err)
+	}
+
+	if !isTextFile(name, data) {
+		return nil
+	}
+
+	text, err := service.ExtractText(name, "", bytes.NewReader(data), im.options)
+	if err != nil {
+		return fmt.Errorf("Cannot extract text from %s: %v", name, err)
+	}
+
+	words, err := im.sink.Import(name, text)
+	if err != nil {
+		return fmt.Errorf("Cannot import %s: %v", name, err)
+	}
+
+	im.files++
+	im.words += words
+	fmt.Printf("%s: %d words\n", name, words)
+	return nil
+}
+
+func isTextFile(name string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text", ".md", ".markdown", ".html", ".htm", ".csv":
+		return true
+	}
+
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	return strings.HasPrefix(http.DetectContentType(data), "text/")
+}
@@ -6,6 +6,9 @@ import (
 	"golang.org/x/sync/errgroup"
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
+	httpclient "mem-db/pkg/api/http/client"
+	auth "mem-db/pkg/auth"
+	certs "mem-db/pkg/certs"
 	node "mem-db/pkg/node"
 	repo "mem-db/pkg/repository"
 	service "mem-db/pkg/service"
@@ -35,6 +38,22 @@ func main() {
 		panic("Config file path is missing!")
 	}
 
+	if os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if os.Args[1] == "token" {
+		if err := runToken(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	configFilePath := os.Args[1]
 
 	config, err := config.ReadConfig(configFilePath)
@@ -57,6 +76,28 @@ func main() {
 	}
 
 	ctx = context.WithValue(ctx, log.LoggerKey, logger)
+
+	authenticator, err := auth.NewAuthenticator(ctx, &config.AuthOptions)
+	if err != nil {
+		panic(fmt.Errorf("Error while trying to initialize authentication: %v", err.Error()))
+	}
+	if authenticator != nil {
+		ctx = context.WithValue(ctx, auth.AuthenticatorKey, authenticator)
+	}
+	// the nodes call each other with the node key
+	if config.AuthOptions.NodeKey != "" {
+		httpclient.SetDefaultHeader("Authorization", "Bearer "+config.AuthOptions.NodeKey)
+	}
+	// the nodes call each other with https and their certificate when the node api uses tls
+	if config.NodeOptions.ApiOptions != nil {
+		store, err := certs.NewStore(ctx, &config.NodeOptions.ApiOptions.TLS)
+		if err != nil {
+			panic(fmt.Errorf("Error while trying to load the node certificates: %v", err.Error()))
+		}
+		if store.HasCertificate() {
+			httpclient.UseTLS(store)
+		}
+	}
 	nodeService := node.NewNodeService(ctx, &config.NodeOptions)
 
 	dbService := repo.NewDatabase(ctx, config, nodeService.IsMaster())
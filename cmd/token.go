@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	config "mem-db/cmd/config"
+	auth "mem-db/pkg/auth"
+	"strings"
+	"time"
+)
+
+func tokenUsage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "Usage: mem-db token [options]\n\n")
+		fmt.Fprintf(flags.Output(), "Prints a bearer token signed with the tokenSecret of -config.\n\n")
+		flags.PrintDefaults()
+	}
+}
+
+func runToken(args []string) error {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	configFilePath := flags.String("config", "cmd/config/config.json", "config file of the nodes")
+	name := flags.String("name", "", "client logged with the rejected requests")
+	role := flags.String("role", "reader", "reader, writer or admin")
+	namespaces := flags.String("namespaces", "", "comma separated index namespaces, every namespace when it's empty")
+	ttl := flags.Duration("ttl", 24*time.Hour, "validity of the token, it doesn't expire when it's 0")
+	flags.Usage = tokenUsage(flags)
+	flags.Parse(args)
+
+	if *name == "" {
+		flags.Usage()
+		return fmt.Errorf("No name provided")
+	}
+
+	config, err := config.ReadConfig(*configFilePath)
+	if err != nil {
+		return fmt.Errorf("Error while trying to read application config: %v", err)
+	}
+	if config.AuthOptions.TokenSecret == "" {
+		return fmt.Errorf("No tokenSecret in %s", *configFilePath)
+	}
+
+	claims := &auth.Claims{Subject: *name, Role: *role, IssuedAt: time.Now().Unix()}
+	if *namespaces != "" {
+		claims.Namespaces = strings.Split(*namespaces, ",")
+	}
+	if *ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(*ttl).Unix()
+	}
+
+	token, err := auth.SignToken([]byte(config.AuthOptions.TokenSecret), claims)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
@@ -2,33 +2,161 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"google.golang.org/grpc"
-	"mem-db/pkg/api"
-	service "mem-db/pkg/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	certs "mem-db/pkg/certs"
 	"net"
+	"runtime/debug"
+	"time"
 )
 
+// used when the api options don't set a gRPC port
+const defaultPort = 50051
+
+// GRPCServer serves the registered services with the standard health service and the server reflection
 type GRPCServer struct {
-	server *grpc.Server
+	Server *grpc.Server
+	// the services set themselves as serving once they are registered
+	Health *health.Server
+	port   int
+	logger log.Logger
 }
 
-func NewServer(ctx context.Context) api.Server {
+func NewServer(ctx context.Context, options *config.ApiOptions) *GRPCServer {
+	logger := ctx.Value(log.LoggerKey).(log.Logger)
+
+	port := options.GRPCPort
+	if port <= 0 {
+		port = defaultPort
+	}
+
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(recoveryUnaryInterceptor(logger), accessLogUnaryInterceptor(logger)),
+		grpc.ChainStreamInterceptor(recoveryStreamInterceptor(logger), accessLogStreamInterceptor(logger)),
+	}
+
+	// same certificates and client verification as the http server of the api options
+	if options.TLS.CertFile != "" {
+		store, err := certs.NewStore(ctx, &options.TLS)
+		if err != nil {
+			panic(fmt.Errorf("Cannot load the certificates of port %d: %v", port, err))
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(serverConfig(store))))
+	}
+
+	server := grpc.NewServer(serverOptions...)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
 	return &GRPCServer{
-		server: grpc.NewServer(),
+		Server: server,
+		Health: healthServer,
+		port:   port,
+		logger: logger,
+	}
+}
+
+// the config of every handshake comes from the store, it must also negotiate http/2
+func serverConfig(store *certs.Store) *tls.Config {
+	tlsConfig := store.ServerConfig()
+	getConfigForClient := tlsConfig.GetConfigForClient
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		clientConfig, err := getConfigForClient(hello)
+		if clientConfig != nil {
+			clientConfig.NextProtos = []string{"h2"}
+		}
+		return clientConfig, err
 	}
+	return tlsConfig
 }
 
-func (s *GRPCServer) Start(ctx context.Context) error {
-	lis, err := net.Listen("tcp", ":50051")
+func (s *GRPCServer) Start() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
 	if err != nil {
-		return fmt.Errorf("Failed to listen on port 50051: %v", err)
+		return fmt.Errorf("Failed to listen on port %d: %v", s.port, err)
 	}
 
-	log.Printf("gRPC server listening at %v", lis.Addr())
-	return s.server.Serve(lis)
+	s.logger.Info("gRPC server listening on port ", s.port)
+	return s.Serve(lis)
+}
+
+// serves the connections accepted by lis, the tests use an in-memory listener
+func (s *GRPCServer) Serve(lis net.Listener) error {
+	if err := s.Server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		return fmt.Errorf("gRPC server error: %v", err)
+	}
+	return nil
 }
 
+// waits for the running calls until ctx is done, the remaining ones are cancelled
 func (s *GRPCServer) Stop(ctx context.Context) error {
-	return s.server.GracefulStop()
+	s.logger.Info("Shutting down gRPC server on port ", s.port)
+	s.Health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Server.Stop()
+	}
+	return nil
+}
+
+func accessLogUnaryInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.Info(fmt.Sprintf("%s %s %s", info.FullMethod, status.Code(err), time.Since(start)))
+		return resp, err
+	}
+}
+
+func accessLogStreamInterceptor(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		logger.Info(fmt.Sprintf("%s %s %s", info.FullMethod, status.Code(err), time.Since(start)))
+		return err
+	}
+}
+
+// answers Internal instead of crashing the node when a handler panics
+func recoveryUnaryInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error(fmt.Sprintf("Panic while serving %s: %v\n%s", info.FullMethod, r, debug.Stack()))
+				err = status.Error(codes.Internal, "Internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func recoveryStreamInterceptor(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error(fmt.Sprintf("Panic while serving %s: %v\n%s", info.FullMethod, r, debug.Stack()))
+				err = status.Error(codes.Internal, "Internal error")
+			}
+		}()
+		return handler(srv, stream)
+	}
 }
@@ -0,0 +1,100 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"math/big"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func getLoggerContext() context.Context {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	return context.WithValue(context.Background(), log.LoggerKey, logger)
+}
+
+// writes a self-signed certificate of 127.0.0.1 and returns it
+func writeCertificate(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "node"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %v", err)
+	}
+	encodedKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot encode key: %v", err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey}), 0600)
+
+	certificate, _ := x509.ParseCertificate(der)
+	return certificate
+}
+
+func checkHealth(t *testing.T, address string, creds credentials.TransportCredentials) error {
+	t.Helper()
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("Cannot create client: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "node.pem"), filepath.Join(dir, "node-key.pem")
+	certificate := writeCertificate(t, certFile, keyFile)
+
+	s := NewServer(getLoggerContext(), &config.ApiOptions{TLS: config.TLSOptions{CertFile: certFile, KeyFile: keyFile}})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go s.Serve(listener)
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	address := listener.Addr().String()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	if err := checkHealth(t, address, credentials.NewTLS(&tls.Config{RootCAs: pool})); err != nil {
+		t.Fatalf("Expected a TLS client to be served, got %v", err)
+	}
+	if err := checkHealth(t, address, insecure.NewCredentials()); err == nil {
+		t.Fatalf("Expected a plaintext client to be rejected")
+	}
+}
@@ -4,19 +4,58 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	certs "mem-db/pkg/certs"
 	"net/http"
 	"net/url"
 	"time"
 )
 
-func GetURL(address string, port int, endpoint string) string {
-	return fmt.Sprintf("http://%s:%d%s", address, port, endpoint)
+// headers sent with every request, like the key of the node
+var defaultHeaders = http.Header{}
+
+func SetDefaultHeader(key, value string) {
+	defaultHeaders.Set(key, value)
 }
 
-func ForwardRequest(ctx context.Context, originalRequest *http.Request, forwardURL string) error {
-	client := &http.Client{
-		Timeout: 2 * time.Second,
+func addDefaultHeaders(req *http.Request) {
+	for key, values := range defaultHeaders {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+}
+
+// https when the nodes use TLS
+var scheme = "http"
+
+// shared by the requests, so the connections to the other nodes are reused
+var transport http.RoundTripper = http.DefaultTransport
+
+// the requests use https, the servers are verified and the client certificate is sent
+// with the current certificates of the store
+func UseTLS(store *certs.Store) {
+	scheme = "https"
+	transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialTLSContext:      store.DialTLSContext,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
 	}
+}
+
+func newClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+var GetURL = func(address string, port int, endpoint string) string {
+	return fmt.Sprintf("%s://%s:%d%s", scheme, address, port, endpoint)
+}
+
+func ForwardRequest(ctx context.Context, originalRequest *http.Request, forwardURL string) error {
+	client := newClient(2 * time.Second)
 
 	forwardedRequest, err := http.NewRequestWithContext(ctx, originalRequest.Method, forwardURL, originalRequest.Body)
 	if err != nil {
@@ -30,6 +69,7 @@ func ForwardRequest(ctx context.Context, originalRequest *http.Request, forwardU
 		}
 	}
 
+	addDefaultHeaders(forwardedRequest)
 	resp, err := client.Do(forwardedRequest)
 	if err != nil {
 		return fmt.Errorf("Error forwarding the request: %v", err)
@@ -44,20 +84,33 @@ func ForwardRequest(ctx context.Context, originalRequest *http.Request, forwardU
 }
 
 func SendPostRequest(url string, payload []byte) error {
+	return SendRequest("POST", url, payload, nil)
+}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+func SendRequest(method, url string, payload []byte, headers http.Header) error {
+	return SendRequestWithTimeout(method, url, payload, headers, 5*time.Second)
+}
+
+// like SendRequest, for the bodies which take longer than the default timeout to process
+func SendRequestWithTimeout(method, url string, payload []byte, headers http.Header, timeout time.Duration) error {
+	client := newClient(timeout)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("Error creating request to %s: %v\n", url, err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	addDefaultHeaders(req)
+
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("Error sending POST request to %s: %v\n", url, err)
+		return fmt.Errorf("Error sending %s request to %s: %v\n", method, url, err)
 	}
 	defer resp.Body.Close()
 
@@ -69,9 +122,7 @@ func SendPostRequest(url string, payload []byte) error {
 }
 
 func SendGetRequest(ctx context.Context, baseURL string, queryParams map[string]string) (*http.Response, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+	client := newClient(5 * time.Second)
 
 	// Parse the base URL to append the query params
 	parsedURL, err := url.Parse(baseURL)
@@ -93,6 +144,7 @@ func SendGetRequest(ctx context.Context, baseURL string, queryParams map[string]
 	if err != nil {
 		return nil, fmt.Errorf("Error creating GET request: %v", err)
 	}
+	addDefaultHeaders(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
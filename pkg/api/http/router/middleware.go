@@ -0,0 +1,134 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	log "mem-db/cmd/logger"
+	util "mem-db/pkg/util"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// longer request ids sent by the clients are replaced
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// returns the id of the request set by the RequestID middleware
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// keeps the id sent by the client or generates one, the id is sent back in the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = util.NewID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// keeps the status code and the size of the response for the access log
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// lets http.ResponseController reach the flusher of the wrapped writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func AccessLogMiddleware(logger log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			writer := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(writer, r)
+
+			if writer.statusCode == 0 {
+				writer.statusCode = http.StatusOK
+			}
+			logger.Info(fmt.Sprintf("%s %s %d %dB %s request=%s",
+				r.Method, r.URL, writer.statusCode, writer.size, time.Since(start), RequestID(r.Context())))
+		})
+	}
+}
+
+// answers 500 instead of dropping the connection when a handler panics
+func RecoveryMiddleware(logger log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				// the server aborts the response on purpose
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				logger.Error(fmt.Sprintf("Panic while serving %s %s request=%s: %v\n%s",
+					r.Method, r.URL, RequestID(r.Context()), err, debug.Stack()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// answers 503 when a read takes longer than timeout, the context of the request is cancelled.
+// The writes are not timed out: they would keep running after the 503 and be applied again when retried.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		timed := http.TimeoutHandler(next, timeout, "Request timed out")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				timed.ServeHTTP(w, r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allows the requests from any origin
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Authorization, Origin, Idempotency-Key, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+
+		next.ServeHTTP(w, r)
+	})
+}
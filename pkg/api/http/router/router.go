@@ -2,50 +2,208 @@ package router
 
 import (
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 )
 
+// Middleware wraps a handler, the first middleware added is the outermost one
+type Middleware func(next http.Handler) http.Handler
+
+// Router matches the path segments of the requests, {name} segments match any
+// non-empty segment and are available with r.PathValue(name).
+// Literal segments have priority over the parameters.
 type Router struct {
-	routes map[string]map[string]http.HandlerFunc
+	root        *node
+	middlewares []Middleware
+	// dispatch wrapped by the middlewares
+	handler http.Handler
+	// writes the 404 and 405 responses
+	errorHandler func(w http.ResponseWriter, req *http.Request, statusCode int)
+}
+
+type node struct {
+	children map[string]*node
+	param    *node
+	// name of the parameter matched by this node
+	name     string
+	handlers map[string]http.Handler
+}
+
+// Group adds routes under a common prefix, with their own middlewares
+type Group struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
 }
 
 func NewRouter() *Router {
 	router := &Router{
-		routes: make(map[string]map[string]http.HandlerFunc),
+		root:         &node{},
+		errorHandler: writeError,
 	}
+	router.handler = http.HandlerFunc(router.dispatch)
 
 	return router
 }
 
-// add CORS headers to the response
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET")
-		w.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Authorization, Origin, application/json")
+// adds middlewares to every request, including the ones without a route.
+// It must be called before the router serves requests.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.handler = chain(http.HandlerFunc(r.dispatch), r.middlewares)
+}
+
+// replaces the plain text bodies of the 404 and 405 responses
+func (r *Router) SetErrorHandler(errorHandler func(w http.ResponseWriter, req *http.Request, statusCode int)) {
+	r.errorHandler = errorHandler
+}
+
+// writes an error with the error handler of the router, so the middlewares answer
+// like the routes do
+func (r *Router) WriteError(w http.ResponseWriter, req *http.Request, statusCode int) {
+	r.errorHandler(w, req, statusCode)
+}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
+func writeError(w http.ResponseWriter, req *http.Request, statusCode int) {
+	http.Error(w, http.StatusText(statusCode), statusCode)
+}
+
+func (r *Router) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{router: r, prefix: strings.TrimSuffix(prefix, "/"), middlewares: middlewares}
 }
 
 func (r *Router) AddRoute(method, path string, handlerFunc http.HandlerFunc) {
-	if r.routes[path] == nil {
-		r.routes[path] = make(map[string]http.HandlerFunc)
+	r.Handle(method, path, handlerFunc)
+}
+
+func (r *Router) Handle(method, path string, handler http.Handler) {
+	n := r.root
+	for _, segment := range splitPath(path) {
+		n = n.child(segment)
 	}
-	r.routes[path][method] = handlerFunc
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.Handler)
+	}
+	n.handlers[method] = handler
+}
+
+// the group middlewares only wrap the routes of the group
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		router:      g.router,
+		prefix:      g.prefix + strings.TrimSuffix(prefix, "/"),
+		middlewares: append(append([]Middleware(nil), g.middlewares...), middlewares...),
+	}
+}
+
+func (g *Group) AddRoute(method, path string, handlerFunc http.HandlerFunc) {
+	g.router.Handle(method, g.prefix+path, chain(handlerFunc, g.middlewares))
+}
+
+func chain(handler http.Handler, middlewares []Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func (n *node) child(segment string) *node {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		name := segment[1 : len(segment)-1]
+		if n.param == nil {
+			n.param = &node{name: name}
+		}
+		if n.param.name != name {
+			panic("router: parameter {" + name + "} conflicts with {" + n.param.name + "}")
+		}
+		return n.param
+	}
+
+	if n.children == nil {
+		n.children = make(map[string]*node)
+	}
+	child := n.children[segment]
+	if child == nil {
+		child = &node{}
+		n.children[segment] = child
+	}
+	return child
+}
+
+// returns the node with handlers matching the segments and the values of the parameters
+func (n *node) match(segments []string, params []string) (*node, []string) {
+	if len(segments) == 0 {
+		if n.handlers == nil {
+			return nil, nil
+		}
+		return n, params
+	}
+
+	if child := n.children[segments[0]]; child != nil {
+		if found, values := child.match(segments[1:], params); found != nil {
+			return found, values
+		}
+	}
+	if n.param != nil && segments[0] != "" {
+		return n.param.match(segments[1:], append(params, n.param.name, segments[0]))
+	}
+	return nil, nil
+}
+
+func (n *node) allowed() string {
+	methods := make([]string, 0, len(n.handlers)+1)
+	for method := range n.handlers {
+		methods = append(methods, method)
+	}
+	if _, found := n.handlers[http.MethodOptions]; !found {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if handlers, ok := r.routes[req.URL.Path]; ok {
-			if handlerFunc, methodExists := handlers[req.Method]; methodExists {
-				handlerFunc(w, req)
-				return
-			}
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) {
+	// the escaped path keeps the encoded slashes inside the segments
+	segments := splitPath(req.URL.EscapedPath())
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = unescaped
 		}
-		http.NotFound(w, req)
-	}))
+	}
 
-	handler.ServeHTTP(w, req)
+	n, params := r.root.match(segments, nil)
+	if n == nil {
+		r.errorHandler(w, req, http.StatusNotFound)
+		return
+	}
+	for i := 0; i < len(params); i += 2 {
+		req.SetPathValue(params[i], params[i+1])
+	}
+
+	if handler, found := n.handlers[req.Method]; found {
+		handler.ServeHTTP(w, req)
+		return
+	}
+
+	w.Header().Set("Allow", n.allowed())
+	if req.Method == http.MethodOptions {
+		// CORS preflight
+		w.Header().Set("Access-Control-Allow-Methods", n.allowed())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	r.errorHandler(w, req, http.StatusMethodNotAllowed)
 }
@@ -0,0 +1,150 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Debug(v ...interface{}) {}
+func (l *testLogger) Info(v ...interface{})  { l.lines = append(l.lines, v[0].(string)) }
+func (l *testLogger) Warn(v ...interface{})  {}
+func (l *testLogger) Error(v ...interface{}) { l.lines = append(l.lines, v[0].(string)) }
+
+func writeValue(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + ":" + r.PathValue("id")))
+	}
+}
+
+func serve(r http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestRouterPathParams(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute("GET", "/documents/{id}", writeValue("get"))
+	r.AddRoute("DELETE", "/documents/{id}", writeValue("delete"))
+	r.AddRoute("GET", "/documents/latest", writeValue("latest"))
+	r.AddRoute("GET", "/synonyms/{id}", writeValue("synonym"))
+
+	tests := []struct {
+		method, path string
+		statusCode   int
+		body         string
+	}{
+		{"GET", "/documents/42", http.StatusOK, "get:42"},
+		{"DELETE", "/documents/42", http.StatusOK, "delete:42"},
+		{"GET", "/documents/latest", http.StatusOK, "latest:"},
+		{"GET", "/synonyms/new%20york", http.StatusOK, "synonym:new york"},
+		{"GET", "/synonyms/a%2Fb", http.StatusOK, "synonym:a/b"},
+		{"GET", "/documents/", http.StatusNotFound, ""},
+		{"GET", "/documents/42/words", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := serve(r, test.method, test.path)
+		if w.Code != test.statusCode {
+			t.Fatalf("%s %s: expected %d, got %d", test.method, test.path, test.statusCode, w.Code)
+		}
+		if test.statusCode == http.StatusOK && w.Body.String() != test.body {
+			t.Fatalf("%s %s: expected %q, got %q", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute("GET", "/documents/{id}", writeValue("get"))
+	r.AddRoute("DELETE", "/documents/{id}", writeValue("delete"))
+
+	w := serve(r, "POST", "/documents/42")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "DELETE, GET, OPTIONS" {
+		t.Fatalf("Expected 405 with Allow header, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = serve(r, "OPTIONS", "/documents/42")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "DELETE, GET, OPTIONS" {
+		t.Fatalf("Expected preflight response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRouterGroupsAndMiddlewares(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	r := NewRouter()
+	r.Use(trace("router"))
+	v1 := r.Group("/v1", trace("v1"))
+	v1.Group("/jobs/", trace("jobs")).AddRoute("GET", "/{id}", writeValue("job"))
+
+	w := serve(r, "GET", "/v1/jobs/7")
+	if w.Body.String() != "job:7" || strings.Join(order, ",") != "router,v1,jobs" {
+		t.Fatalf("Unexpected response %q with middlewares %v", w.Body.String(), order)
+	}
+
+	// the router middlewares also run for the requests without a route
+	order = nil
+	if w := serve(r, "GET", "/jobs/7"); w.Code != http.StatusNotFound || strings.Join(order, ",") != "router" {
+		t.Fatalf("Expected 404 through the router middlewares, got %d %v", w.Code, order)
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	logger := &testLogger{}
+	r := NewRouter()
+	r.Use(RequestIDMiddleware, AccessLogMiddleware(logger), RecoveryMiddleware(logger), TimeoutMiddleware(50*time.Millisecond))
+	r.AddRoute("GET", "/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("broken handler")
+	})
+	r.AddRoute("GET", "/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	r.AddRoute("POST", "/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("applied"))
+	})
+	r.AddRoute("GET", "/id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RequestID(r.Context())))
+	})
+
+	if w := serve(r, "GET", "/panic"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 after a panic, got %d", w.Code)
+	}
+	if w := serve(r, "GET", "/slow"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 after the timeout, got %d", w.Code)
+	}
+	if w := serve(r, "POST", "/slow"); w.Code != http.StatusOK || w.Body.String() != "applied" {
+		t.Fatalf("Expected the write to be answered after the timeout, got %d %q", w.Code, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/id", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "abc" || w.Header().Get(RequestIDHeader) != "abc" {
+		t.Fatalf("Expected the request id of the client, got %q", w.Body.String())
+	}
+	if w := serve(r, "GET", "/id"); w.Body.String() == "" || w.Header().Get(RequestIDHeader) != w.Body.String() {
+		t.Fatalf("Expected a generated request id, got %q", w.Body.String())
+	}
+
+	last := logger.lines[len(logger.lines)-1]
+	if !strings.HasPrefix(last, "GET /id 200") {
+		t.Fatalf("Unexpected access log %q", last)
+	}
+}
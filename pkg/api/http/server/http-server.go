@@ -4,28 +4,66 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	// api "mem-db/pkg/api"
 	router "mem-db/pkg/api/http/router"
+	auth "mem-db/pkg/auth"
+	certs "mem-db/pkg/certs"
 	"net/http"
+	"time"
 )
 
+// used when the api options don't set a request timeout
+const defaultRequestTimeout = 30 * time.Second
+
 type HTTPServer struct {
 	Server *http.Server
 	Router *router.Router
-	logger log.Logger
+	// nil when the authentication is disabled, the routes use it to require a role
+	Auth *auth.Authenticator
+	// nil when the server uses plain http
+	Certificates *certs.Store
+	logger       log.Logger
 }
 
-func NewServer(ctx context.Context, port int) *HTTPServer { // api.Server {
+func NewServer(ctx context.Context, options *config.ApiOptions) *HTTPServer { // api.Server {
+	logger := ctx.Value(log.LoggerKey).(log.Logger)
+
+	timeout := time.Duration(options.RequestTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	authenticator, _ := ctx.Value(auth.AuthenticatorKey).(*auth.Authenticator)
+
 	r := router.NewRouter()
+	r.Use(
+		router.RequestIDMiddleware,
+		router.AccessLogMiddleware(logger),
+		router.RecoveryMiddleware(logger),
+		router.TimeoutMiddleware(timeout),
+		router.CORSMiddleware,
+		authenticator.Middleware(r.WriteError),
+	)
 
 	server := &HTTPServer{
 		Server: &http.Server{
-			Addr:    fmt.Sprintf(":%d", port),
+			Addr:    fmt.Sprintf(":%d", options.Port),
 			Handler: r,
 		},
 		Router: r,
-		logger: ctx.Value(log.LoggerKey).(log.Logger),
+		Auth:   authenticator,
+		logger: logger,
+	}
+
+	if options.TLS.CertFile != "" {
+		store, err := certs.NewStore(ctx, &options.TLS)
+		if err != nil {
+			panic(fmt.Errorf("Cannot load the certificates of port %d: %v", options.Port, err))
+		}
+		server.Certificates = store
+		server.Server.TLSConfig = store.ServerConfig()
 	}
 
 	return server
@@ -33,8 +71,16 @@ func NewServer(ctx context.Context, port int) *HTTPServer { // api.Server {
 
 func (s *HTTPServer) Start() error {
 
-	s.logger.Info("Http server listening on port ", s.Server.Addr)
-	if err := s.Server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	var err error
+	if s.Certificates != nil {
+		s.logger.Info("Https server listening on port ", s.Server.Addr)
+		// the certificates come from the tls config
+		err = s.Server.ListenAndServeTLS("", "")
+	} else {
+		s.logger.Info("Http server listening on port ", s.Server.Addr)
+		err = s.Server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("HTTP server error: %v", err)
 	}
 	return nil
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	httpclient "mem-db/pkg/api/http/client"
+	certs "mem-db/pkg/certs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type authority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create CA: %v", err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	return &authority{certificate: certificate, key: key}
+}
+
+func (a *authority) writeCA(t *testing.T, path string) {
+	t.Helper()
+	writePEM(t, path, "CERTIFICATE", a.certificate.Raw)
+}
+
+// writes a certificate of 127.0.0.1 which can be used by the servers and the clients, like the ones of the nodes
+func (a *authority) writeCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %v", err)
+	}
+	encodedKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot encode key: %v", err)
+	}
+	writePEM(t, keyFile, "EC PRIVATE KEY", encodedKey)
+	writePEM(t, certFile, "CERTIFICATE", der)
+
+	// the files of a rotation must look changed even within the resolution of the file system
+	modTime := time.Now().Add(time.Duration(serial) * time.Second)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %v", path, err)
+	}
+}
+
+func getLoggerContext() context.Context {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	return context.WithValue(context.Background(), log.LoggerKey, logger)
+}
+
+// starts a server with the tls options on a random port
+func startTLSServer(t *testing.T, options *config.TLSOptions) string {
+	t.Helper()
+	s := NewServer(getLoggerContext(), &config.ApiOptions{TLS: *options})
+	s.Router.AddRoute("GET", "/worker/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	// every request gets a new handshake
+	s.Server.SetKeepAlivesEnabled(false)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go s.Server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { s.Server.Close() })
+
+	return listener.Addr().String()
+}
+
+func heartbeat(t *testing.T, address string) (*http.Response, error) {
+	t.Helper()
+	resp, err := httpclient.SendGetRequest(context.Background(), "https://"+address+"/worker/heartbeat", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca := newAuthority(t, "cluster")
+	ca.writeCA(t, path("ca.pem"))
+	ca.writeCertificate(t, path("node.pem"), path("node-key.pem"), 2)
+
+	intruder := newAuthority(t, "intruder")
+	intruder.writeCA(t, path("intruder-ca.pem"))
+	intruder.writeCertificate(t, path("intruder.pem"), path("intruder-key.pem"), 2)
+
+	options := &config.TLSOptions{CertFile: path("node.pem"), KeyFile: path("node-key.pem"), CAFile: path("ca.pem"), RequireClientCert: true}
+	address := startTLSServer(t, options)
+
+	// the client certificates can't be required without a CA to verify them
+	if _, err := certs.NewStore(getLoggerContext(), &config.TLSOptions{CertFile: path("node.pem"), KeyFile: path("node-key.pem"), RequireClientCert: true}); err == nil {
+		t.Fatalf("Expected requireClientCert without caFile to be rejected")
+	}
+
+	// the nodes verify each other with the same CA
+	store, err := certs.NewStore(getLoggerContext(), options)
+	if err != nil {
+		t.Fatalf("Cannot load certificates: %v", err)
+	}
+	httpclient.UseTLS(store)
+	if resp, err := heartbeat(t, address); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the node to be accepted, got %v %v", resp, err)
+	}
+	if url := httpclient.GetURL("node2", 8081, "/worker/heartbeat"); url != "https://node2:8081/worker/heartbeat" {
+		t.Fatalf("Expected an https url, got %s", url)
+	}
+
+	// a client which trusts the server but has no certificate
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.certificate)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if resp, err := anonymous.Get("https://" + address + "/worker/heartbeat"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected a client without certificate to be rejected")
+	}
+
+	// a client with a certificate of another CA
+	intruderStore, err := certs.NewStore(getLoggerContext(), &config.TLSOptions{CertFile: path("intruder.pem"), KeyFile: path("intruder-key.pem"), CAFile: path("ca.pem")})
+	if err != nil {
+		t.Fatalf("Cannot load certificates: %v", err)
+	}
+	httpclient.UseTLS(intruderStore)
+	if _, err := heartbeat(t, address); err == nil {
+		t.Fatalf("Expected a certificate of another CA to be rejected")
+	}
+
+	// a server with a certificate of another CA
+	httpclient.UseTLS(store)
+	impostor := startTLSServer(t, &config.TLSOptions{CertFile: path("intruder.pem"), KeyFile: path("intruder-key.pem"), CAFile: path("ca.pem")})
+	if _, err := heartbeat(t, impostor); err == nil {
+		t.Fatalf("Expected a server with a certificate of another CA to be rejected")
+	}
+}
+
+func TestCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca := newAuthority(t, "cluster")
+	ca.writeCA(t, path("ca.pem"))
+	ca.writeCertificate(t, path("node.pem"), path("node-key.pem"), 2)
+
+	options := &config.TLSOptions{CertFile: path("node.pem"), KeyFile: path("node-key.pem"), CAFile: path("ca.pem"), RequireClientCert: true}
+	address := startTLSServer(t, options)
+	store, err := certs.NewStore(getLoggerContext(), options)
+	if err != nil {
+		t.Fatalf("Cannot load certificates: %v", err)
+	}
+	httpclient.UseTLS(store)
+
+	serial := func() int64 {
+		t.Helper()
+		resp, err := heartbeat(t, address)
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 2 {
+		t.Fatalf("Expected the certificate 2, got %d", got)
+	}
+
+	ca.writeCertificate(t, path("node.pem"), path("node-key.pem"), 3)
+	time.Sleep(1100 * time.Millisecond)
+	if got := serial(); got != 3 {
+		t.Fatalf("Expected the rotated certificate 3, got %d", got)
+	}
+
+	// a broken certificate is not used, the previous one is kept
+	os.WriteFile(path("node.pem"), []byte("not a certificate"), 0600)
+	modTime := time.Now().Add(time.Minute)
+	os.Chtimes(path("node.pem"), modTime, modTime)
+	time.Sleep(1100 * time.Millisecond)
+	if got := serial(); got != 3 {
+		t.Fatalf("Expected the certificate 3 to be kept, got %d", got)
+	}
+}
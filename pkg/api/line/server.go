@@ -0,0 +1,266 @@
+package line
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAckInterval = time.Second
+	maxLineLength      = 1 << 20
+	// a closed connection waits this long for its last lines to be committed
+	maxDrain = time.Minute
+)
+
+// Ingester counts the lines read by the server
+type Ingester interface {
+	// accepts a connection of the producer at addr, the connection is closed after the error
+	Accept(addr net.Addr) error
+	// counts a line of the producer at addr and returns the WAL position from which its words are durable
+	Ingest(addr net.Addr, line string) (int64, error)
+	// WAL position synced to disk
+	Committed() int64
+}
+
+// LineServer counts every line sent on a tcp port or a unix socket. Every interval the
+// connections get "ACK <lines> <position>" once their first lines are committed to the WAL,
+// the lines which can't be counted get "ERR <line> <message>", a rejected connection "ERR 0 <message>".
+type LineServer struct {
+	port        int
+	socketPath  string
+	ackInterval time.Duration
+	ingester    Ingester
+	logger      log.Logger
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+func NewServer(ctx context.Context, options *config.LineOptions, ingester Ingester) *LineServer {
+	ackInterval := time.Duration(options.AckInterval) * time.Millisecond
+	if ackInterval <= 0 {
+		ackInterval = defaultAckInterval
+	}
+
+	return &LineServer{
+		port:        options.Port,
+		socketPath:  options.SocketPath,
+		ackInterval: ackInterval,
+		ingester:    ingester,
+		logger:      ctx.Value(log.LoggerKey).(log.Logger),
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *LineServer) Start() error {
+	var servers errgroup.Group
+
+	if s.port > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+		if err != nil {
+			return fmt.Errorf("Failed to listen on port %d: %v", s.port, err)
+		}
+		s.logger.Info("Line server listening on port ", s.port)
+		servers.Go(func() error { return s.Serve(lis) })
+	}
+
+	if s.socketPath != "" {
+		// the socket of a previous run is left behind when the node is killed
+		if info, err := os.Stat(s.socketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(s.socketPath)
+		}
+		lis, err := net.Listen("unix", s.socketPath)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on socket %s: %v", s.socketPath, err)
+		}
+		s.logger.Info("Line server listening on socket ", s.socketPath)
+		servers.Go(func() error { return s.Serve(lis) })
+	}
+
+	return servers.Wait()
+}
+
+// serves the connections accepted by lis until the server is stopped
+func (s *LineServer) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		lis.Close()
+		return nil
+	}
+	s.listeners = append(s.listeners, lis)
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("Line server error: %v", err)
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// closes the listeners and every connection, the lines not acknowledged yet are still counted
+func (s *LineServer) Stop(ctx context.Context) error {
+	s.logger.Info("Shutting down line server")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, lis := range s.listeners {
+		lis.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// line number and WAL position of a line which is not acknowledged yet
+type pendingLine struct {
+	line     int64
+	position int64
+}
+
+type lineConn struct {
+	conn        net.Conn
+	ackInterval time.Duration
+
+	mu      sync.Mutex
+	pending []pendingLine
+	acked   int64
+	// set once a write timed out, the producer doesn't read the acknowledgements
+	mute bool
+}
+
+func (s *LineServer) serveConn(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	c := &lineConn{conn: conn, ackInterval: s.ackInterval}
+	if err := s.ingester.Accept(conn.RemoteAddr()); err != nil {
+		c.write(fmt.Sprintf("ERR 0 %v", err))
+		return
+	}
+
+	done := make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		s.readLines(c)
+	}(done)
+
+	ticker := time.NewTicker(s.ackInterval)
+	defer ticker.Stop()
+
+	var drain <-chan time.Time
+	for {
+		select {
+		case <-ticker.C:
+			if c.ack(s.ingester.Committed()) && drain != nil {
+				return
+			}
+		case <-done:
+			done = nil
+			if c.ack(s.ingester.Committed()) {
+				return
+			}
+			drain = time.After(maxDrain)
+		case <-drain:
+			s.logger.Warn("Closing line connection before its last lines were committed")
+			return
+		}
+	}
+}
+
+func (s *LineServer) readLines(c *lineConn) {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	addr := c.conn.RemoteAddr()
+	var read int64
+	for scanner.Scan() {
+		read++
+		position, err := s.ingester.Ingest(addr, scanner.Text())
+		if err != nil {
+			c.write(fmt.Sprintf("ERR %d %v", read, err))
+		}
+		c.add(pendingLine{line: read, position: position})
+	}
+
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		c.write(fmt.Sprintf("ERR %d Line is longer than %d bytes", read+1, maxLineLength))
+	} else if err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Error("Cannot read line: ", err)
+	}
+}
+
+func (c *lineConn) add(line pendingLine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, line)
+}
+
+// acknowledges the lines committed since the last acknowledgement, returns true when
+// every line read so far is acknowledged
+func (c *lineConn) ack(committed int64) bool {
+	c.mu.Lock()
+	acked := c.acked
+	// the lines are acknowledged in order, a line waits for the ones before it
+	for len(c.pending) > 0 && c.pending[0].position <= committed {
+		acked = c.pending[0].line
+		c.pending = c.pending[1:]
+	}
+	advanced := acked != c.acked
+	c.acked = acked
+	all := len(c.pending) == 0
+	c.mu.Unlock()
+
+	if advanced {
+		c.write(fmt.Sprintf("ACK %d %d", acked, committed))
+	}
+	return all
+}
+
+// the replies are dropped once the producer stops reading them, the lines are still counted
+func (c *lineConn) write(message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mute {
+		return
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.ackInterval))
+	if _, err := c.conn.Write([]byte(message + "\n")); err != nil {
+		c.mute = true
+	}
+}
@@ -0,0 +1,142 @@
+package line
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// every line moves the position by its length, the committed position is set by the test
+type fakeIngester struct {
+	mu        sync.Mutex
+	lines     []string
+	written   int64
+	committed int64
+	// every connection is rejected when it's set
+	reject error
+}
+
+func (f *fakeIngester) Accept(addr net.Addr) error {
+	return f.reject
+}
+
+func (f *fakeIngester) Ingest(addr net.Addr, line string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if line == "fail" {
+		return 0, errors.New("Cannot count line")
+	}
+	f.lines = append(f.lines, line)
+	f.written += int64(len(line))
+	return f.written, nil
+}
+
+func (f *fakeIngester) Committed() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.committed
+}
+
+func (f *fakeIngester) commit() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = f.written
+}
+
+func getLoggerContext() context.Context {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	return context.WithValue(context.Background(), log.LoggerKey, logger)
+}
+
+func startServer(t *testing.T, ingester Ingester) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "line.sock")
+	server := NewServer(getLoggerContext(), &config.LineOptions{SocketPath: socketPath, AckInterval: 10}, ingester)
+
+	started := make(chan error, 1)
+	go func() { started <- server.Start() }()
+	t.Cleanup(func() { server.Stop(context.Background()) })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return socketPath
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Line server didn't start: %v", <-started)
+	return ""
+}
+
+func readReply(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	reply, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Cannot read reply: %v", err)
+	}
+	return strings.TrimSuffix(reply, "\n")
+}
+
+func TestAcknowledgeCommittedLines(t *testing.T) {
+	ingester := &fakeIngester{}
+	conn, err := net.Dial("unix", startServer(t, ingester))
+	if err != nil {
+		t.Fatalf("Cannot connect: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte("apple banana\nfail\ncherry\n"))
+	if reply := readReply(t, r); reply != "ERR 2 Cannot count line" {
+		t.Fatalf("Expected an error for the second line, got %q", reply)
+	}
+
+	// nothing is acknowledged before the lines are committed
+	time.Sleep(50 * time.Millisecond)
+	ingester.commit()
+	if reply := readReply(t, r); reply != "ACK 3 18" {
+		t.Fatalf("Expected the 3 lines to be acknowledged, got %q", reply)
+	}
+
+	// the last lines are acknowledged before the connection is closed
+	conn.Write([]byte("date\n"))
+	conn.(*net.UnixConn).CloseWrite()
+	time.Sleep(50 * time.Millisecond)
+	ingester.commit()
+	if reply := readReply(t, r); reply != "ACK 4 22" {
+		t.Fatalf("Expected the last line to be acknowledged, got %q", reply)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("Expected the connection to be closed")
+	}
+
+	if len(ingester.lines) != 3 {
+		t.Fatalf("Expected 3 lines to be counted, got %v", ingester.lines)
+	}
+}
+
+func TestRejectConnection(t *testing.T) {
+	ingester := &fakeIngester{reject: errors.New("Too many requests, retry later")}
+	conn, err := net.Dial("unix", startServer(t, ingester))
+	if err != nil {
+		t.Fatalf("Cannot connect: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if reply := readReply(t, r); reply != "ERR 0 Too many requests, retry later" {
+		t.Fatalf("Expected the connection to be rejected, got %q", reply)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("Expected the connection to be closed")
+	}
+}
@@ -0,0 +1,143 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// limits of the commands sent by the clients
+	maxArgs       = 1 << 16
+	maxBulkLength = 1 << 20
+)
+
+var ErrProtocol = errors.New("Protocol error")
+
+// reads a command sent as an array of bulk strings, or as an inline command
+// like the ones typed in telnet
+func ReadCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	}
+
+	args := make([]string, 0, min(count, 16))
+	for i := 0; i < count; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got '%.1s'", ErrProtocol, line)
+		}
+
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+		}
+
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if data[length] != '\r' || data[length+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", ErrProtocol)
+		}
+		args = append(args, string(data[:length]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		part, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, part...)
+		if len(line) > maxBulkLength {
+			return "", fmt.Errorf("%w: too big inline request", ErrProtocol)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// Writer encodes the replies, the RESP3 types are only used once the client sent HELLO 3
+type Writer struct {
+	w *bufio.Writer
+	// 2 or 3
+	Protocol int
+	// address of the client of the connection, nil when it's unknown
+	RemoteAddr net.Addr
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), Protocol: 2}
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) WriteSimple(value string) {
+	w.w.WriteString("+" + value + "\r\n")
+}
+
+// the message starts with the error code, like "ERR unknown command"
+func (w *Writer) WriteError(message string) {
+	w.w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(message) + "\r\n")
+}
+
+func (w *Writer) WriteInt(value int64) {
+	w.w.WriteString(":" + strconv.FormatInt(value, 10) + "\r\n")
+}
+
+func (w *Writer) WriteBulk(value string) {
+	w.w.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
+
+func (w *Writer) WriteNull() {
+	if w.Protocol == 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+// must be followed by length values
+func (w *Writer) WriteArray(length int) {
+	w.w.WriteString("*" + strconv.Itoa(length) + "\r\n")
+}
+
+// must be followed by length keys and values, RESP2 clients get a flat array
+func (w *Writer) WriteMap(length int) {
+	if w.Protocol == 3 {
+		w.w.WriteString("%" + strconv.Itoa(length) + "\r\n")
+		return
+	}
+	w.WriteArray(2 * length)
+}
+
+func (w *Writer) WriteBulks(values []string) {
+	w.WriteArray(len(values))
+	for _, value := range values {
+		w.WriteBulk(value)
+	}
+}
@@ -0,0 +1,67 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	input := "*2\r\n$4\r\nINCR\r\n$5\r\napple\r\n" +
+		"GET  apple\r\n" +
+		"*3\r\n$4\r\nMGET\r\n$0\r\n\r\n$8\r\nwith\r\nlf\r\n"
+	r := bufio.NewReader(strings.NewReader(input))
+
+	expected := [][]string{
+		{"INCR", "apple"},
+		{"GET", "apple"},
+		{"MGET", "", "with\r\nlf"},
+	}
+	for _, want := range expected {
+		args, err := ReadCommand(r)
+		if err != nil {
+			t.Fatalf("Cannot read command: %v", err)
+		}
+		if !reflect.DeepEqual(args, want) {
+			t.Fatalf("Expected %q, got %q", want, args)
+		}
+	}
+}
+
+func TestReadCommandProtocolErrors(t *testing.T) {
+	inputs := []string{
+		"*x\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$3\r\nabcd\r\n",
+		"*1\r\n$2000000\r\n",
+	}
+	for _, input := range inputs {
+		if _, err := ReadCommand(bufio.NewReader(strings.NewReader(input))); !errors.Is(err, ErrProtocol) {
+			t.Fatalf("Expected a protocol error for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestWriterProtocols(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+
+	w.WriteNull()
+	w.WriteMap(1)
+	w.WriteBulk("a")
+	w.WriteInt(1)
+	w.Protocol = 3
+	w.WriteNull()
+	w.WriteMap(1)
+	w.WriteError("ERR bad\r\ninput")
+	w.Flush()
+
+	expected := "$-1\r\n*2\r\n$1\r\na\r\n:1\r\n_\r\n%1\r\n-ERR bad  input\r\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,249 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// used when the options don't set a port
+	defaultPort = 6379
+	// reported as the Redis version, the clients enable their features from it
+	Version = "7.0.0"
+)
+
+// Handler answers a command, args[0] is the name of the command
+type Handler func(w *Writer, args []string)
+
+type command struct {
+	handler Handler
+	// number of arguments with the name, a negative arity is a minimum
+	arity int
+}
+
+// RESPServer answers the commands of the Redis clients, the connection commands like
+// PING, HELLO or QUIT are answered by the server itself
+type RESPServer struct {
+	port     int
+	logger   log.Logger
+	commands map[string]command
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+
+	Clients   atomic.Int64
+	Processed atomic.Int64
+}
+
+func NewServer(ctx context.Context, options *config.RESPOptions) *RESPServer {
+	port := options.Port
+	if port <= 0 {
+		port = defaultPort
+	}
+
+	s := &RESPServer{
+		port:     port,
+		logger:   ctx.Value(log.LoggerKey).(log.Logger),
+		commands: make(map[string]command),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.Handle("PING", -1, s.ping)
+	s.Handle("ECHO", 2, func(w *Writer, args []string) { w.WriteBulk(args[1]) })
+	s.Handle("HELLO", -1, s.hello)
+	s.Handle("SELECT", 2, s.selectDB)
+	s.Handle("CLIENT", -2, func(w *Writer, args []string) { w.WriteSimple("OK") })
+	s.Handle("COMMAND", -1, func(w *Writer, args []string) { w.WriteArray(0) })
+	return s
+}
+
+// registers the handler of a command, the names are case insensitive
+func (s *RESPServer) Handle(name string, arity int, handler Handler) {
+	s.commands[strings.ToUpper(name)] = command{handler: handler, arity: arity}
+}
+
+func (s *RESPServer) Start() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return fmt.Errorf("Failed to listen on port %d: %v", s.port, err)
+	}
+
+	s.logger.Info("RESP server listening on port ", s.port)
+	return s.Serve(lis)
+}
+
+// serves the connections accepted by lis until the server is stopped
+func (s *RESPServer) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		lis.Close()
+		return nil
+	}
+	s.listener = lis
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("RESP server error: %v", err)
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// closes the listener and every connection, the commands being run are finished
+func (s *RESPServer) Stop(ctx context.Context) error {
+	s.logger.Info("Shutting down RESP server on port ", s.port)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (s *RESPServer) serveConn(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	s.Clients.Add(1)
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.Clients.Add(-1)
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := NewWriter(conn)
+	w.RemoteAddr = conn.RemoteAddr()
+	for {
+		args, err := ReadCommand(r)
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				w.WriteError("ERR " + err.Error())
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Cannot read RESP command: ", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(args[0])
+		if name == "QUIT" {
+			w.WriteSimple("OK")
+			w.Flush()
+			return
+		}
+		s.run(w, name, args)
+
+		// the replies of pipelined commands are sent together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// answers an error instead of closing every connection when a handler panics
+func (s *RESPServer) run(w *Writer, name string, args []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(fmt.Sprintf("Panic while running %s: %v\n%s", name, r, debug.Stack()))
+			w.WriteError("ERR internal error")
+		}
+	}()
+
+	s.Processed.Add(1)
+	cmd, found := s.commands[name]
+	if !found {
+		w.WriteError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+	cmd.handler(w, args)
+}
+
+func (s *RESPServer) ping(w *Writer, args []string) {
+	switch len(args) {
+	case 1:
+		w.WriteSimple("PONG")
+	case 2:
+		w.WriteBulk(args[1])
+	default:
+		w.WriteError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// HELLO [protover [AUTH username password] [SETNAME name]], the options are ignored
+func (s *RESPServer) hello(w *Writer, args []string) {
+	if len(args) > 1 {
+		protocol, err := strconv.Atoi(args[1])
+		if err != nil || protocol < 2 || protocol > 3 {
+			w.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		w.Protocol = protocol
+	}
+
+	w.WriteMap(6)
+	w.WriteBulk("server")
+	w.WriteBulk("mem-db")
+	w.WriteBulk("version")
+	w.WriteBulk(Version)
+	w.WriteBulk("proto")
+	w.WriteInt(int64(w.Protocol))
+	w.WriteBulk("mode")
+	w.WriteBulk("standalone")
+	w.WriteBulk("role")
+	w.WriteBulk("master")
+	w.WriteBulk("modules")
+	w.WriteArray(0)
+}
+
+// there is a single database
+func (s *RESPServer) selectDB(w *Writer, args []string) {
+	if args[1] != "0" {
+		w.WriteError("ERR DB index is out of range")
+		return
+	}
+	w.WriteSimple("OK")
+}
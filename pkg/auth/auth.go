@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	router "mem-db/pkg/api/http/router"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// context key of the authenticator, it's missing when the authentication is disabled
+const AuthenticatorKey string = "authenticator"
+
+const APIKeyHeader = "X-API-Key"
+
+// the keys and the token secret must be at least this long
+const minKeyLength = 16
+
+type Role int
+
+// every role can do what the lower ones can
+const (
+	RoleReader Role = iota + 1
+	RoleWriter
+	RoleAdmin
+)
+
+var (
+	ErrMissingCredentials = errors.New("Missing API key or bearer token")
+	ErrInvalidCredentials = errors.New("Invalid API key or bearer token")
+)
+
+func ParseRole(role string) (Role, error) {
+	switch role {
+	case "reader":
+		return RoleReader, nil
+	case "writer":
+		return RoleWriter, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("Unknown role %q, it must be reader, writer or admin", role)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "reader"
+	case RoleWriter:
+		return "writer"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// Principal is the client authenticated by a key or a token
+type Principal struct {
+	Name string
+	Role Role
+	// every namespace when it's empty
+	Namespaces []string
+	// the other nodes, authenticated by the node key
+	Node bool
+}
+
+// used when the authentication is disabled
+var unrestricted = &Principal{Name: "anonymous", Role: RoleAdmin}
+
+type principalKey struct{}
+
+// returns the client of the request, every request is allowed when the authentication is disabled
+func FromContext(ctx context.Context) *Principal {
+	if principal, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return principal
+	}
+	return unrestricted
+}
+
+// false when the authentication is disabled
+func Authenticated(ctx context.Context) bool {
+	_, ok := ctx.Value(principalKey{}).(*Principal)
+	return ok
+}
+
+func (p *Principal) Scoped() bool {
+	return len(p.Namespaces) > 0
+}
+
+func (p *Principal) CanAccess(namespace string) bool {
+	return !p.Scoped() || slices.Contains(p.Namespaces, namespace)
+}
+
+// Authenticator checks the API keys and the bearer tokens of the http requests
+type Authenticator struct {
+	// keyed by the hash of the keys, so the lookups don't depend on the content of the keys
+	keys   map[[sha256.Size]byte]*Principal
+	secret []byte
+	logger log.Logger
+}
+
+// returns nil when the authentication is disabled
+func NewAuthenticator(ctx context.Context, options *config.AuthOptions) (*Authenticator, error) {
+	if !options.Enabled {
+		return nil, nil
+	}
+
+	a := &Authenticator{
+		keys:   make(map[[sha256.Size]byte]*Principal),
+		secret: []byte(options.TokenSecret),
+		logger: ctx.Value(log.LoggerKey).(log.Logger),
+	}
+	if options.TokenSecret != "" {
+		if err := CheckKey(options.TokenSecret); err != nil {
+			return nil, fmt.Errorf("Token secret: %v", err)
+		}
+	}
+	for i, key := range options.Keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("Key %d has no value", i)
+		}
+		if err := CheckKey(key.Key); err != nil {
+			return nil, fmt.Errorf("Key %q: %v", key.Name, err)
+		}
+		role, err := ParseRole(key.Role)
+		if err != nil {
+			return nil, fmt.Errorf("Key %q: %v", key.Name, err)
+		}
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, found := a.keys[hash]; found {
+			return nil, fmt.Errorf("Key %q is defined twice", key.Name)
+		}
+		a.keys[hash] = &Principal{Name: key.Name, Role: role, Namespaces: key.Namespaces}
+	}
+	if options.NodeKey != "" {
+		if err := CheckKey(options.NodeKey); err != nil {
+			return nil, fmt.Errorf("Node key: %v", err)
+		}
+		a.keys[sha256.Sum256([]byte(options.NodeKey))] = &Principal{Name: "node", Role: RoleAdmin, Node: true}
+	}
+	return a, nil
+}
+
+// rejects the short keys and the placeholders of the sample config
+func CheckKey(key string) error {
+	if len(key) < minKeyLength {
+		return fmt.Errorf("It must have at least %d characters", minKeyLength)
+	}
+	if strings.HasPrefix(strings.ToLower(key), "change-me") {
+		return errors.New("It must be replaced, it's a placeholder")
+	}
+	return nil
+}
+
+// the key or token of "Authorization: Bearer <key or token>" or "X-API-Key: <key>"
+func Credentials(r *http.Request) (string, error) {
+	credentials := r.Header.Get(APIKeyHeader)
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		scheme, value, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return "", ErrInvalidCredentials
+		}
+		credentials = strings.TrimSpace(value)
+	}
+	if credentials == "" {
+		return "", ErrMissingCredentials
+	}
+	return credentials, nil
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credentials, err := Credentials(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if principal, found := a.keys[sha256.Sum256([]byte(credentials))]; found {
+		return principal, nil
+	}
+	// the tokens are the only credentials with dots
+	if strings.Count(credentials, ".") == 2 && len(a.secret) > 0 {
+		return VerifyToken(a.secret, credentials)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// rejects the requests without valid credentials with 401, except the CORS preflights.
+// A nil authenticator lets every request through.
+func (a *Authenticator) Middleware(writeError func(w http.ResponseWriter, r *http.Request, statusCode int)) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := a.Authenticate(r)
+			if err != nil {
+				a.reject(r, "", err.Error())
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mem-db"`)
+				writeError(w, r, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
+
+// rejects with 403 the clients whose role is lower than role
+func (a *Authenticator) Require(role Role, writeError func(w http.ResponseWriter, r *http.Request, statusCode int)) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := FromContext(r.Context())
+			if principal.Role < role {
+				a.reject(r, principal.Name, fmt.Sprintf("role %s, %s required", principal.Role, role))
+				writeError(w, r, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logs the access denied by a handler, like a namespace the client can't use
+func (a *Authenticator) Deny(r *http.Request, reason string) {
+	if a != nil {
+		a.reject(r, FromContext(r.Context()).Name, reason)
+	}
+}
+
+func (a *Authenticator) reject(r *http.Request, name, reason string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client := host
+	if name != "" {
+		client = fmt.Sprintf("%s (%s)", name, host)
+	}
+	a.logger.Warn(fmt.Sprintf("Access denied to %s for %s %s request=%s: %s",
+		client, r.Method, r.URL.Path, router.RequestID(r.Context()), reason))
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the tokens are JWTs signed with HS256, so the usual libraries can issue them
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type Claims struct {
+	Subject string `json:"sub"`
+	// "reader", "writer" or "admin"
+	Role       string   `json:"role"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// unix time, the token doesn't expire when it's 0
+	ExpiresAt int64 `json:"exp,omitempty"`
+	IssuedAt  int64 `json:"iat,omitempty"`
+}
+
+func SignToken(secret []byte, claims *Claims) (string, error) {
+	if _, err := ParseRole(claims.Role); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("Cannot encode claims: %v", err)
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(secret, unsigned), nil
+}
+
+func VerifyToken(secret []byte, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+	header, payload, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, header+"."+payload))) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// the header is signed, only HS256 is accepted
+	var alg struct {
+		Alg string `json:"alg"`
+	}
+	if decoded, err := base64.RawURLEncoding.DecodeString(header); err != nil || json.Unmarshal(decoded, &alg) != nil || alg.Alg != "HS256" {
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims Claims
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(decoded, &claims) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("Token of %s expired", claims.Subject)
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Name: claims.Subject, Role: role, Namespaces: claims.Namespaces}, nil
+}
+
+func sign(secret []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,189 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// the files are checked at most once per interval, during the handshakes
+const checkInterval = time.Second
+
+// Store keeps the certificate and the CA of the tls options and reloads them when the files change,
+// so the rotated certificates are used by the next connections without a restart
+type Store struct {
+	options *config.TLSOptions
+	logger  log.Logger
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	// nil when there's no CA file, the system roots are used
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+// returns nil when the options set neither a certificate nor a CA
+func NewStore(ctx context.Context, options *config.TLSOptions) (*Store, error) {
+	if options.CertFile == "" && options.CAFile == "" {
+		return nil, nil
+	}
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return nil, fmt.Errorf("Both certFile and keyFile must be set")
+	}
+	// the client certificates couldn't be verified
+	if options.RequireClientCert && options.CAFile == "" {
+		return nil, fmt.Errorf("A caFile must be set with requireClientCert")
+	}
+
+	s := &Store{
+		options: options,
+		logger:  ctx.Value(log.LoggerKey).(log.Logger),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// true when the store can serve https
+func (s *Store) HasCertificate() bool {
+	return s != nil && s.options.CertFile != ""
+}
+
+func (s *Store) files() []string {
+	var files []string
+	for _, file := range []string{s.options.CertFile, s.options.KeyFile, s.options.CAFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (s *Store) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range s.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("Cannot read %s: %v", file, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	var certificate *tls.Certificate
+	if s.options.CertFile != "" {
+		loaded, err := tls.LoadX509KeyPair(s.options.CertFile, s.options.KeyFile)
+		if err != nil {
+			return fmt.Errorf("Cannot load certificate %s: %v", s.options.CertFile, err)
+		}
+		certificate = &loaded
+	}
+
+	var pool *x509.CertPool
+	if s.options.CAFile != "" {
+		pem, err := os.ReadFile(s.options.CAFile)
+		if err != nil {
+			return fmt.Errorf("Cannot read CA %s: %v", s.options.CAFile, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificate found in CA %s", s.options.CAFile)
+		}
+	}
+
+	s.mu.Lock()
+	s.certificate, s.pool, s.modTimes = certificate, pool, modTimes
+	s.checkedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// reloads the files when one of them changed, the previous ones are kept when the new ones are invalid,
+// e.g. while a certificate is written before its key
+func (s *Store) refresh() {
+	s.mu.Lock()
+	if time.Since(s.checkedAt) < checkInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.checkedAt = time.Now()
+	changed := false
+	for file, modTime := range s.modTimes {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(modTime) {
+			changed = true
+		}
+	}
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := s.load(); err != nil {
+		s.logger.Error("Cannot reload the certificates, the previous ones are used: ", err)
+		return
+	}
+	s.logger.Info("Reloaded the certificate ", s.options.CertFile)
+}
+
+func (s *Store) current() (*tls.Certificate, *x509.CertPool) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.certificate, s.pool
+}
+
+func (s *Store) clientAuth() tls.ClientAuthType {
+	if s.options.RequireClientCert {
+		return tls.RequireAndVerifyClientCert
+	}
+	if s.options.CAFile != "" {
+		return tls.VerifyClientCertIfGiven
+	}
+	return tls.NoClientCert
+}
+
+// config of a server, every handshake gets the current certificate and CA
+func (s *Store) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, pool := s.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificate},
+				ClientCAs:    pool,
+				ClientAuth:   s.clientAuth(),
+			}, nil
+		},
+	}
+}
+
+// config of a client, it sends the certificate when the server asks for one
+func (s *Store) ClientConfig() *tls.Config {
+	certificate, pool := s.current()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if certificate == nil {
+				return &tls.Certificate{}, nil
+			}
+			return certificate, nil
+		},
+	}
+}
+
+// dials tls connections with the current client config, for http.Transport.DialTLSContext
+func (s *Store) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &tls.Dialer{Config: s.ClientConfig()}
+	return dialer.DialContext(ctx, network, addr)
+}
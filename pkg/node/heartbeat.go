@@ -23,9 +23,10 @@ func (n *Node) sendHeartbeat(ctx context.Context, nodeName string) bool {
 
 func (n *Node) CheckAndRemoveDeadWorkers() {
 	var wg sync.WaitGroup
-	deadWorkers := make(chan string, len(n.Workers))
+	workers := n.WorkerNames()
+	deadWorkers := make(chan string, len(workers))
 
-	for worker := range n.Workers {
+	for _, worker := range workers {
 		wg.Add(1)
 		go func(worker string) {
 			defer wg.Done()
@@ -0,0 +1,234 @@
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	config "mem-db/cmd/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// join tokens look like "join.<payload>.<signature>", so they can't be mistaken for a secret
+const joinTokenPrefix = "join"
+
+var (
+	ErrMissingJoinToken = errors.New("Missing join token")
+	ErrInvalidJoinToken = errors.New("Invalid join secret or token")
+	ErrOpenRegistration = errors.New("The registration is open, set a join secret to issue tokens")
+)
+
+type joinClaims struct {
+	// the token registers only this worker, any worker when it's empty
+	Name string `json:"name,omitempty"`
+	// unix milliseconds
+	IssuedAt int64 `json:"iat"`
+	// unix milliseconds, the token doesn't expire when it's 0
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// saved in the state file, so a rotation or a revocation survives the restarts of the master
+type joinState struct {
+	Secret  string           `json:"secret"`
+	Revoked map[string]int64 `json:"revoked"`
+}
+
+// JoinTokens verifies the secrets and the tokens sent by the workers which register to the master
+type JoinTokens struct {
+	mu        sync.Mutex
+	secret    string
+	stateFile string
+	// unix milliseconds of the revocation, by worker name
+	revoked map[string]int64
+}
+
+func NewJoinTokens(options *config.JoinOptions) (*JoinTokens, error) {
+	j := &JoinTokens{
+		secret:    options.Secret,
+		stateFile: options.StateFile,
+		revoked:   make(map[string]int64),
+	}
+	if j.stateFile == "" {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(j.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read join state %s: %v", j.stateFile, err)
+	}
+	var state joinState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("Cannot decode join state %s: %v", j.stateFile, err)
+	}
+	// a rotated secret replaces the one of the config
+	if state.Secret != "" {
+		j.secret = state.Secret
+	}
+	for name, revokedAt := range state.Revoked {
+		j.revoked[name] = revokedAt
+	}
+	return j, nil
+}
+
+// true when any worker can register
+func (j *JoinTokens) Open() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.secret == ""
+}
+
+// checks the secret or the token sent by a worker. A revoked worker can only register
+// again with a token issued after its revocation.
+func (j *JoinTokens) Verify(name, credential string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	revokedAt, revoked := j.revoked[name]
+	if j.secret == "" && !revoked {
+		return nil
+	}
+	if credential == "" {
+		return ErrMissingJoinToken
+	}
+
+	if !strings.HasPrefix(credential, joinTokenPrefix+".") {
+		if j.secret == "" || !hmac.Equal([]byte(credential), []byte(j.secret)) {
+			return ErrInvalidJoinToken
+		}
+		if revoked {
+			return fmt.Errorf("Worker %s is revoked, it needs a new join token", name)
+		}
+		return nil
+	}
+
+	claims, err := j.verifyToken(credential)
+	if err != nil {
+		return err
+	}
+	if claims.Name != "" && claims.Name != name {
+		return fmt.Errorf("Join token of %s used by %s", claims.Name, name)
+	}
+	if revoked && claims.IssuedAt < revokedAt {
+		return fmt.Errorf("Worker %s is revoked, the join token was issued before", name)
+	}
+	return nil
+}
+
+func (j *JoinTokens) verifyToken(token string) (*joinClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || j.secret == "" {
+		return nil, ErrInvalidJoinToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signJoinToken(j.secret, parts[1]))) {
+		return nil, ErrInvalidJoinToken
+	}
+
+	var claims joinClaims
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return nil, ErrInvalidJoinToken
+	}
+	if claims.ExpiresAt != 0 && time.Now().UnixMilli() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("Join token expired")
+	}
+	return &claims, nil
+}
+
+// signs a token for the worker name, or for any worker when name is empty. The token doesn't expire when ttl is 0.
+func (j *JoinTokens) Issue(name string, ttl time.Duration) (string, time.Time, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.secret == "" {
+		return "", time.Time{}, ErrOpenRegistration
+	}
+	now := time.Now()
+	claims := &joinClaims{Name: name, IssuedAt: now.UnixMilli()}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+		claims.ExpiresAt = expiresAt.UnixMilli()
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Cannot encode join token: %v", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return joinTokenPrefix + "." + payload + "." + signJoinToken(j.secret, payload), expiresAt, nil
+}
+
+// replaces the secret with a random one, the previous secret and the tokens signed with it are rejected
+func (j *JoinTokens) Rotate() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("Cannot generate join secret: %v", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	previous := j.secret
+	j.secret = hex.EncodeToString(random)
+	if err := j.save(); err != nil {
+		j.secret = previous
+		return "", err
+	}
+	return j.secret, nil
+}
+
+func (j *JoinTokens) Revoke(name string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.revoked[name] = time.Now().UnixMilli()
+	return j.save()
+}
+
+// revoked workers, with the time of their revocation
+func (j *JoinTokens) Revoked() map[string]time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	revoked := make(map[string]time.Time, len(j.revoked))
+	for name, revokedAt := range j.revoked {
+		revoked[name] = time.UnixMilli(revokedAt)
+	}
+	return revoked
+}
+
+// writes the state to a temporary file renamed over the previous one, so it's never partially written
+func (j *JoinTokens) save() error {
+	if j.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(&joinState{Secret: j.secret, Revoked: j.revoked})
+	if err != nil {
+		return fmt.Errorf("Cannot encode join state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.stateFile), 0755); err != nil {
+		return fmt.Errorf("Cannot create directory of join state: %v", err)
+	}
+	tmp := j.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("Cannot write join state: %v", err)
+	}
+	if err := os.Rename(tmp, j.stateFile); err != nil {
+		return fmt.Errorf("Cannot write join state: %v", err)
+	}
+	return nil
+}
+
+func signJoinToken(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(joinTokenPrefix + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
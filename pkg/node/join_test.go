@@ -0,0 +1,170 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	auth "mem-db/pkg/auth"
+)
+
+func TestJoinTokens(t *testing.T) {
+	options := &config.JoinOptions{Secret: "cluster-secret", StateFile: filepath.Join(t.TempDir(), "join", "state.json")}
+	join, err := NewJoinTokens(options)
+	if err != nil {
+		t.Fatalf("Cannot create join tokens: %v", err)
+	}
+
+	if err := join.Verify("worker1", "cluster-secret"); err != nil {
+		t.Fatalf("Expected the secret to be accepted, got %v", err)
+	}
+	for _, credential := range []string{"", "wrong-secret", "join.e30.c2lnbmF0dXJl"} {
+		if err := join.Verify("worker1", credential); err == nil {
+			t.Fatalf("Expected %q to be rejected", credential)
+		}
+	}
+
+	token, _, err := join.Issue("worker2", time.Hour)
+	if err != nil {
+		t.Fatalf("Cannot issue token: %v", err)
+	}
+	if err := join.Verify("worker2", token); err != nil {
+		t.Fatalf("Expected the token to be accepted, got %v", err)
+	}
+	if err := join.Verify("worker3", token); err == nil {
+		t.Fatalf("Expected the token of worker2 to be rejected for worker3")
+	}
+	expired, _, _ := join.Issue("", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if err := join.Verify("worker2", expired); err == nil {
+		t.Fatalf("Expected an expired token to be rejected")
+	}
+
+	// a revoked worker needs a token issued after its revocation
+	if err := join.Revoke("worker2"); err != nil {
+		t.Fatalf("Cannot revoke worker: %v", err)
+	}
+	for _, credential := range []string{"cluster-secret", token} {
+		if err := join.Verify("worker2", credential); err == nil {
+			t.Fatalf("Expected the revoked worker to be rejected with %q", credential)
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	renewed, _, _ := join.Issue("worker2", 0)
+	if err := join.Verify("worker2", renewed); err != nil {
+		t.Fatalf("Expected a new token to be accepted, got %v", err)
+	}
+
+	secret, err := join.Rotate()
+	if err != nil {
+		t.Fatalf("Cannot rotate secret: %v", err)
+	}
+	for _, credential := range []string{"cluster-secret", renewed} {
+		if err := join.Verify("worker1", credential); err == nil {
+			t.Fatalf("Expected %q to be rejected after the rotation", credential)
+		}
+	}
+
+	// the rotation and the revocation are kept after a restart
+	restarted, err := NewJoinTokens(options)
+	if err != nil {
+		t.Fatalf("Cannot load join state: %v", err)
+	}
+	if err := restarted.Verify("worker1", secret); err != nil {
+		t.Fatalf("Expected the rotated secret to be accepted, got %v", err)
+	}
+	if err := restarted.Verify("worker2", secret); err == nil {
+		t.Fatalf("Expected worker2 to stay revoked")
+	}
+}
+
+func TestRegisterWorkerRequiresJoinToken(t *testing.T) {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	join, _ := NewJoinTokens(&config.JoinOptions{Secret: "cluster-secret"})
+	node := &Node{
+		Name:    "master",
+		Workers: map[string]struct{}{},
+		Logger:  logger,
+		join:    join,
+	}
+
+	tests := []string{
+		`{"name":"intruder"}`,
+		`{"name":"intruder","joinToken":"guess"}`,
+		`{"joinToken":"cluster-secret"}`,
+	}
+	for _, body := range tests {
+		w := httptest.NewRecorder()
+		node.registerWorker(w, httptest.NewRequest("POST", "/master/register", strings.NewReader(body)))
+
+		var response Response
+		json.NewDecoder(w.Body).Decode(&response)
+		if w.Code != http.StatusForbidden || response.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected %s to be rejected, got %d %+v", body, w.Code, response)
+		}
+	}
+	if len(node.Workers) != 0 {
+		t.Fatalf("Expected no registered worker, got %v", node.Workers)
+	}
+
+	w := httptest.NewRecorder()
+	node.listWorkers(w, httptest.NewRequest("GET", "/master/workers", nil))
+	var workers WorkersResponse
+	if err := json.NewDecoder(w.Body).Decode(&workers); err != nil || len(workers.Workers) != 0 {
+		t.Fatalf("Unexpected workers %+v %v", workers, err)
+	}
+}
+
+func TestMasterAdminRoutesNeedAuthentication(t *testing.T) {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	ctx := context.WithValue(context.Background(), log.LoggerKey, logger)
+	join, _ := NewJoinTokens(&config.JoinOptions{Secret: "cluster-secret"})
+	node := &Node{
+		Name:    "master",
+		Workers: map[string]struct{}{},
+		Logger:  logger,
+		join:    join,
+	}
+
+	send := func(server *MasterHttpServer, method, path, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer admin-key-for-tests")
+		w := httptest.NewRecorder()
+		server.server.Router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// without authentication anybody could take over the cluster
+	open := NewMasterHttpServer(ctx, &config.NodeOptions{ApiOptions: &config.ApiOptions{}}, node)
+	for _, route := range [][]string{
+		{"POST", "/master/join-tokens", `{"name":"worker-3"}`},
+		{"POST", "/master/join-secret:rotate", ""},
+		{"DELETE", "/master/workers/worker-1", ""},
+	} {
+		if code := send(open, route[0], route[1], route[2]); code != http.StatusForbidden {
+			t.Fatalf("Expected %s %s to be refused without authentication, got %d", route[0], route[1], code)
+		}
+	}
+	if code := send(open, "GET", "/master/workers", ""); code != http.StatusOK {
+		t.Fatalf("Expected the workers to be listed, got %d", code)
+	}
+
+	authenticator, err := auth.NewAuthenticator(ctx, &config.AuthOptions{
+		Enabled: true,
+		Keys:    []config.APIKey{{Name: "admin", Key: "admin-key-for-tests", Role: "admin"}},
+	})
+	if err != nil {
+		t.Fatalf("Cannot create authenticator: %v", err)
+	}
+	protected := NewMasterHttpServer(context.WithValue(ctx, auth.AuthenticatorKey, authenticator), &config.NodeOptions{ApiOptions: &config.ApiOptions{}}, node)
+	if code := send(protected, "POST", "/master/join-tokens", `{"name":"worker-3"}`); code != http.StatusOK {
+		t.Fatalf("Expected an admin to issue a token, got %d", code)
+	}
+}
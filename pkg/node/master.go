@@ -11,7 +11,16 @@ import (
 	log "mem-db/cmd/logger"
 	httpclient "mem-db/pkg/api/http/client"
 	httpserver "mem-db/pkg/api/http/server"
+	auth "mem-db/pkg/auth"
+	service "mem-db/pkg/service"
+	util "mem-db/pkg/util"
 	"net/http"
+	"time"
+)
+
+const (
+	forwardRetryAttempts = 3
+	forwardRetryInterval = 500 * time.Millisecond
 )
 
 type MasterHttpServer struct {
@@ -21,15 +30,38 @@ type MasterHttpServer struct {
 
 func NewMasterHttpServer(ctx context.Context, options *config.NodeOptions, node *Node) *MasterHttpServer {
 	httpServer := &MasterHttpServer{
-		server: httpserver.NewServer(ctx, options.ApiOptions.Port),
+		server: httpserver.NewServer(ctx, options.ApiOptions),
 		logger: ctx.Value(log.LoggerKey).(log.Logger),
 	}
-	httpServer.server.Router.AddRoute("POST", "/master/register", node.registerWorker)
-	// httpServer.server.Router.AddRoute("POST", "/master/replicate", node.replicate)
+	authenticator := httpServer.server.Auth
+	if authenticator == nil {
+		httpServer.logger.Warn("The authentication is disabled, the admin endpoints of the master answer 403")
+	}
+
+	master := httpServer.server.Router.Group("/master", authenticator.Require(auth.RoleAdmin, httpServer.server.Router.WriteError))
+	master.AddRoute("POST", "/register", node.registerWorker)
+	master.AddRoute("POST", "/join-tokens", requireAuthentication(authenticator, node.issueJoinToken))
+	master.AddRoute("POST", "/join-secret:rotate", requireAuthentication(authenticator, node.rotateJoinSecret))
+	master.AddRoute("GET", "/workers", node.listWorkers)
+	master.AddRoute("DELETE", "/workers/{name}", requireAuthentication(authenticator, node.revokeWorker))
+	// master.AddRoute("POST", "/replicate", node.replicate)
 
 	return httpServer
 }
 
+// the routes which change the cluster are refused when the admins can't be authenticated
+func requireAuthentication(authenticator *auth.Authenticator, next http.HandlerFunc) http.HandlerFunc {
+	if authenticator != nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusForbidden, &Response{
+			Status:     "Forbidden",
+			StatusCode: http.StatusForbidden,
+			Message:    "The admin endpoints of the master need the authentication to be enabled"})
+	}
+}
+
 func (s *MasterHttpServer) Start() error {
 	s.logger.Info("Starting Master Node..")
 	return s.server.Start()
@@ -43,8 +75,6 @@ func (n *Node) registerWorker(w http.ResponseWriter, r *http.Request) {
 	var wd *NodeDetails = &NodeDetails{}
 	var err error
 
-	n.Logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
-
 	err = json.NewDecoder(r.Body).Decode(wd)
 	if err != nil {
 		n.Logger.Error("Cannot decode workerDetails: ", err.Error())
@@ -55,6 +85,20 @@ func (n *Node) registerWorker(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the database is sent to the worker, only the ones with the join secret or a token can register
+	err = n.join.Verify(wd.Name, wd.JoinToken)
+	if err == nil && wd.Name == "" {
+		err = fmt.Errorf("Missing worker name")
+	}
+	if err != nil {
+		n.Logger.Warn(fmt.Sprintf("Rejected the registration of worker %q from %s: %v", wd.Name, r.RemoteAddr, err))
+		writeResponse(w, http.StatusForbidden, &Response{
+			Status:     "Forbidden",
+			StatusCode: http.StatusForbidden,
+			Message:    err.Error()})
+		return
+	}
+
 	n.RegisterWorker(wd.Name)
 	// send post request to worker with encoded db
 	workerUrl := httpclient.GetURL(wd.Name, n.Port, "/worker/master-database")
@@ -97,14 +141,14 @@ func (n *Node) registerWorker(w http.ResponseWriter, r *http.Request) {
 func (n *Node) replicateToWorkers(ctx context.Context, isGRPC bool) {
 	for {
 		select {
-		case data := <-n.forwardingCh:
+		case req := <-n.forwardingCh:
 			if isGRPC {
-				err := n.ForwardToWorkersGRPC(data)
+				err := n.ForwardToWorkersGRPC(req)
 				if err != nil {
 					n.Logger.Error("Cannot forward data to workers: ", err)
 				}
 			} else {
-				err := n.ForwardToWorkersHTTP(data)
+				err := n.ForwardToWorkersHTTP(req)
 				if err != nil {
 					n.Logger.Error("Cannot forward data to workers: ", err)
 				}
@@ -116,20 +160,27 @@ func (n *Node) replicateToWorkers(ctx context.Context, isGRPC bool) {
 	}
 }
 
-func (n *Node) ForwardToWorkersGRPC(data []byte) error {
+func (n *Node) ForwardToWorkersGRPC(req *service.ForwardedRequest) error {
 	return nil
 }
 
-// forward requests with words to the workers
-func (n *Node) ForwardToWorkersHTTP(data []byte) error {
-	n.Logger.Debug("Started forwarding the request to the workers: ", n.Workers)
+// forward requests which change the database to the workers
+func (n *Node) ForwardToWorkersHTTP(req *service.ForwardedRequest) error {
+	workers := n.WorkerNames()
+	n.Logger.Debug("Started forwarding the request to the workers: ", workers)
 
 	var errs error
-	for workerName, _ := range n.Workers {
-		forwardURL := httpclient.GetURL(workerName, 8080, "/words/register")
+	for _, workerName := range workers {
+		forwardURL := httpclient.GetURL(workerName, 8080, req.Endpoint)
 		n.Logger.Debug("Forwarding the request to  ", forwardURL)
 
-		err := httpclient.SendPostRequest(forwardURL, data)
+		headers := http.Header{}
+		headers.Set(service.IdempotencyKeyHeader, req.IdempotencyKey)
+
+		// the workers ignore the duplicates, so failed requests can be sent again
+		err := util.Retry(context.Background(), func() error {
+			return httpclient.SendRequest(req.Method, forwardURL, req.Payload, headers)
+		}, forwardRetryAttempts, forwardRetryInterval)
 		if err != nil {
 			errs = errors.Join(errs, fmt.Errorf("Failed to forward request to worker %s: %v", forwardURL, err))
 		}
@@ -145,28 +196,33 @@ func (n *Node) ForwardToWorkersHTTP(data []byte) error {
 // call from master to add worker to the workers list
 func (n *Node) RegisterWorker(workerName string) {
 	n.Logger.Info(fmt.Sprintf("Registered node %s as worker", workerName))
-	if len(n.Workers) >= 0 {
-		n.SetForwarding()
-	}
+
+	// the forwarding changes with the workers, so it's toggled under the same lock
+	n.workersMu.Lock()
+	defer n.workersMu.Unlock()
 	n.Workers[workerName] = struct{}{}
+	n.SetForwarding()
 }
 
 func (n *Node) DeleteWorker(workerName string) {
 	n.Logger.Warn(fmt.Sprintf("Deleted worker %s from list", workerName))
+	n.workersMu.Lock()
 	delete(n.Workers, workerName)
-
 	if len(n.Workers) == 0 {
 		n.UnsetForwarding()
 	}
+	n.workersMu.Unlock()
 
-	n.Logger.Warn("Active Workers: ", n.Workers)
+	n.Logger.Warn("Active Workers: ", n.WorkerNames())
 }
 
 // send the list of workers to every other worker
 // workers should delete themselves from the list
 func (n *Node) BroadcastWorkersList() error {
 
+	n.workersMu.RLock()
 	payload, err := json.Marshal(n.Workers)
+	n.workersMu.RUnlock()
 	if err != nil {
 		return fmt.Errorf("Error marshaling workers list: %v\n", err)
 	}
@@ -178,3 +234,116 @@ func (n *Node) BroadcastWorkersList() error {
 
 	return nil
 }
+
+type JoinTokenRequest struct {
+	// the token registers only this worker, any worker when it's empty
+	Name string `json:"name"`
+	// seconds, the token doesn't expire when it's 0
+	TTL int `json:"ttl"`
+}
+
+type JoinTokenResponse struct {
+	Response
+	Token     string `json:"token,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+type WorkersResponse struct {
+	Response
+	Workers []string `json:"workers"`
+	// time of the revocation, by worker name
+	Revoked map[string]string `json:"revoked"`
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (n *Node) issueJoinToken(w http.ResponseWriter, r *http.Request) {
+	var request JoinTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.TTL < 0 {
+		writeResponse(w, http.StatusBadRequest, &Response{
+			Status:     "Bad Request",
+			StatusCode: http.StatusBadRequest,
+			Message:    "Expected a name and a positive ttl"})
+		return
+	}
+
+	token, expiresAt, err := n.join.Issue(request.Name, time.Duration(request.TTL)*time.Second)
+	if err != nil {
+		writeResponse(w, http.StatusConflict, &Response{
+			Status:     "Conflict",
+			StatusCode: http.StatusConflict,
+			Message:    err.Error()})
+		return
+	}
+	n.Logger.Info(fmt.Sprintf("Issued a join token for worker %q", request.Name))
+
+	response := &JoinTokenResponse{
+		Response: Response{Status: "Success", StatusCode: http.StatusOK},
+		Token:    token,
+	}
+	if !expiresAt.IsZero() {
+		response.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+func (n *Node) rotateJoinSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := n.join.Rotate()
+	if err != nil {
+		n.Logger.Error("Cannot rotate the join secret: ", err)
+		writeResponse(w, http.StatusInternalServerError, &Response{
+			Status:     "Error",
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error()})
+		return
+	}
+	n.Logger.Warn("Rotated the join secret, the previous secret and join tokens are rejected")
+
+	writeResponse(w, http.StatusOK, &JoinTokenResponse{
+		Response: Response{Status: "Success", StatusCode: http.StatusOK},
+		Secret:   secret,
+	})
+}
+
+func (n *Node) listWorkers(w http.ResponseWriter, r *http.Request) {
+	response := &WorkersResponse{
+		Response: Response{Status: "Success", StatusCode: http.StatusOK},
+		Workers:  n.WorkerNames(),
+		Revoked:  make(map[string]string),
+	}
+	for name, revokedAt := range n.join.Revoked() {
+		response.Revoked[name] = revokedAt.UTC().Format(time.RFC3339)
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+// removes the worker from the cluster, it can't register again without a new join token
+func (n *Node) revokeWorker(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := n.join.Revoke(name); err != nil {
+		n.Logger.Error("Cannot revoke worker: ", err)
+		writeResponse(w, http.StatusInternalServerError, &Response{
+			Status:     "Error",
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error()})
+		return
+	}
+
+	if n.HasWorker(name) {
+		n.DeleteWorker(name)
+		if err := n.BroadcastWorkersList(); err != nil {
+			n.Logger.Error("Cannot broadcast the list of workers: ", err.Error())
+		}
+	}
+	n.Logger.Warn(fmt.Sprintf("Revoked worker %s", name))
+
+	writeResponse(w, http.StatusOK, &Response{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Message:    fmt.Sprintf("Worker %s revoked", name)})
+}
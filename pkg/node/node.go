@@ -13,25 +13,34 @@ import (
 	repo "mem-db/pkg/repository"
 	service "mem-db/pkg/service"
 	// util "mem-db/pkg/util"
+	"sort"
+	"sync"
 	"time"
 )
 
 type Node struct {
-	Name              string
-	MasterID          string
-	PartitionMasters  map[string]struct{}
+	Name             string
+	MasterID         string
+	PartitionMasters map[string]struct{}
+	// guarded by workersMu, it's changed by the registrations and the heartbeats
 	Workers           map[string]struct{}
+	workersMu         sync.RWMutex
 	Port              int
 	HeartbeatInterval int
 	Logger            log.Logger
-	forwardingCh      chan []byte
+	forwardingCh      chan *service.ForwardedRequest
 	Server            api.Server
 	db                repo.DBService
 	ws                service.WordService
+	// verifies the workers which register, when the node is the master
+	join *JoinTokens
+	// secret or token sent by the node when it registers as worker
+	joinToken string
 }
 
 type NodeDetails struct {
-	Name string `json:"name"`
+	Name      string `json:"name"`
+	JoinToken string `json:"joinToken,omitempty"`
 }
 
 type Response struct {
@@ -49,8 +58,18 @@ func NewNode(ctx context.Context, options *config.NodeOptions) *Node {
 		Logger:            ctx.Value(log.LoggerKey).(log.Logger),
 		Port:              options.ApiOptions.Port,
 		HeartbeatInterval: options.HeartbeatInterval,
-		forwardingCh:      make(chan []byte),
+		forwardingCh:      make(chan *service.ForwardedRequest),
+		joinToken:         options.JoinOptions.Token,
 	}
+	if node.joinToken == "" {
+		node.joinToken = options.JoinOptions.Secret
+	}
+
+	join, err := NewJoinTokens(&options.JoinOptions)
+	if err != nil {
+		panic(fmt.Errorf("Cannot load join tokens: %v", err))
+	}
+	node.join = join
 
 	if node.IsMaster() {
 		node.Server = NewMasterHttpServer(ctx, options, node)
@@ -79,8 +98,11 @@ func (n *Node) Start(ctx context.Context) error {
 	time.Sleep(2 * time.Second)
 
 	if n.IsMaster() {
+		if n.join.Open() {
+			n.Logger.Warn("Any worker can register, set nodeOptions.joinOptions.secret to require a join token")
+		}
 		n.SetForwardingCh()
-		if len(n.Workers) > 0 {
+		if len(n.WorkerNames()) > 0 {
 			n.SetForwarding()
 		}
 		n.Logger.Info("Starting Hearbeat process..")
@@ -136,7 +158,7 @@ func (n *Node) BroadcastMasterID() error {
 func (n *Node) broadcast(endpoint string, payload []byte) error {
 
 	var allErrs error
-	for address, _ := range n.Workers {
+	for _, address := range n.WorkerNames() {
 		url := httpclient.GetURL(address, n.Port, endpoint)
 		err := httpclient.SendPostRequest(url, payload)
 		if err != nil {
@@ -160,12 +182,35 @@ func (n *Node) UpdateWorkersList(workers map[string]struct{}) error {
 
 	// delete myselft from workers list
 	delete(workers, n.Name)
+	n.workersMu.Lock()
 	n.Workers = workers
+	n.workersMu.Unlock()
 	n.Logger.Info("Updated workers list: ", workers)
 
 	return nil
 }
 
+// sorted copy of the workers, safe to iterate while they change
+func (n *Node) WorkerNames() []string {
+	n.workersMu.RLock()
+	defer n.workersMu.RUnlock()
+
+	names := make([]string, 0, len(n.Workers))
+	for name := range n.Workers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n *Node) HasWorker(name string) bool {
+	n.workersMu.RLock()
+	defer n.workersMu.RUnlock()
+
+	_, found := n.Workers[name]
+	return found
+}
+
 // worker request for registration to master
 func (n *Node) SendRegistrationReq() error {
 	n.Logger.Info(fmt.Sprintf("Registering worker %s to master %s", n.Name, n.MasterID))
@@ -173,7 +218,7 @@ func (n *Node) SendRegistrationReq() error {
 	// send POST request with
 	url := httpclient.GetURL(n.MasterID, n.Port, "/master/register")
 
-	payload, err := json.Marshal(NodeDetails{Name: n.Name})
+	payload, err := json.Marshal(NodeDetails{Name: n.Name, JoinToken: n.joinToken})
 	if err != nil {
 		return fmt.Errorf("Error marshaling Master's Name: %v\n", err)
 	}
@@ -2,12 +2,16 @@ package node
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	log "mem-db/cmd/logger"
 	httpclient "mem-db/pkg/api/http/client"
+	service "mem-db/pkg/service"
 )
 
 func TestBroadcastWorkersList(t *testing.T) {
@@ -79,16 +83,16 @@ func TestBroadcastMasterID(t *testing.T) {
 		}
 
 		// Verify the request payload
-		var receivedPayload string
+		var receivedPayload NodeDetails
 		err := json.NewDecoder(r.Body).Decode(&receivedPayload)
 		if err != nil {
-			t.Fatalf("Failed to decode request body: %v", err)
+			t.Errorf("Failed to decode request body: %v", err)
 		}
 		defer r.Body.Close()
 
 		expectedPayload := "master"
-		if receivedPayload != expectedPayload {
-			t.Errorf("Expected payload %s, got %s", expectedPayload, receivedPayload)
+		if receivedPayload.Name != expectedPayload {
+			t.Errorf("Expected payload %s, got %s", expectedPayload, receivedPayload.Name)
 		}
 
 		// Respond with 200 OK
@@ -159,3 +163,55 @@ func TestBroadcast(t *testing.T) {
 		t.Fatalf("broadcast() returned an error: %v", err)
 	}
 }
+
+type forwardingService struct {
+	service.WordService
+	forwarding atomic.Bool
+}
+
+func (s *forwardingService) SetForwarding()   { s.forwarding.Store(true) }
+func (s *forwardingService) UnsetForwarding() { s.forwarding.Store(false) }
+
+// the workers change while the master broadcasts and forwards, run with -race
+func TestWorkersConcurrentChanges(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+	httpclient.GetURL = func(address string, port int, endpoint string) string {
+		return mockServer.URL + endpoint
+	}
+
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	ws := &forwardingService{}
+	node := &Node{
+		Name:    "master",
+		Workers: map[string]struct{}{},
+		Logger:  logger,
+		ws:      ws,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(worker string) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				node.RegisterWorker(worker)
+				node.DeleteWorker(worker)
+			}
+		}(fmt.Sprintf("worker%d", i))
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				node.WorkerNames()
+				node.BroadcastWorkersList()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if names := node.WorkerNames(); len(names) != 0 || ws.forwarding.Load() {
+		t.Fatalf("Expected no worker and no forwarding, got %v %v", names, ws.forwarding.Load())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	httpserver "mem-db/pkg/api/http/server"
+	auth "mem-db/pkg/auth"
 	"net/http"
 )
 
@@ -18,14 +19,16 @@ type WorkerHttpServer struct {
 
 func NewWorkerHttpServer(ctx context.Context, options *config.NodeOptions, node *Node) *WorkerHttpServer {
 	httpServer := &WorkerHttpServer{
-		server: httpserver.NewServer(ctx, options.ApiOptions.Port),
+		server: httpserver.NewServer(ctx, options.ApiOptions),
 		logger: ctx.Value(log.LoggerKey).(log.Logger),
 	}
 
-	httpServer.server.Router.AddRoute("POST", "/worker/workers-list", node.updateWorkersList)
-	httpServer.server.Router.AddRoute("POST", "/worker/master-id", node.updateMasterID)
-	httpServer.server.Router.AddRoute("POST", "/worker/master-database", node.loadMasterDatabase)
-	httpServer.server.Router.AddRoute("GET", "/worker/heartbeat", node.heartbeat)
+	// only the other nodes, which send the node key, can call the worker
+	worker := httpServer.server.Router.Group("/worker", httpServer.server.Auth.Require(auth.RoleAdmin, httpServer.server.Router.WriteError))
+	worker.AddRoute("POST", "/workers-list", node.updateWorkersList)
+	worker.AddRoute("POST", "/master-id", node.updateMasterID)
+	worker.AddRoute("POST", "/master-database", node.loadMasterDatabase)
+	worker.AddRoute("GET", "/heartbeat", node.heartbeat)
 
 	return httpServer
 }
@@ -41,8 +44,6 @@ func (s *WorkerHttpServer) Stop(ctx context.Context) error {
 
 func (n *Node) updateWorkersList(w http.ResponseWriter, r *http.Request) {
 	var workersMap map[string]struct{}
-	n.Logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
-
 	err := json.NewDecoder(r.Body).Decode(&workersMap)
 	if err != nil {
 		n.Logger.Error(fmt.Sprintf("Invalid request body for workers list: %v", err))
@@ -63,8 +64,6 @@ func (n *Node) updateWorkersList(w http.ResponseWriter, r *http.Request) {
 
 func (n *Node) updateMasterID(w http.ResponseWriter, r *http.Request) {
 	var md *NodeDetails = &NodeDetails{}
-	n.Logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
-
 	err := json.NewDecoder(r.Body).Decode(md)
 	if err != nil {
 		n.Logger.Error(fmt.Sprintf("Invalid request body for masterID: %v", err))
@@ -82,8 +81,6 @@ func (n *Node) heartbeat(w http.ResponseWriter, r *http.Request) {
 }
 
 func (n *Node) loadMasterDatabase(w http.ResponseWriter, r *http.Request) {
-	n.Logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
-
 	var err error
 	var bodyBytes []byte
 	bodyBytes, err = io.ReadAll(r.Body)
@@ -0,0 +1,890 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.1
+// source: word_service.proto
+
+package service
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	WordService_RegisterWords_FullMethodName           = "/memdb.v1.WordService/RegisterWords"
+	WordService_UploadFiles_FullMethodName             = "/memdb.v1.WordService/UploadFiles"
+	WordService_IngestStream_FullMethodName            = "/memdb.v1.WordService/IngestStream"
+	WordService_GetWordOccurrences_FullMethodName      = "/memdb.v1.WordService/GetWordOccurrences"
+	WordService_GetWordOccurrencesBatch_FullMethodName = "/memdb.v1.WordService/GetWordOccurrencesBatch"
+	WordService_WatchWords_FullMethodName              = "/memdb.v1.WordService/WatchWords"
+	WordService_SuggestWords_FullMethodName            = "/memdb.v1.WordService/SuggestWords"
+	WordService_SimilarWords_FullMethodName            = "/memdb.v1.WordService/SimilarWords"
+	WordService_GetCooccurring_FullMethodName          = "/memdb.v1.WordService/GetCooccurring"
+	WordService_GetDocument_FullMethodName             = "/memdb.v1.WordService/GetDocument"
+	WordService_RetractDocument_FullMethodName         = "/memdb.v1.WordService/RetractDocument"
+	WordService_ListSynonyms_FullMethodName            = "/memdb.v1.WordService/ListSynonyms"
+	WordService_GetSynonym_FullMethodName              = "/memdb.v1.WordService/GetSynonym"
+	WordService_PutSynonym_FullMethodName              = "/memdb.v1.WordService/PutSynonym"
+	WordService_DeleteSynonym_FullMethodName           = "/memdb.v1.WordService/DeleteSynonym"
+	WordService_Search_FullMethodName                  = "/memdb.v1.WordService/Search"
+	WordService_SearchPhrase_FullMethodName            = "/memdb.v1.WordService/SearchPhrase"
+	WordService_GetPostings_FullMethodName             = "/memdb.v1.WordService/GetPostings"
+	WordService_GetJob_FullMethodName                  = "/memdb.v1.WordService/GetJob"
+	WordService_GetJobStats_FullMethodName             = "/memdb.v1.WordService/GetJobStats"
+	WordService_GetDedupStats_FullMethodName           = "/memdb.v1.WordService/GetDedupStats"
+)
+
+// WordServiceClient is the client API for WordService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// WordService mirrors the /v1 HTTP API. The errors are returned as gRPC status
+// codes with an ErrorInfo detail whose reason is the code of the HTTP problem.
+// The calls changing data accept an "idempotency-key" metadata.
+type WordServiceClient interface {
+	RegisterWords(ctx context.Context, in *RegisterWordsRequest, opts ...grpc.CallOption) (*RegisterWordsResponse, error)
+	UploadFiles(ctx context.Context, in *UploadFilesRequest, opts ...grpc.CallOption) (*UploadFilesResponse, error)
+	// registers the texts of a long-lived stream, the summary is sent once the client closes it
+	IngestStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IngestStreamRequest, IngestStreamResponse], error)
+	GetWordOccurrences(ctx context.Context, in *GetWordOccurrencesRequest, opts ...grpc.CallOption) (*GetWordOccurrencesResponse, error)
+	GetWordOccurrencesBatch(ctx context.Context, in *GetWordOccurrencesBatchRequest, opts ...grpc.CallOption) (*GetWordOccurrencesBatchResponse, error)
+	// sends the counts of the terms, then the counts which changed
+	WatchWords(ctx context.Context, in *WatchWordsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchWordsResponse], error)
+	SuggestWords(ctx context.Context, in *SuggestWordsRequest, opts ...grpc.CallOption) (*SuggestWordsResponse, error)
+	SimilarWords(ctx context.Context, in *SimilarWordsRequest, opts ...grpc.CallOption) (*SimilarWordsResponse, error)
+	GetCooccurring(ctx context.Context, in *GetCooccurringRequest, opts ...grpc.CallOption) (*GetCooccurringResponse, error)
+	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*DocumentResponse, error)
+	RetractDocument(ctx context.Context, in *RetractDocumentRequest, opts ...grpc.CallOption) (*DocumentResponse, error)
+	ListSynonyms(ctx context.Context, in *ListSynonymsRequest, opts ...grpc.CallOption) (*ListSynonymsResponse, error)
+	GetSynonym(ctx context.Context, in *GetSynonymRequest, opts ...grpc.CallOption) (*Synonym, error)
+	PutSynonym(ctx context.Context, in *PutSynonymRequest, opts ...grpc.CallOption) (*Synonym, error)
+	DeleteSynonym(ctx context.Context, in *DeleteSynonymRequest, opts ...grpc.CallOption) (*Synonym, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	SearchPhrase(ctx context.Context, in *SearchPhraseRequest, opts ...grpc.CallOption) (*SearchPhraseResponse, error)
+	GetPostings(ctx context.Context, in *GetPostingsRequest, opts ...grpc.CallOption) (*GetPostingsResponse, error)
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	GetJobStats(ctx context.Context, in *GetJobStatsRequest, opts ...grpc.CallOption) (*JobStats, error)
+	GetDedupStats(ctx context.Context, in *GetDedupStatsRequest, opts ...grpc.CallOption) (*DedupStats, error)
+}
+
+type wordServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWordServiceClient(cc grpc.ClientConnInterface) WordServiceClient {
+	return &wordServiceClient{cc}
+}
+
+func (c *wordServiceClient) RegisterWords(ctx context.Context, in *RegisterWordsRequest, opts ...grpc.CallOption) (*RegisterWordsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterWordsResponse)
+	err := c.cc.Invoke(ctx, WordService_RegisterWords_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) UploadFiles(ctx context.Context, in *UploadFilesRequest, opts ...grpc.CallOption) (*UploadFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadFilesResponse)
+	err := c.cc.Invoke(ctx, WordService_UploadFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) IngestStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IngestStreamRequest, IngestStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WordService_ServiceDesc.Streams[0], WordService_IngestStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[IngestStreamRequest, IngestStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WordService_IngestStreamClient = grpc.ClientStreamingClient[IngestStreamRequest, IngestStreamResponse]
+
+func (c *wordServiceClient) GetWordOccurrences(ctx context.Context, in *GetWordOccurrencesRequest, opts ...grpc.CallOption) (*GetWordOccurrencesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetWordOccurrencesResponse)
+	err := c.cc.Invoke(ctx, WordService_GetWordOccurrences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetWordOccurrencesBatch(ctx context.Context, in *GetWordOccurrencesBatchRequest, opts ...grpc.CallOption) (*GetWordOccurrencesBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetWordOccurrencesBatchResponse)
+	err := c.cc.Invoke(ctx, WordService_GetWordOccurrencesBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) WatchWords(ctx context.Context, in *WatchWordsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchWordsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WordService_ServiceDesc.Streams[1], WordService_WatchWords_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchWordsRequest, WatchWordsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WordService_WatchWordsClient = grpc.ServerStreamingClient[WatchWordsResponse]
+
+func (c *wordServiceClient) SuggestWords(ctx context.Context, in *SuggestWordsRequest, opts ...grpc.CallOption) (*SuggestWordsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SuggestWordsResponse)
+	err := c.cc.Invoke(ctx, WordService_SuggestWords_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) SimilarWords(ctx context.Context, in *SimilarWordsRequest, opts ...grpc.CallOption) (*SimilarWordsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SimilarWordsResponse)
+	err := c.cc.Invoke(ctx, WordService_SimilarWords_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetCooccurring(ctx context.Context, in *GetCooccurringRequest, opts ...grpc.CallOption) (*GetCooccurringResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCooccurringResponse)
+	err := c.cc.Invoke(ctx, WordService_GetCooccurring_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*DocumentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DocumentResponse)
+	err := c.cc.Invoke(ctx, WordService_GetDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) RetractDocument(ctx context.Context, in *RetractDocumentRequest, opts ...grpc.CallOption) (*DocumentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DocumentResponse)
+	err := c.cc.Invoke(ctx, WordService_RetractDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) ListSynonyms(ctx context.Context, in *ListSynonymsRequest, opts ...grpc.CallOption) (*ListSynonymsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSynonymsResponse)
+	err := c.cc.Invoke(ctx, WordService_ListSynonyms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetSynonym(ctx context.Context, in *GetSynonymRequest, opts ...grpc.CallOption) (*Synonym, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Synonym)
+	err := c.cc.Invoke(ctx, WordService_GetSynonym_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) PutSynonym(ctx context.Context, in *PutSynonymRequest, opts ...grpc.CallOption) (*Synonym, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Synonym)
+	err := c.cc.Invoke(ctx, WordService_PutSynonym_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) DeleteSynonym(ctx context.Context, in *DeleteSynonymRequest, opts ...grpc.CallOption) (*Synonym, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Synonym)
+	err := c.cc.Invoke(ctx, WordService_DeleteSynonym_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, WordService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) SearchPhrase(ctx context.Context, in *SearchPhraseRequest, opts ...grpc.CallOption) (*SearchPhraseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchPhraseResponse)
+	err := c.cc.Invoke(ctx, WordService_SearchPhrase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetPostings(ctx context.Context, in *GetPostingsRequest, opts ...grpc.CallOption) (*GetPostingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPostingsResponse)
+	err := c.cc.Invoke(ctx, WordService_GetPostings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, WordService_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetJobStats(ctx context.Context, in *GetJobStatsRequest, opts ...grpc.CallOption) (*JobStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobStats)
+	err := c.cc.Invoke(ctx, WordService_GetJobStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wordServiceClient) GetDedupStats(ctx context.Context, in *GetDedupStatsRequest, opts ...grpc.CallOption) (*DedupStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DedupStats)
+	err := c.cc.Invoke(ctx, WordService_GetDedupStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WordServiceServer is the server API for WordService service.
+// All implementations must embed UnimplementedWordServiceServer
+// for forward compatibility.
+//
+// WordService mirrors the /v1 HTTP API. The errors are returned as gRPC status
+// codes with an ErrorInfo detail whose reason is the code of the HTTP problem.
+// The calls changing data accept an "idempotency-key" metadata.
+type WordServiceServer interface {
+	RegisterWords(context.Context, *RegisterWordsRequest) (*RegisterWordsResponse, error)
+	UploadFiles(context.Context, *UploadFilesRequest) (*UploadFilesResponse, error)
+	// registers the texts of a long-lived stream, the summary is sent once the client closes it
+	IngestStream(grpc.ClientStreamingServer[IngestStreamRequest, IngestStreamResponse]) error
+	GetWordOccurrences(context.Context, *GetWordOccurrencesRequest) (*GetWordOccurrencesResponse, error)
+	GetWordOccurrencesBatch(context.Context, *GetWordOccurrencesBatchRequest) (*GetWordOccurrencesBatchResponse, error)
+	// sends the counts of the terms, then the counts which changed
+	WatchWords(*WatchWordsRequest, grpc.ServerStreamingServer[WatchWordsResponse]) error
+	SuggestWords(context.Context, *SuggestWordsRequest) (*SuggestWordsResponse, error)
+	SimilarWords(context.Context, *SimilarWordsRequest) (*SimilarWordsResponse, error)
+	GetCooccurring(context.Context, *GetCooccurringRequest) (*GetCooccurringResponse, error)
+	GetDocument(context.Context, *GetDocumentRequest) (*DocumentResponse, error)
+	RetractDocument(context.Context, *RetractDocumentRequest) (*DocumentResponse, error)
+	ListSynonyms(context.Context, *ListSynonymsRequest) (*ListSynonymsResponse, error)
+	GetSynonym(context.Context, *GetSynonymRequest) (*Synonym, error)
+	PutSynonym(context.Context, *PutSynonymRequest) (*Synonym, error)
+	DeleteSynonym(context.Context, *DeleteSynonymRequest) (*Synonym, error)
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	SearchPhrase(context.Context, *SearchPhraseRequest) (*SearchPhraseResponse, error)
+	GetPostings(context.Context, *GetPostingsRequest) (*GetPostingsResponse, error)
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	GetJobStats(context.Context, *GetJobStatsRequest) (*JobStats, error)
+	GetDedupStats(context.Context, *GetDedupStatsRequest) (*DedupStats, error)
+	mustEmbedUnimplementedWordServiceServer()
+}
+
+// UnimplementedWordServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWordServiceServer struct{}
+
+func (UnimplementedWordServiceServer) RegisterWords(context.Context, *RegisterWordsRequest) (*RegisterWordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterWords not implemented")
+}
+func (UnimplementedWordServiceServer) UploadFiles(context.Context, *UploadFilesRequest) (*UploadFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadFiles not implemented")
+}
+func (UnimplementedWordServiceServer) IngestStream(grpc.ClientStreamingServer[IngestStreamRequest, IngestStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method IngestStream not implemented")
+}
+func (UnimplementedWordServiceServer) GetWordOccurrences(context.Context, *GetWordOccurrencesRequest) (*GetWordOccurrencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWordOccurrences not implemented")
+}
+func (UnimplementedWordServiceServer) GetWordOccurrencesBatch(context.Context, *GetWordOccurrencesBatchRequest) (*GetWordOccurrencesBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWordOccurrencesBatch not implemented")
+}
+func (UnimplementedWordServiceServer) WatchWords(*WatchWordsRequest, grpc.ServerStreamingServer[WatchWordsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchWords not implemented")
+}
+func (UnimplementedWordServiceServer) SuggestWords(context.Context, *SuggestWordsRequest) (*SuggestWordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SuggestWords not implemented")
+}
+func (UnimplementedWordServiceServer) SimilarWords(context.Context, *SimilarWordsRequest) (*SimilarWordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SimilarWords not implemented")
+}
+func (UnimplementedWordServiceServer) GetCooccurring(context.Context, *GetCooccurringRequest) (*GetCooccurringResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCooccurring not implemented")
+}
+func (UnimplementedWordServiceServer) GetDocument(context.Context, *GetDocumentRequest) (*DocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDocument not implemented")
+}
+func (UnimplementedWordServiceServer) RetractDocument(context.Context, *RetractDocumentRequest) (*DocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetractDocument not implemented")
+}
+func (UnimplementedWordServiceServer) ListSynonyms(context.Context, *ListSynonymsRequest) (*ListSynonymsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSynonyms not implemented")
+}
+func (UnimplementedWordServiceServer) GetSynonym(context.Context, *GetSynonymRequest) (*Synonym, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSynonym not implemented")
+}
+func (UnimplementedWordServiceServer) PutSynonym(context.Context, *PutSynonymRequest) (*Synonym, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutSynonym not implemented")
+}
+func (UnimplementedWordServiceServer) DeleteSynonym(context.Context, *DeleteSynonymRequest) (*Synonym, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSynonym not implemented")
+}
+func (UnimplementedWordServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedWordServiceServer) SearchPhrase(context.Context, *SearchPhraseRequest) (*SearchPhraseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchPhrase not implemented")
+}
+func (UnimplementedWordServiceServer) GetPostings(context.Context, *GetPostingsRequest) (*GetPostingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPostings not implemented")
+}
+func (UnimplementedWordServiceServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedWordServiceServer) GetJobStats(context.Context, *GetJobStatsRequest) (*JobStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJobStats not implemented")
+}
+func (UnimplementedWordServiceServer) GetDedupStats(context.Context, *GetDedupStatsRequest) (*DedupStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDedupStats not implemented")
+}
+func (UnimplementedWordServiceServer) mustEmbedUnimplementedWordServiceServer() {}
+func (UnimplementedWordServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeWordServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WordServiceServer will
+// result in compilation errors.
+type UnsafeWordServiceServer interface {
+	mustEmbedUnimplementedWordServiceServer()
+}
+
+func RegisterWordServiceServer(s grpc.ServiceRegistrar, srv WordServiceServer) {
+	// If the following call pancis, it indicates UnimplementedWordServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&WordService_ServiceDesc, srv)
+}
+
+func _WordService_RegisterWords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterWordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).RegisterWords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_RegisterWords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).RegisterWords(ctx, req.(*RegisterWordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_UploadFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).UploadFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_UploadFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).UploadFiles(ctx, req.(*UploadFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_IngestStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WordServiceServer).IngestStream(&grpc.GenericServerStream[IngestStreamRequest, IngestStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WordService_IngestStreamServer = grpc.ClientStreamingServer[IngestStreamRequest, IngestStreamResponse]
+
+func _WordService_GetWordOccurrences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWordOccurrencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetWordOccurrences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetWordOccurrences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetWordOccurrences(ctx, req.(*GetWordOccurrencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetWordOccurrencesBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWordOccurrencesBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetWordOccurrencesBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetWordOccurrencesBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetWordOccurrencesBatch(ctx, req.(*GetWordOccurrencesBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_WatchWords_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchWordsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WordServiceServer).WatchWords(m, &grpc.GenericServerStream[WatchWordsRequest, WatchWordsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WordService_WatchWordsServer = grpc.ServerStreamingServer[WatchWordsResponse]
+
+func _WordService_SuggestWords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestWordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).SuggestWords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_SuggestWords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).SuggestWords(ctx, req.(*SuggestWordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_SimilarWords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimilarWordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).SimilarWords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_SimilarWords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).SimilarWords(ctx, req.(*SimilarWordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetCooccurring_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCooccurringRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetCooccurring(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetCooccurring_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetCooccurring(ctx, req.(*GetCooccurringRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetDocument(ctx, req.(*GetDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_RetractDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetractDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).RetractDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_RetractDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).RetractDocument(ctx, req.(*RetractDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_ListSynonyms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSynonymsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).ListSynonyms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_ListSynonyms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).ListSynonyms(ctx, req.(*ListSynonymsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetSynonym_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSynonymRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetSynonym(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetSynonym_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetSynonym(ctx, req.(*GetSynonymRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_PutSynonym_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutSynonymRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).PutSynonym(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_PutSynonym_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).PutSynonym(ctx, req.(*PutSynonymRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_DeleteSynonym_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSynonymRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).DeleteSynonym(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_DeleteSynonym_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).DeleteSynonym(ctx, req.(*DeleteSynonymRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_SearchPhrase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchPhraseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).SearchPhrase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_SearchPhrase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).SearchPhrase(ctx, req.(*SearchPhraseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetPostings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPostingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetPostings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetPostings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetPostings(ctx, req.(*GetPostingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetJobStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetJobStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetJobStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetJobStats(ctx, req.(*GetJobStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WordService_GetDedupStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDedupStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WordServiceServer).GetDedupStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WordService_GetDedupStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WordServiceServer).GetDedupStats(ctx, req.(*GetDedupStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// WordService_ServiceDesc is the grpc.ServiceDesc for WordService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WordService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "memdb.v1.WordService",
+	HandlerType: (*WordServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterWords",
+			Handler:    _WordService_RegisterWords_Handler,
+		},
+		{
+			MethodName: "UploadFiles",
+			Handler:    _WordService_UploadFiles_Handler,
+		},
+		{
+			MethodName: "GetWordOccurrences",
+			Handler:    _WordService_GetWordOccurrences_Handler,
+		},
+		{
+			MethodName: "GetWordOccurrencesBatch",
+			Handler:    _WordService_GetWordOccurrencesBatch_Handler,
+		},
+		{
+			MethodName: "SuggestWords",
+			Handler:    _WordService_SuggestWords_Handler,
+		},
+		{
+			MethodName: "SimilarWords",
+			Handler:    _WordService_SimilarWords_Handler,
+		},
+		{
+			MethodName: "GetCooccurring",
+			Handler:    _WordService_GetCooccurring_Handler,
+		},
+		{
+			MethodName: "GetDocument",
+			Handler:    _WordService_GetDocument_Handler,
+		},
+		{
+			MethodName: "RetractDocument",
+			Handler:    _WordService_RetractDocument_Handler,
+		},
+		{
+			MethodName: "ListSynonyms",
+			Handler:    _WordService_ListSynonyms_Handler,
+		},
+		{
+			MethodName: "GetSynonym",
+			Handler:    _WordService_GetSynonym_Handler,
+		},
+		{
+			MethodName: "PutSynonym",
+			Handler:    _WordService_PutSynonym_Handler,
+		},
+		{
+			MethodName: "DeleteSynonym",
+			Handler:    _WordService_DeleteSynonym_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _WordService_Search_Handler,
+		},
+		{
+			MethodName: "SearchPhrase",
+			Handler:    _WordService_SearchPhrase_Handler,
+		},
+		{
+			MethodName: "GetPostings",
+			Handler:    _WordService_GetPostings_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _WordService_GetJob_Handler,
+		},
+		{
+			MethodName: "GetJobStats",
+			Handler:    _WordService_GetJobStats_Handler,
+		},
+		{
+			MethodName: "GetDedupStats",
+			Handler:    _WordService_GetDedupStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "IngestStream",
+			Handler:       _WordService_IngestStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchWords",
+			Handler:       _WordService_WatchWords_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "word_service.proto",
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"crypto/subtle"
+	"math"
+	auth "mem-db/pkg/auth"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// the full buckets are dropped at most once per interval, they are the same as new ones
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per client: every client gets rate tokens per second, up to burst,
+// and a request takes one
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+// returns nil when rate is 0, a nil limiter allows every request
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// takes a token of the client, or returns how long it has to wait for the next one
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.sweptAt) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, found := l.buckets[client]
+	if !found {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.sweptAt = now
+}
+
+// the name of the key or token of the request, or the ip of the client when the authentication is disabled
+func Client(r *http.Request) string {
+	if auth.Authenticated(r.Context()) {
+		return auth.FromContext(r.Context()).Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// the ip of a client of the gRPC, RESP and line listeners, the producers of a unix socket share one client
+func ClientAddr(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.Network()
+	}
+	return host
+}
+
+// the requests of the other nodes, like the writes forwarded by the master, are never limited.
+// Without the authentication, they are recognized by the nodeKey they send.
+func Exempt(r *http.Request, nodeKey string) bool {
+	if auth.Authenticated(r.Context()) {
+		return auth.FromContext(r.Context()).Node
+	}
+	if nodeKey == "" {
+		return false
+	}
+	credentials, err := auth.Credentials(r)
+	return err == nil && subtle.ConstantTimeCompare([]byte(credentials), []byte(nodeKey)) == 1
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Usage is the number of words registered by a client today
+type Usage struct {
+	Client string `json:"client"`
+	// UTC day of the usage
+	Date  string `json:"date"`
+	Words int64  `json:"words"`
+	// 0 when the client has no quota
+	Limit int64 `json:"limit"`
+	// nil when the client has no quota
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+// QuotaError is returned when a client has used its daily quota
+type QuotaError struct {
+	Client string
+	Limit  int64
+	// until the quotas are reset, at midnight UTC
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Daily quota of %d words of %s exceeded", e.Limit, e.Client)
+}
+
+// Quotas counts the words registered by every client during the current UTC day
+type Quotas struct {
+	limit  int64
+	limits map[string]int64
+
+	mu   sync.Mutex
+	day  string
+	used map[string]int64
+	now  func() time.Time
+}
+
+// returns nil when no client has a quota, a nil quota accepts every word
+func NewQuotas(limit int64, limits map[string]int64) *Quotas {
+	if limit <= 0 && len(limits) == 0 {
+		return nil
+	}
+	return &Quotas{
+		limit:  limit,
+		limits: limits,
+		used:   make(map[string]int64),
+		now:    time.Now,
+	}
+}
+
+func (q *Quotas) limitOf(client string) int64 {
+	if limit, found := q.limits[client]; found {
+		return limit
+	}
+	return q.limit
+}
+
+// starts a new day when the previous one is over, it must be called with the lock
+func (q *Quotas) today() time.Time {
+	now := q.now().UTC()
+	if day := now.Format(dayLayout); day != q.day {
+		q.day = day
+		q.used = make(map[string]int64)
+	}
+	return now
+}
+
+func (q *Quotas) exceeded(client string, limit int64, now time.Time) *QuotaError {
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return &QuotaError{Client: client, Limit: limit, RetryAfter: tomorrow.Sub(now)}
+}
+
+// adds the words to the usage of the client, unless they exceed its quota
+func (q *Quotas) Reserve(client string, words int64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.today()
+	limit := q.limitOf(client)
+	if limit > 0 && q.used[client]+words > limit {
+		return q.exceeded(client, limit, now)
+	}
+	q.used[client] += words
+	return nil
+}
+
+// returns an error when the client has no words left, for the requests whose words are only known later
+func (q *Quotas) Check(client string) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.today()
+	limit := q.limitOf(client)
+	if limit > 0 && q.used[client] >= limit {
+		return q.exceeded(client, limit, now)
+	}
+	return nil
+}
+
+// adds the words to the usage of the client, even over its quota
+func (q *Quotas) Add(client string, words int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.today()
+	q.used[client] += words
+}
+
+// gives back the words of a request which were not counted
+func (q *Quotas) Refund(client string, words int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// the words charged before midnight were already forgotten
+	q.today()
+	if used := q.used[client] - words; used > 0 {
+		q.used[client] = used
+	} else {
+		delete(q.used, client)
+	}
+}
+
+func (q *Quotas) usage(client string) Usage {
+	usage := Usage{Client: client, Date: q.day, Words: q.used[client], Limit: q.limitOf(client)}
+	if usage.Limit > 0 {
+		remaining := max(usage.Limit-usage.Words, 0)
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+func (q *Quotas) Usage(client string) Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.today()
+	return q.usage(client)
+}
+
+// usage of the clients which registered words today or have their own quota, sorted by client
+func (q *Quotas) All() []Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.today()
+	clients := make(map[string]struct{})
+	for client := range q.used {
+		clients[client] = struct{}{}
+	}
+	for client := range q.limits {
+		clients[client] = struct{}{}
+	}
+
+	usages := make([]Usage, 0, len(clients))
+	for client := range clients {
+		usages = append(usages, q.usage(client))
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Client < usages[j].Client })
+	return usages
+}
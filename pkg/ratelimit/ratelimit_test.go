@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"errors"
+	auth "mem-db/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestLimiter(t *testing.T) {
+	c := &clock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(2, 3)
+	l.now = c.Now
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := l.Allow("producer"); !allowed {
+			t.Fatalf("Expected the request %d of the burst to be allowed", i)
+		}
+	}
+	allowed, wait := l.Allow("producer")
+	if allowed || wait != 500*time.Millisecond {
+		t.Fatalf("Expected to wait 500ms, got %v %v", allowed, wait)
+	}
+	if allowed, _ := l.Allow("reports"); !allowed {
+		t.Fatalf("Expected another client to have its own bucket")
+	}
+
+	c.now = c.now.Add(500 * time.Millisecond)
+	if allowed, _ := l.Allow("producer"); !allowed {
+		t.Fatalf("Expected a token after 500ms")
+	}
+
+	// the idle buckets are dropped, they would be full anyway
+	c.now = c.now.Add(2 * sweepInterval)
+	l.Allow("producer")
+	if len(l.buckets) != 1 {
+		t.Fatalf("Expected the idle bucket to be dropped, got %d buckets", len(l.buckets))
+	}
+
+	disabled := NewLimiter(0, 0)
+	if allowed, _ := disabled.Allow("producer"); !allowed {
+		t.Fatalf("Expected a disabled limiter to allow every request")
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	c := &clock{now: time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)}
+	q := NewQuotas(10, map[string]int64{"importer": 100, "internal": 0})
+	q.now = c.Now
+
+	if err := q.Reserve("producer", 6); err != nil {
+		t.Fatalf("Expected 6 words to be accepted, got %v", err)
+	}
+	var quotaErr *QuotaError
+	if err := q.Reserve("producer", 5); !errors.As(err, &quotaErr) || quotaErr.RetryAfter != 6*time.Hour {
+		t.Fatalf("Expected the quota to be exceeded until midnight, got %v", err)
+	}
+	if err := q.Reserve("importer", 50); err != nil {
+		t.Fatalf("Expected the quota of importer to be used, got %v", err)
+	}
+	if err := q.Reserve("internal", 1000); err != nil {
+		t.Fatalf("Expected internal to have no quota, got %v", err)
+	}
+
+	// the words known after the request can go over the quota
+	q.Add("producer", 5)
+	if err := q.Check("producer"); err == nil {
+		t.Fatalf("Expected producer to have no words left")
+	}
+	usage := q.Usage("producer")
+	if usage.Words != 11 || usage.Limit != 10 || *usage.Remaining != 0 || usage.Date != "2024-05-01" {
+		t.Fatalf("Unexpected usage %+v", usage)
+	}
+
+	all := q.All()
+	if len(all) != 3 || all[0].Client != "importer" || all[1].Client != "internal" || all[1].Remaining != nil {
+		t.Fatalf("Unexpected usages %+v", all)
+	}
+
+	c.now = c.now.Add(7 * time.Hour)
+	if err := q.Reserve("producer", 10); err != nil {
+		t.Fatalf("Expected the quota to be reset the next day, got %v", err)
+	}
+
+	if NewQuotas(0, nil) != nil {
+		t.Fatalf("Expected no quotas")
+	}
+}
+
+func TestQuotasRefund(t *testing.T) {
+	q := NewQuotas(10, nil)
+	q.Reserve("producer", 6)
+	q.Refund("producer", 4)
+	if usage := q.Usage("producer"); usage.Words != 2 {
+		t.Fatalf("Expected 2 words to be used, got %+v", usage)
+	}
+	// the usage never goes below 0, the words refunded the next day were already reset
+	q.Refund("producer", 5)
+	if usage := q.Usage("producer"); usage.Words != 0 {
+		t.Fatalf("Expected no words to be used, got %+v", usage)
+	}
+}
+
+func TestExemptWithNodeKey(t *testing.T) {
+	request := func(header, value string) *http.Request {
+		r := httptest.NewRequest("POST", "/v1/words/register", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+
+	nodeKey := "node-key-for-tests"
+	if !Exempt(request("Authorization", "Bearer "+nodeKey), nodeKey) || !Exempt(request(auth.APIKeyHeader, nodeKey), nodeKey) {
+		t.Fatalf("Expected the node key to exempt the request without authentication")
+	}
+	if Exempt(request("Authorization", "Bearer other-key-for-tests"), nodeKey) || Exempt(request("", ""), nodeKey) {
+		t.Fatalf("Expected the other requests to be limited")
+	}
+	if Exempt(request("Authorization", "Bearer "), "") {
+		t.Fatalf("Expected no request to be exempt without a node key")
+	}
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	config "mem-db/cmd/config"
+	"sort"
+	"sync"
+)
+
+const (
+	CooccurrenceSentence = "sentence"
+	CooccurrenceWindow   = "window"
+
+	defaultCooccurrenceWindow = 5
+	// every pair of a sentence is counted, long sentences are truncated
+	maxSentenceWords = 100
+
+	SortByCount = "count"
+	SortByPMI   = "pmi"
+)
+
+// CooccurrenceCounter counts the pairs of words appearing in the same sentence,
+// or within a sliding window of tokens. The counts are symmetric.
+// It's persisted only by the snapshots, the pairs counted after the last
+// snapshot are lost when the node crashes.
+type CooccurrenceCounter struct {
+	mutex  sync.RWMutex
+	mode   string
+	window int
+	pairs  map[string]map[string]int
+	// sum of the pair counts of every word
+	marginals map[string]int
+	total     int
+	// sentences of the documents, their pairs are subtracted when they are retracted
+	documents map[string][][]string
+}
+
+type Cooccurrence struct {
+	Word  string  `json:"word"`
+	Count int     `json:"count"`
+	PMI   float64 `json:"pmi"`
+}
+
+type cooccurrenceSnapshot struct {
+	Pairs     map[string]map[string]int `json:"pairs"`
+	Documents map[string][][]string     `json:"documents,omitempty"`
+}
+
+func NewCooccurrenceCounter(options *config.CooccurrenceOptions) *CooccurrenceCounter {
+	mode := options.Mode
+	if mode == "" {
+		mode = CooccurrenceSentence
+	}
+	window := options.Window
+	if window <= 1 {
+		window = defaultCooccurrenceWindow
+	}
+
+	return &CooccurrenceCounter{
+		mode:      mode,
+		window:    window,
+		pairs:     make(map[string]map[string]int),
+		marginals: make(map[string]int),
+		documents: make(map[string][][]string),
+	}
+}
+
+// counts the pairs of a text split into sentences of lowercase words
+func (c *CooccurrenceCounter) Add(sentences [][]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.count(sentences, 1)
+}
+
+// same as Add, the sentences are kept to subtract their pairs when the document is retracted
+func (c *CooccurrenceCounter) AddDocument(id string, sentences [][]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.documents[id] = sentences
+	c.count(sentences, 1)
+}
+
+// subtracts the pairs counted for the document, unknown ids are ignored
+func (c *CooccurrenceCounter) RemoveDocument(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sentences, found := c.documents[id]
+	if !found {
+		return
+	}
+	delete(c.documents, id)
+	c.count(sentences, -1)
+}
+
+// drops every pair of the word
+func (c *CooccurrenceCounter) RemoveWord(word string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for partner, count := range c.pairs[word] {
+		c.addPair(word, partner, -count)
+	}
+}
+
+// adds delta to every pair of the sentences
+func (c *CooccurrenceCounter) count(sentences [][]string, delta int) {
+	if c.mode == CooccurrenceWindow {
+		var tokens []string
+		for _, sentence := range sentences {
+			tokens = append(tokens, sentence...)
+		}
+		c.addWindow(tokens, delta)
+		return
+	}
+
+	for _, sentence := range sentences {
+		c.addSentence(sentence, delta)
+	}
+}
+
+// a pair is counted once per sentence
+func (c *CooccurrenceCounter) addSentence(sentence []string, delta int) {
+	seen := make(map[string]struct{})
+	words := make([]string, 0, len(sentence))
+	for _, word := range sentence {
+		if _, found := seen[word]; found {
+			continue
+		}
+		seen[word] = struct{}{}
+		words = append(words, word)
+		if len(words) == maxSentenceWords {
+			break
+		}
+	}
+
+	for i := range words {
+		for j := i + 1; j < len(words); j++ {
+			c.addPair(words[i], words[j], delta)
+		}
+	}
+}
+
+// every pair of tokens at most window-1 positions apart is counted
+func (c *CooccurrenceCounter) addWindow(tokens []string, delta int) {
+	for i := range tokens {
+		for j := i + 1; j < len(tokens) && j < i+c.window; j++ {
+			if tokens[i] != tokens[j] {
+				c.addPair(tokens[i], tokens[j], delta)
+			}
+		}
+	}
+}
+
+func (c *CooccurrenceCounter) addPair(a, b string, count int) {
+	// the pairs of a deleted word are already gone when its documents are retracted
+	count = max(count, -c.pairs[a][b])
+	if count == 0 {
+		return
+	}
+	c.increment(a, b, count)
+	c.increment(b, a, count)
+	c.total += 2 * count
+}
+
+// the words and pairs which are not counted anymore are removed
+func (c *CooccurrenceCounter) increment(a, b string, count int) {
+	if c.pairs[a] == nil {
+		c.pairs[a] = make(map[string]int)
+	}
+	c.pairs[a][b] += count
+	if c.pairs[a][b] <= 0 {
+		delete(c.pairs[a], b)
+	}
+	if len(c.pairs[a]) == 0 {
+		delete(c.pairs, a)
+	}
+
+	c.marginals[a] += count
+	if c.marginals[a] <= 0 {
+		delete(c.marginals, a)
+	}
+}
+
+// returns the words appearing most often with word, sorted by count or by PMI
+func (c *CooccurrenceCounter) Top(word string, sortBy string, limit int) ([]Cooccurrence, error) {
+	if sortBy == "" {
+		sortBy = SortByCount
+	}
+	if sortBy != SortByCount && sortBy != SortByPMI {
+		return nil, fmt.Errorf("Unknown sort %s", sortBy)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	results := make([]Cooccurrence, 0, len(c.pairs[word]))
+	for partner, count := range c.pairs[word] {
+		results = append(results, Cooccurrence{
+			Word:  partner,
+			Count: count,
+			PMI:   c.pmi(word, partner, count),
+		})
+	}
+
+	// ties are broken by word to keep the order deterministic
+	sort.Slice(results, func(i, j int) bool {
+		if sortBy == SortByPMI && results[i].PMI != results[j].PMI {
+			return results[i].PMI > results[j].PMI
+		}
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Word < results[j].Word
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// log2(p(a,b) / (p(a) * p(b))), the probabilities are estimated from the pair counts
+func (c *CooccurrenceCounter) pmi(a, b string, count int) float64 {
+	total := float64(c.total)
+	return math.Log2(float64(count) * total / (float64(c.marginals[a]) * float64(c.marginals[b])))
+}
+
+func (c *CooccurrenceCounter) SnapshotName() string {
+	return "cooccurrence"
+}
+
+func (c *CooccurrenceCounter) EncodeSnapshot() ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	encodedData, err := json.Marshal(&cooccurrenceSnapshot{Pairs: c.pairs, Documents: c.documents})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode co-occurrences: %v", err)
+	}
+	return encodedData, nil
+}
+
+func (c *CooccurrenceCounter) LoadSnapshot(encodedData []byte) error {
+	var snapshot cooccurrenceSnapshot
+	if err := json.Unmarshal(encodedData, &snapshot); err != nil {
+		return fmt.Errorf("Cannot decode co-occurrences: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pairs = make(map[string]map[string]int)
+	c.marginals = make(map[string]int)
+	c.total = 0
+	c.documents = snapshot.Documents
+	if c.documents == nil {
+		c.documents = make(map[string][][]string)
+	}
+	for a, partners := range snapshot.Pairs {
+		for b, count := range partners {
+			c.increment(a, b, count)
+			c.total += count
+		}
+	}
+	return nil
+}
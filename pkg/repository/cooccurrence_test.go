@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"math"
+	"testing"
+
+	config "mem-db/cmd/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCooccurrenceSentence(t *testing.T) {
+	counter := NewCooccurrenceCounter(&config.CooccurrenceOptions{Mode: CooccurrenceSentence})
+	counter.Add([][]string{{"big", "data", "big"}, {"big", "data"}, {"small", "data"}, {"big", "other"}})
+
+	results, err := counter.Top("data", SortByCount, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"big", "small"}, cooccurringWords(results))
+	// a pair is counted once per sentence
+	assert.Equal(t, 2, results[0].Count)
+
+	// total 8, data 3, big 3, small 1
+	assert.InDelta(t, math.Log2(2*8.0/(3*3)), results[0].PMI, 1e-9)
+	assert.InDelta(t, math.Log2(1*8.0/(3*1)), results[1].PMI, 1e-9)
+
+	results, _ = counter.Top("data", SortByPMI, 1)
+	assert.Equal(t, []string{"small"}, cooccurringWords(results))
+}
+
+func TestCooccurrenceWindow(t *testing.T) {
+	counter := NewCooccurrenceCounter(&config.CooccurrenceOptions{Mode: CooccurrenceWindow, Window: 2})
+	counter.Add([][]string{{"a", "b"}, {"c"}})
+
+	results, _ := counter.Top("b", SortByCount, 0)
+	// the window spans the sentences
+	assert.Equal(t, []string{"a", "c"}, cooccurringWords(results))
+
+	results, _ = counter.Top("a", SortByCount, 0)
+	assert.Equal(t, []string{"b"}, cooccurringWords(results))
+}
+
+func TestCooccurrenceSnapshot(t *testing.T) {
+	options := &config.CooccurrenceOptions{}
+	counter := NewCooccurrenceCounter(options)
+	counter.Add([][]string{{"red", "apple"}, {"green", "apple"}})
+
+	encodedData, err := counter.EncodeSnapshot()
+	assert.NoError(t, err)
+
+	loaded := NewCooccurrenceCounter(options)
+	assert.NoError(t, loaded.LoadSnapshot(encodedData))
+
+	expected, _ := counter.Top("apple", SortByCount, 0)
+	actual, _ := loaded.Top("apple", SortByCount, 0)
+	assert.Equal(t, expected, actual)
+}
+
+func TestCooccurrenceRemove(t *testing.T) {
+	options := &config.CooccurrenceOptions{}
+	counter := NewCooccurrenceCounter(options)
+	counter.Add([][]string{{"red", "apple"}})
+	counter.AddDocument("doc1", [][]string{{"green", "apple"}, {"red", "apple"}})
+
+	counter.RemoveDocument("doc1")
+	results, _ := counter.Top("apple", SortByCount, 0)
+	assert.Equal(t, []Cooccurrence{{Word: "red", Count: 1, PMI: 1}}, results)
+	// the marginals and the total are subtracted too
+	assert.Equal(t, 2, counter.total)
+	assert.Equal(t, map[string]int{"red": 1, "apple": 1}, counter.marginals)
+
+	// the retraction of a document with a deleted word doesn't go below zero
+	counter.AddDocument("doc2", [][]string{{"green", "apple"}})
+	encodedData, _ := counter.EncodeSnapshot()
+	counter.RemoveWord("apple")
+	results, _ = counter.Top("green", SortByCount, 0)
+	assert.Empty(t, results)
+	counter.RemoveDocument("doc2")
+	assert.Equal(t, 0, counter.total)
+	assert.Empty(t, counter.pairs)
+
+	// the documents are kept in the snapshots
+	loaded := NewCooccurrenceCounter(options)
+	assert.NoError(t, loaded.LoadSnapshot(encodedData))
+	loaded.RemoveDocument("doc2")
+	results, _ = loaded.Top("apple", SortByCount, 0)
+	assert.Equal(t, []string{"red"}, cooccurringWords(results))
+}
+
+func cooccurringWords(results []Cooccurrence) []string {
+	words := make([]string, len(results))
+	for i, result := range results {
+		words[i] = result.Word
+	}
+	return words
+}
@@ -7,64 +7,189 @@ import (
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	"sync"
+	"time"
 )
 
 type DBService interface {
 	Insert(string)
 	Get(string) int
+	DeleteWord(word string) int
+	Range(f func(word string, count int) bool)
+	RegisterLabels(labels map[string]string, words map[string]int)
+	RegisterDocument(doc *Document) error
+	RetractDocument(id string) (map[string]int, error)
+	GetDocument(id string) (map[string]int, bool)
+	Index() *InvertedIndex
+	Cooccurrences() *CooccurrenceCounter
+	Suggestions() *SuggestTrie
+	Similar() *BKTree
+	Synonyms() *SynonymTable
+	Labels() *LabelCounts
+	PutSynonym(synonym *Synonym) error
+	RemoveSynonym(alias string) (*Synonym, error)
+	BeginRequest(key string) (*StoredResponse, error)
+	CompleteRequest(key string, statusCode int, contentType string, body []byte)
+	ReleaseRequest(key string)
 	EncodeDatastore() ([]byte, error)
 	LoadDatastore(encodedData []byte) error
+	WALPosition() (written int64, committed int64)
 }
 
 type Database struct {
-	datastore   *sync.Map
-	snapshotter *Snapshotter
-	wal         *WriteAheadLog
-	logger      log.Logger
+	datastore    *sync.Map
+	documents    *DocumentRegistry
+	index        *InvertedIndex
+	cooccurrence *CooccurrenceCounter
+	suggest      *SuggestTrie
+	similar      *BKTree
+	synonyms     *SynonymTable
+	labels       *LabelCounts
+	idempotency  *IdempotencyStore
+	snapshotter  *Snapshotter
+	wal          *WriteAheadLog
+	logger       log.Logger
 }
 
-func NewDatabase(ctx context.Context, config *config.Config, isMaster bool) *Database {
-	var db *Database
-	var err error
+// full copy of the database, sent by the master to the new workers
+type datastoreDump struct {
+	Words        map[string]int            `json:"words"`
+	Documents    map[string]map[string]int `json:"documents,omitempty"`
+	Index        json.RawMessage           `json:"index,omitempty"`
+	Cooccurrence json.RawMessage           `json:"cooccurrence,omitempty"`
+	Synonyms     []Synonym                 `json:"synonyms,omitempty"`
+	Labels       json.RawMessage           `json:"labels,omitempty"`
+}
 
+// WAL payload for the deleted words
+type deleteWordRecord struct {
+	Word string `json:"word"`
+}
+
+func NewDatabase(ctx context.Context, config *config.Config, isMaster bool) *Database {
 	snapshotter := NewSnapshotter(ctx, &config.SnapshotOptions)
-	logger := ctx.Value(log.LoggerKey).(log.Logger)
+	idempotencyWindow := time.Duration(config.ServiceOptions.IdempotencyWindow) * time.Second
+
+	db := &Database{
+		documents:   NewDocumentRegistry(),
+		idempotency: NewIdempotencyStore(idempotencyWindow),
+		synonyms:    NewSynonymTable(),
+		labels:      NewLabelCounts(),
+		snapshotter: snapshotter,
+		logger:      ctx.Value(log.LoggerKey).(log.Logger),
+	}
+
+	if config.IndexOptions.Enabled {
+		db.index = NewInvertedIndex(&config.IndexOptions)
+		snapshotter.AddComponent(db.index)
+	}
+	if config.CooccurrenceOptions.Enabled {
+		db.cooccurrence = NewCooccurrenceCounter(&config.CooccurrenceOptions)
+		snapshotter.AddComponent(db.cooccurrence)
+	}
+	if config.SuggestOptions.Enabled {
+		db.suggest = NewSuggestTrie()
+	}
+	if config.SimilarOptions.Enabled {
+		db.similar = NewBKTree()
+	}
 
 	if !isMaster {
 		wal := NewWAL(ctx, &config.WALOptions)
 		if err := wal.Init(ctx); err != nil {
 			panic(fmt.Sprintf("Failed to initialize WAL: %v", err.Error()))
 		}
-		db := &Database{
-			datastore:   &sync.Map{},
-			wal:         wal,
-			snapshotter: snapshotter,
-			logger:      logger,
-		}
+		db.datastore = &sync.Map{}
+		db.wal = wal
 		go db.snapshotter.StartSnapshotRoutine(ctx, db)
 
 		return db
 	}
 
-	db, err = InitDBFromWal(ctx, &config.WALOptions)
+	// the WAL replay brings the data loaded from snapshots up to date
+	if err := snapshotter.LoadComponents(); err != nil {
+		db.logger.Warn("Cannot load snapshots, the data will be rebuilt from wal: ", err.Error())
+	}
+
+	restored, err := InitDBFromWal(ctx, &config.WALOptions, db.recordHandlers())
 	if err != nil {
 		panic(fmt.Sprintf("Cannot restore MasterDB from wal: %v", err.Error()))
 	}
+	db.datastore = restored.datastore
+	db.wal = restored.wal
+	if db.suggest != nil {
+		db.suggest.Load(db.datastore)
+	}
+	if db.similar != nil {
+		db.similar.Load(db.datastore)
+	}
 
-	db.snapshotter = snapshotter
-	db.logger = logger
+	// drop documents which are in the snapshot but not in the wal anymore
+	if db.index != nil {
+		db.index.Retain(func(id string) bool {
+			_, found := db.documents.Get(id)
+			return found
+		})
+	}
 
 	go db.snapshotter.StartSnapshotRoutine(ctx, db)
 
 	return db
 }
 
+// handlers used to replay the structured records from the WAL
+func (db *Database) recordHandlers() map[string]RecordHandler {
+	return map[string]RecordHandler{
+		docRecord:     db.replayDocument,
+		retractRecord: db.replayRetract,
+		idempotencyRecord: func(datastore *sync.Map, payload []byte) error {
+			return db.idempotency.replay(payload)
+		},
+		synonymRecord: func(datastore *sync.Map, payload []byte) error {
+			return db.synonyms.replay(payload)
+		},
+		labelsRecord: func(datastore *sync.Map, payload []byte) error {
+			return db.labels.replay(payload)
+		},
+		deleteRecord: db.replayDelete,
+	}
+}
+
+// adds delta to the count of word, words which are not counted anymore are removed
+func addCount(datastore *sync.Map, word string, delta int) int {
+	for {
+		val, found := datastore.Load(word)
+		if !found {
+			if delta <= 0 {
+				return 0
+			}
+			if _, loaded := datastore.LoadOrStore(word, delta); !loaded {
+				return delta
+			}
+			continue
+		}
+
+		count := val.(int) + delta
+		if count <= 0 {
+			if datastore.CompareAndDelete(word, val) {
+				return 0
+			}
+			continue
+		}
+		if datastore.CompareAndSwap(word, val, count) {
+			return count
+		}
+	}
+}
+
 func (db *Database) Insert(word string) {
 
 	// Update in-memory store
-	val, loaded := db.datastore.LoadOrStore(word, 1)
-	if loaded {
-		db.datastore.Store(word, val.(int)+1)
+	// only the new words are added to the tree
+	if count := addCount(db.datastore, word, 1); count == 1 && db.similar != nil {
+		db.similar.Add(word)
+	}
+	if db.suggest != nil {
+		db.suggest.Add(word, 1)
 	}
 	err := db.wal.Write([]byte(word + "\n"))
 	if err != nil {
@@ -72,6 +197,40 @@ func (db *Database) Insert(word string) {
 	}
 }
 
+// removes the count of word and returns it, the registered documents keep their words
+func (db *Database) DeleteWord(word string) int {
+	val, found := db.datastore.LoadAndDelete(word)
+	if !found {
+		return 0
+	}
+
+	count := val.(int)
+	if db.suggest != nil {
+		db.suggest.Add(word, -count)
+	}
+	if db.cooccurrence != nil {
+		db.cooccurrence.RemoveWord(word)
+	}
+	db.labels.RemoveWord(word)
+
+	err := db.wal.WriteRecord(deleteRecord, &deleteWordRecord{Word: word})
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+	return count
+}
+
+func (db *Database) replayDelete(datastore *sync.Map, payload []byte) error {
+	var record deleteWordRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("Cannot decode delete record: %v", err)
+	}
+
+	datastore.Delete(record.Word)
+	db.labels.RemoveWord(record.Word)
+	return nil
+}
+
 func (db *Database) Get(word string) int {
 	if val, found := db.datastore.Load(word); found {
 		return val.(int)
@@ -79,6 +238,157 @@ func (db *Database) Get(word string) int {
 	return 0
 }
 
+// byte offsets of the WAL, see WriteAheadLog.Position
+func (db *Database) WALPosition() (int64, int64) {
+	return db.wal.Position()
+}
+
+// calls f for every word until it returns false, the order is not specified
+func (db *Database) Range(f func(word string, count int) bool) {
+	db.datastore.Range(func(key, value interface{}) bool {
+		return f(key.(string), value.(int))
+	})
+}
+
+// adds the words to the counts of the label set, the words are counted by Insert
+func (db *Database) RegisterLabels(labels map[string]string, words map[string]int) {
+	if len(labels) == 0 {
+		return
+	}
+	db.labels.Add(labels, words)
+
+	err := db.wal.WriteRecord(labelsRecord, &labelRecord{Labels: labels, Words: words})
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+}
+
+// remembers the histogram of a document, the words are counted by Insert
+func (db *Database) RegisterDocument(doc *Document) error {
+	if err := db.documents.Add(doc.ID, doc.Words); err != nil {
+		return err
+	}
+	db.labels.AddDocument(doc.ID, doc.Labels, doc.Words)
+
+	record := &documentRecord{ID: doc.ID, Namespace: doc.Namespace, Words: doc.Words, Labels: doc.Labels}
+	if db.index != nil {
+		db.index.Add(doc)
+		// the order of the words is needed to rebuild the positions
+		if db.index.Positional(doc.Namespace) {
+			record.Tokens = doc.Tokens
+		}
+	}
+
+	err := db.wal.WriteRecord(docRecord, record)
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+	return nil
+}
+
+// subtracts the words of a document from the database
+func (db *Database) RetractDocument(id string) (map[string]int, error) {
+	words, err := db.documents.Remove(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for word, count := range words {
+		addCount(db.datastore, word, -count)
+		if db.suggest != nil {
+			db.suggest.Add(word, -count)
+		}
+	}
+	db.labels.RemoveDocument(id, words)
+	if db.index != nil {
+		db.index.Remove(id)
+	}
+	if db.cooccurrence != nil {
+		db.cooccurrence.RemoveDocument(id)
+	}
+
+	err = db.wal.WriteRecord(retractRecord, &documentRecord{ID: id})
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+	return words, nil
+}
+
+func (db *Database) GetDocument(id string) (map[string]int, bool) {
+	return db.documents.Get(id)
+}
+
+// returns nil when the index is disabled
+func (db *Database) Index() *InvertedIndex {
+	return db.index
+}
+
+// returns nil when co-occurrence counting is disabled
+func (db *Database) Cooccurrences() *CooccurrenceCounter {
+	return db.cooccurrence
+}
+
+// returns nil when the autocomplete is disabled
+func (db *Database) Suggestions() *SuggestTrie {
+	return db.suggest
+}
+
+// returns nil when the spelling suggestions are disabled
+func (db *Database) Similar() *BKTree {
+	return db.similar
+}
+
+func (db *Database) Synonyms() *SynonymTable {
+	return db.synonyms
+}
+
+func (db *Database) Labels() *LabelCounts {
+	return db.labels
+}
+
+func (db *Database) PutSynonym(synonym *Synonym) error {
+	if err := db.synonyms.Put(synonym); err != nil {
+		return err
+	}
+
+	err := db.wal.WriteRecord(synonymRecord, &synonymChange{Synonym: *synonym})
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+	return nil
+}
+
+func (db *Database) RemoveSynonym(alias string) (*Synonym, error) {
+	synonym, err := db.synonyms.Remove(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.wal.WriteRecord(synonymRecord, &synonymChange{Synonym: Synonym{Alias: alias}, Deleted: true})
+	if err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+	return synonym, nil
+}
+
+// returns the stored response of a request with the same key, or reserves the key
+func (db *Database) BeginRequest(key string) (*StoredResponse, error) {
+	return db.idempotency.Begin(key)
+}
+
+// remembers the response of the request, retries get the same response
+func (db *Database) CompleteRequest(key string, statusCode int, contentType string, body []byte) {
+	response := db.idempotency.Store(key, statusCode, contentType, body)
+
+	if err := db.wal.WriteRecord(idempotencyRecord, response); err != nil {
+		db.logger.Error("Cannot write into wal buffer: ", err.Error())
+	}
+}
+
+func (db *Database) ReleaseRequest(key string) {
+	db.idempotency.Release(key)
+}
+
 func (db *Database) SetDatastore(datastore *sync.Map) {
 	db.datastore = datastore
 }
@@ -91,8 +401,35 @@ func (db *Database) EncodeDatastore() ([]byte, error) {
 		return true
 	})
 
-	// Marshal the map into JSON
-	encodedData, err := json.Marshal(data)
+	dump := &datastoreDump{
+		Words:     data,
+		Documents: db.documents.All(),
+		Synonyms:  db.synonyms.All(),
+	}
+
+	encodedLabels, err := db.labels.Encode()
+	if err != nil {
+		return nil, err
+	}
+	dump.Labels = encodedLabels
+
+	if db.index != nil {
+		encodedIndex, err := db.index.EncodeSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		dump.Index = encodedIndex
+	}
+	if db.cooccurrence != nil {
+		encodedPairs, err := db.cooccurrence.EncodeSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		dump.Cooccurrence = encodedPairs
+	}
+
+	// Marshal the dump into JSON
+	encodedData, err := json.Marshal(dump)
 	if err != nil {
 		return nil, fmt.Errorf("Cannot encode map to JSON: %v", err)
 	}
@@ -101,19 +438,43 @@ func (db *Database) EncodeDatastore() ([]byte, error) {
 }
 
 func (db *Database) LoadDatastore(encodedData []byte) error {
-	data := make(map[string]int)
+	var dump datastoreDump
 
 	// Decode the JSON data from the byte slice
-	if err := json.Unmarshal(encodedData, &data); err != nil {
+	if err := json.Unmarshal(encodedData, &dump); err != nil {
 		return err
 	}
 
 	// Create and populate the sync.Map
 	snapshotMap := &sync.Map{}
-	for k, v := range data {
+	for k, v := range dump.Words {
 		snapshotMap.Store(k, v)
 	}
 
 	db.datastore = snapshotMap
+	db.documents.Load(dump.Documents)
+	db.synonyms.Load(dump.Synonyms)
+	if dump.Labels != nil {
+		if err := db.labels.Load(dump.Labels); err != nil {
+			return err
+		}
+	}
+	if db.suggest != nil {
+		db.suggest.Load(db.datastore)
+	}
+	if db.similar != nil {
+		db.similar.Load(db.datastore)
+	}
+
+	if db.index != nil && dump.Index != nil {
+		if err := db.index.LoadSnapshot(dump.Index); err != nil {
+			return err
+		}
+	}
+	if db.cooccurrence != nil && dump.Cooccurrence != nil {
+		if err := db.cooccurrence.LoadSnapshot(dump.Cooccurrence); err != nil {
+			return err
+		}
+	}
 	return nil
 }
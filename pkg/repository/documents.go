@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrDocumentExists   = errors.New("Document already exists")
+	ErrDocumentNotFound = errors.New("Document not found")
+)
+
+// DocumentRegistry keeps the word histogram of every document registered with an ID,
+// so the words of a document can be un-counted later
+type DocumentRegistry struct {
+	mutex     sync.RWMutex
+	documents map[string]map[string]int
+}
+
+// WAL payload for registered and retracted documents
+type documentRecord struct {
+	ID        string            `json:"id"`
+	Namespace string            `json:"namespace,omitempty"`
+	Words     map[string]int    `json:"words,omitempty"`
+	Tokens    []string          `json:"tokens,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func NewDocumentRegistry() *DocumentRegistry {
+	return &DocumentRegistry{
+		documents: make(map[string]map[string]int),
+	}
+}
+
+func (r *DocumentRegistry) Add(id string, words map[string]int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, found := r.documents[id]; found {
+		return ErrDocumentExists
+	}
+	r.documents[id] = words
+	return nil
+}
+
+func (r *DocumentRegistry) Remove(id string) (map[string]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	words, found := r.documents[id]
+	if !found {
+		return nil, ErrDocumentNotFound
+	}
+	delete(r.documents, id)
+	return words, nil
+}
+
+func (r *DocumentRegistry) Get(id string) (map[string]int, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	words, found := r.documents[id]
+	return words, found
+}
+
+// returns a copy of the registry, used when the database is encoded
+func (r *DocumentRegistry) All() map[string]map[string]int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	documents := make(map[string]map[string]int, len(r.documents))
+	for id, words := range r.documents {
+		documents[id] = words
+	}
+	return documents
+}
+
+func (r *DocumentRegistry) Load(documents map[string]map[string]int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if documents == nil {
+		documents = make(map[string]map[string]int)
+	}
+	r.documents = documents
+}
+
+// documents already loaded from the index snapshot are not indexed again
+func (db *Database) replayDocument(datastore *sync.Map, payload []byte) error {
+	var record documentRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("Cannot decode document record: %v", err)
+	}
+
+	if err := db.documents.Add(record.ID, record.Words); err != nil {
+		return err
+	}
+	db.labels.AddDocument(record.ID, record.Labels, record.Words)
+	if db.index != nil && !db.index.Contains(record.ID) {
+		db.index.Add(&Document{
+			ID:        record.ID,
+			Namespace: record.Namespace,
+			Words:     record.Words,
+			Tokens:    record.Tokens,
+		})
+	}
+	return nil
+}
+
+func (db *Database) replayRetract(datastore *sync.Map, payload []byte) error {
+	var record documentRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("Cannot decode retract record: %v", err)
+	}
+
+	words, err := db.documents.Remove(record.ID)
+	if err != nil {
+		return err
+	}
+	for word, count := range words {
+		addCount(datastore, word, -count)
+	}
+	db.labels.RemoveDocument(record.ID, words)
+	if db.index != nil {
+		db.index.Remove(record.ID)
+	}
+	// the documents retracted after the snapshot of the co-occurrences
+	if db.cooccurrence != nil {
+		db.cooccurrence.RemoveDocument(record.ID)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverDBWithDocuments(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-wal-docs-*.wal")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	// doc1 is retracted, doc2 stays registered
+	_, err = tmpFile.WriteString(`doc {"id":"doc1","words":{"apple":2,"banana":1}}
+apple
+apple
+banana
+doc {"id":"doc2","words":{"apple":1}}
+apple
+cherry
+retract {"id":"doc1"}
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	documents := NewDocumentRegistry()
+	database := &Database{documents: documents}
+	db, file, err := RecoverDBWithRecords(tmpFile.Name(), database.recordHandlers())
+	assert.NoError(t, err)
+	defer file.Close()
+
+	val, ok := db.Load("apple")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+
+	_, ok = db.Load("banana")
+	assert.False(t, ok)
+
+	val, ok = db.Load("cherry")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+
+	_, found := documents.Get("doc1")
+	assert.False(t, found)
+
+	words, found := documents.Get("doc2")
+	assert.True(t, found)
+	assert.Equal(t, map[string]int{"apple": 1}, words)
+}
+
+func TestRecoverDBUnknownRecord(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-wal-unknown-*.wal")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString("apple\nunknown {}\n")
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	_, _, err = RecoverDB(tmpFile.Name())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown WAL record type")
+}
+
+func TestRecoverDBWithDeletedWords(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-wal-delete-*.wal")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	// apple is counted again after being deleted
+	_, err = tmpFile.WriteString(`apple
+apple
+banana
+labels {"labels":{"source":"rss"},"words":{"banana":1}}
+delete {"word":"apple"}
+delete {"word":"banana"}
+apple
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	database := &Database{documents: NewDocumentRegistry(), labels: NewLabelCounts()}
+	db, file, err := RecoverDBWithRecords(tmpFile.Name(), database.recordHandlers())
+	assert.NoError(t, err)
+	defer file.Close()
+
+	val, ok := db.Load("apple")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+
+	_, ok = db.Load("banana")
+	assert.False(t, ok)
+
+	total, _ := database.labels.Count([]string{"banana"}, nil, nil)
+	assert.Equal(t, 0, total)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// used when the window is not configured
+const defaultIdempotencyWindow = time.Hour
+
+var ErrRequestInProgress = errors.New("A request with the same idempotency key is in progress")
+
+// IdempotencyStore remembers the responses of the requests processed with an
+// idempotency key, so retried requests are answered without applying them again
+type IdempotencyStore struct {
+	mutex     sync.Mutex
+	window    time.Duration
+	responses map[string]*StoredResponse
+	pending   map[string]struct{}
+	lastPurge time.Time
+}
+
+type StoredResponse struct {
+	Key         string    `json:"key"`
+	Expires     time.Time `json:"expires"`
+	StatusCode  int       `json:"statusCode"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        []byte    `json:"body"`
+}
+
+func NewIdempotencyStore(window time.Duration) *IdempotencyStore {
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+
+	return &IdempotencyStore{
+		window:    window,
+		responses: make(map[string]*StoredResponse),
+		pending:   make(map[string]struct{}),
+		lastPurge: time.Now(),
+	}
+}
+
+// returns the stored response of the key, or reserves the key for a new request.
+// The reservation must be completed with Store or Release.
+func (s *IdempotencyStore) Begin(key string) (*StoredResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if response, found := s.responses[key]; found && time.Now().Before(response.Expires) {
+		return response, nil
+	}
+	if _, found := s.pending[key]; found {
+		return nil, ErrRequestInProgress
+	}
+
+	s.pending[key] = struct{}{}
+	return nil, nil
+}
+
+func (s *IdempotencyStore) Store(key string, statusCode int, contentType string, body []byte) *StoredResponse {
+	response := &StoredResponse{
+		Key:         key,
+		Expires:     time.Now().Add(s.window),
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        body,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.pending, key)
+	s.put(response)
+	return response
+}
+
+// drops the reservation of a request which failed, so it can be retried
+func (s *IdempotencyStore) Release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.pending, key)
+}
+
+func (s *IdempotencyStore) put(response *StoredResponse) {
+	now := time.Now()
+	if !now.Before(response.Expires) {
+		return
+	}
+	s.responses[response.Key] = response
+
+	// expired keys are purged at most once per minute
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	for key, stored := range s.responses {
+		if !now.Before(stored.Expires) {
+			delete(s.responses, key)
+		}
+	}
+	s.lastPurge = now
+}
+
+func (s *IdempotencyStore) replay(payload []byte) error {
+	var response StoredResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return fmt.Errorf("Cannot decode idempotency record: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.put(&response)
+	return nil
+}
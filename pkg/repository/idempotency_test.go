@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute)
+
+	stored, err := store.Begin("key1")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// the first request is still running
+	_, err = store.Begin("key1")
+	assert.ErrorIs(t, err, ErrRequestInProgress)
+
+	store.Store("key1", 200, "application/json", []byte(`{"status":"Success"}`))
+
+	stored, err = store.Begin("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, stored.StatusCode)
+	assert.Equal(t, "application/json", stored.ContentType)
+	assert.Equal(t, []byte(`{"status":"Success"}`), stored.Body)
+
+	// released keys can be used again
+	_, err = store.Begin("key2")
+	assert.NoError(t, err)
+	store.Release("key2")
+	stored, err = store.Begin("key2")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestIdempotencyStoreReplay(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute)
+
+	expired, _ := json.Marshal(&StoredResponse{Key: "old", Expires: time.Now().Add(-time.Second), StatusCode: 200})
+	valid, _ := json.Marshal(&StoredResponse{Key: "new", Expires: time.Now().Add(time.Minute), StatusCode: 200})
+
+	assert.NoError(t, store.replay(expired))
+	assert.NoError(t, store.replay(valid))
+
+	stored, err := store.Begin("old")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	stored, err = store.Begin("new")
+	assert.NoError(t, err)
+	assert.NotNil(t, stored)
+}
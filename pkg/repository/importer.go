@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	config "mem-db/cmd/config"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Importer writes words directly into the data directory of a stopped node.
+// Words are appended to the WAL like Database.Insert does, and a snapshot of
+// the resulting database is written when the importer is closed.
+type Importer struct {
+	datastore   *sync.Map
+	file        *os.File
+	bufWriter   *bufio.Writer
+	snapshotter *Snapshotter
+	db          *Database
+}
+
+func NewImporter(config *config.Config) (*Importer, error) {
+	walFilePath := config.WALOptions.WalFilePath
+
+	if err := os.MkdirAll(filepath.Dir(walFilePath), 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create WAL directory: %v", err)
+	}
+	if err := os.MkdirAll(config.SnapshotOptions.DirPath, 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create snapshot directory: %v", err)
+	}
+
+	// create the WAL if it's a fresh data directory
+	file, err := os.OpenFile(walFilePath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open WALfile %s: %v", walFilePath, err)
+	}
+	file.Close()
+
+	// start from the existing data, so the snapshot matches the WAL
+	db := &Database{
+		documents:   NewDocumentRegistry(),
+		idempotency: NewIdempotencyStore(0),
+		synonyms:    NewSynonymTable(),
+		labels:      NewLabelCounts(),
+	}
+	datastore, file, err := RecoverDBWithRecords(walFilePath, db.recordHandlers())
+	if err != nil {
+		return nil, err
+	}
+	db.datastore = datastore
+
+	return &Importer{
+		datastore:   datastore,
+		file:        file,
+		bufWriter:   bufio.NewWriter(file),
+		snapshotter: &Snapshotter{dirPath: config.SnapshotOptions.DirPath},
+		db:          db,
+	}, nil
+}
+
+// the ingest synonyms of the node are applied to the words
+func (im *Importer) Insert(words []string) error {
+	for _, word := range im.db.synonyms.Canonicalize(words) {
+		val, loaded := im.datastore.LoadOrStore(word, 1)
+		if loaded {
+			im.datastore.Store(word, val.(int)+1)
+		}
+
+		if _, err := im.bufWriter.WriteString(word + "\n"); err != nil {
+			return fmt.Errorf("Cannot write into wal buffer: %v", err)
+		}
+	}
+	return nil
+}
+
+// flushes the WAL and writes the snapshot
+func (im *Importer) Close() error {
+	if err := im.bufWriter.Flush(); err != nil {
+		return fmt.Errorf("Cannot flush data: %v", err)
+	}
+	if err := im.file.Sync(); err != nil {
+		return fmt.Errorf("Cannot sync WAL file: %v", err)
+	}
+	if err := im.file.Close(); err != nil {
+		return fmt.Errorf("Cannot close WAL file: %v", err)
+	}
+
+	// same format as the snapshots of the node
+	encodedData, err := im.db.EncodeDatastore()
+	if err != nil {
+		return err
+	}
+
+	return im.snapshotter.SaveDataToFile(encodedData)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	config "mem-db/cmd/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImporterSnapshotLoadedByDatabase(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "importer_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	importConfig := &config.Config{}
+	importConfig.WALOptions.WalFilePath = filepath.Join(dir, "wal", "wal.log")
+	importConfig.SnapshotOptions.DirPath = filepath.Join(dir, "snapshots")
+
+	importer, err := NewImporter(importConfig)
+	if err != nil {
+		t.Fatalf("NewImporter failed: %v", err)
+	}
+	if err := importer.Insert([]string{"apple", "pear", "apple"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := importer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	files, err := os.ReadDir(importConfig.SnapshotOptions.DirPath)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file in directory, but found %d", len(files))
+	}
+	data, err := os.ReadFile(filepath.Join(importConfig.SnapshotOptions.DirPath, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot file: %v", err)
+	}
+
+	db := &Database{
+		documents: NewDocumentRegistry(),
+		synonyms:  NewSynonymTable(),
+		labels:    NewLabelCounts(),
+	}
+	if err := db.LoadDatastore(data); err != nil {
+		t.Fatalf("LoadDatastore failed: %v", err)
+	}
+
+	expected := map[string]int{"apple": 2, "pear": 1}
+	for word, count := range expected {
+		value, ok := db.datastore.Load(word)
+		if !ok || value.(int) != count {
+			t.Errorf("Expected %s to be counted %d times, got %v", word, count, value)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	config "mem-db/cmd/config"
+	"sort"
+	"sync"
+)
+
+const (
+	TFIDF = "tfidf"
+	BM25  = "bm25"
+
+	// standard BM25 parameters
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	DefaultNamespace = "default"
+)
+
+// InvertedIndex maps every word to the documents it appears in.
+// It's maintained for the documents registered with an id, every document
+// belongs to a namespace and the namespaces are searched separately.
+type InvertedIndex struct {
+	mutex      sync.RWMutex
+	options    map[string]config.NamespaceOptions
+	namespaces map[string]*namespaceIndex
+	documents  map[string]*indexedDocument
+}
+
+type namespaceIndex struct {
+	documents   map[string]*indexedDocument
+	postings    map[string]map[string]int // word -> document -> term frequency
+	totalLength int
+}
+
+type indexedDocument struct {
+	Namespace string         `json:"namespace,omitempty"`
+	Words     map[string]int `json:"words"`
+	Length    int            `json:"length"`
+	// positions of every word, only kept by the positional namespaces
+	Positions map[string][]int `json:"positions,omitempty"`
+}
+
+// document registered with an id
+type Document struct {
+	ID        string
+	Namespace string
+	Words     map[string]int
+	// the words in their original order, needed by the positional namespaces
+	Tokens []string
+	Labels map[string]string
+}
+
+type Posting struct {
+	DocumentID    string `json:"documentId"`
+	TermFrequency int    `json:"termFrequency"`
+}
+
+type SearchResult struct {
+	DocumentID string  `json:"documentId"`
+	Score      float64 `json:"score"`
+}
+
+type PhraseResult struct {
+	DocumentID  string `json:"documentId"`
+	Occurrences int    `json:"occurrences"`
+}
+
+type indexSnapshot struct {
+	Documents map[string]*indexedDocument `json:"documents"`
+}
+
+func NewInvertedIndex(options *config.IndexOptions) *InvertedIndex {
+	return &InvertedIndex{
+		options:    options.Namespaces,
+		namespaces: make(map[string]*namespaceIndex),
+		documents:  make(map[string]*indexedDocument),
+	}
+}
+
+// positions are disabled for the namespaces which are not configured
+func (idx *InvertedIndex) Positional(namespace string) bool {
+	return idx.options[namespaceOrDefault(namespace)].Positions
+}
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+func (idx *InvertedIndex) Add(doc *Document) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	indexed := &indexedDocument{
+		Namespace: namespaceOrDefault(doc.Namespace),
+		Words:     doc.Words,
+	}
+	if idx.Positional(indexed.Namespace) && doc.Tokens != nil {
+		indexed.Positions = make(map[string][]int)
+		for position, word := range doc.Tokens {
+			indexed.Positions[word] = append(indexed.Positions[word], position)
+		}
+	}
+
+	idx.add(doc.ID, indexed)
+}
+
+func (idx *InvertedIndex) add(id string, doc *indexedDocument) {
+	if _, found := idx.documents[id]; found {
+		idx.remove(id)
+	}
+
+	ns := idx.namespaces[doc.Namespace]
+	if ns == nil {
+		ns = &namespaceIndex{
+			documents: make(map[string]*indexedDocument),
+			postings:  make(map[string]map[string]int),
+		}
+		idx.namespaces[doc.Namespace] = ns
+	}
+
+	doc.Length = 0
+	for word, tf := range doc.Words {
+		if ns.postings[word] == nil {
+			ns.postings[word] = make(map[string]int)
+		}
+		ns.postings[word][id] = tf
+		doc.Length += tf
+	}
+
+	ns.documents[id] = doc
+	ns.totalLength += doc.Length
+	idx.documents[id] = doc
+}
+
+func (idx *InvertedIndex) Remove(id string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *InvertedIndex) remove(id string) {
+	doc, found := idx.documents[id]
+	if !found {
+		return
+	}
+
+	ns := idx.namespaces[doc.Namespace]
+	for word := range doc.Words {
+		delete(ns.postings[word], id)
+		if len(ns.postings[word]) == 0 {
+			delete(ns.postings, word)
+		}
+	}
+
+	ns.totalLength -= doc.Length
+	delete(ns.documents, id)
+	if len(ns.documents) == 0 {
+		delete(idx.namespaces, doc.Namespace)
+	}
+	delete(idx.documents, id)
+}
+
+func (idx *InvertedIndex) Contains(id string) bool {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	_, found := idx.documents[id]
+	return found
+}
+
+// keeps only the documents accepted by keep
+func (idx *InvertedIndex) Retain(keep func(id string) bool) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	for id := range idx.documents {
+		if !keep(id) {
+			idx.remove(id)
+		}
+	}
+}
+
+// returns the documents of the namespace the word appears in, sorted by document id
+func (idx *InvertedIndex) Postings(namespace, word string) []Posting {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	ns := idx.namespaces[namespaceOrDefault(namespace)]
+	if ns == nil {
+		return []Posting{}
+	}
+
+	postings := make([]Posting, 0, len(ns.postings[word]))
+	for id, tf := range ns.postings[word] {
+		postings = append(postings, Posting{DocumentID: id, TermFrequency: tf})
+	}
+	sort.Slice(postings, func(i, j int) bool {
+		return postings[i].DocumentID < postings[j].DocumentID
+	})
+	return postings
+}
+
+// ranks the documents of the namespace containing at least one of the terms
+func (idx *InvertedIndex) Search(namespace string, terms []string, scoring string, limit int) ([]SearchResult, error) {
+	if scoring == "" {
+		scoring = BM25
+	}
+	if scoring != TFIDF && scoring != BM25 {
+		return nil, fmt.Errorf("Unknown scoring function %s", scoring)
+	}
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	ns := idx.namespaces[namespaceOrDefault(namespace)]
+	if ns == nil {
+		return []SearchResult{}, nil
+	}
+
+	n := float64(len(ns.documents))
+	avgLength := float64(ns.totalLength) / n
+
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		postings := ns.postings[term]
+		df := float64(len(postings))
+		if df == 0 {
+			continue
+		}
+
+		for id, tf := range postings {
+			length := float64(ns.documents[id].Length)
+			if scoring == TFIDF {
+				scores[id] += float64(tf) / length * math.Log(1+n/df)
+				continue
+			}
+
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := float64(tf) + bm25K1*(1-bm25B+bm25B*length/avgLength)
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / norm
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, SearchResult{DocumentID: id, Score: score})
+	}
+
+	// best score first, ties broken by document id to keep the order deterministic
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].DocumentID < results[j].DocumentID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// returns the documents where the words of the phrase appear consecutively
+// and the number of occurrences of the phrase in the whole namespace
+func (idx *InvertedIndex) PhraseSearch(namespace string, phrase []string, limit int) ([]PhraseResult, int, error) {
+	namespace = namespaceOrDefault(namespace)
+	if !idx.Positional(namespace) {
+		return nil, 0, fmt.Errorf("Positions are disabled for namespace %s", namespace)
+	}
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	ns := idx.namespaces[namespace]
+	if ns == nil || len(phrase) == 0 {
+		return []PhraseResult{}, 0, nil
+	}
+
+	results := []PhraseResult{}
+	total := 0
+	// only the documents containing the first word can match
+	for id := range ns.postings[phrase[0]] {
+		occurrences := countPhrase(ns.documents[id].Positions, phrase)
+		if occurrences > 0 {
+			results = append(results, PhraseResult{DocumentID: id, Occurrences: occurrences})
+			total += occurrences
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Occurrences != results[j].Occurrences {
+			return results[i].Occurrences > results[j].Occurrences
+		}
+		return results[i].DocumentID < results[j].DocumentID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, total, nil
+}
+
+func countPhrase(positions map[string][]int, phrase []string) int {
+	occurrences := 0
+	for _, start := range positions[phrase[0]] {
+		matched := true
+		for offset, word := range phrase[1:] {
+			if !containsPosition(positions[word], start+offset+1) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			occurrences++
+		}
+	}
+	return occurrences
+}
+
+// positions are sorted, they are appended in the order of the words
+func containsPosition(positions []int, position int) bool {
+	i := sort.SearchInts(positions, position)
+	return i < len(positions) && positions[i] == position
+}
+
+func (idx *InvertedIndex) SnapshotName() string {
+	return "index"
+}
+
+func (idx *InvertedIndex) EncodeSnapshot() ([]byte, error) {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	encodedData, err := json.Marshal(&indexSnapshot{Documents: idx.documents})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode inverted index: %v", err)
+	}
+	return encodedData, nil
+}
+
+func (idx *InvertedIndex) LoadSnapshot(encodedData []byte) error {
+	var snapshot indexSnapshot
+	if err := json.Unmarshal(encodedData, &snapshot); err != nil {
+		return fmt.Errorf("Cannot decode inverted index: %v", err)
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.namespaces = make(map[string]*namespaceIndex)
+	idx.documents = make(map[string]*indexedDocument)
+	for id, doc := range snapshot.Documents {
+		doc.Namespace = namespaceOrDefault(doc.Namespace)
+		idx.add(id, doc)
+	}
+	return nil
+}
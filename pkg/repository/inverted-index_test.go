@@ -0,0 +1,91 @@
+package repository
+
+import (
+	config "mem-db/cmd/config"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvertedIndexSearch(t *testing.T) {
+	index := NewInvertedIndex(&config.IndexOptions{Enabled: true})
+	index.Add(&Document{ID: "doc1", Words: map[string]int{"go": 3, "database": 1}})
+	index.Add(&Document{ID: "doc2", Words: map[string]int{"go": 1, "memory": 4}})
+	index.Add(&Document{ID: "doc3", Words: map[string]int{"rust": 2}})
+	index.Add(&Document{ID: "doc4", Namespace: "other", Words: map[string]int{"go": 1}})
+
+	assert.Equal(t, []Posting{{"doc1", 3}, {"doc2", 1}}, index.Postings("", "go"))
+
+	for _, scoring := range []string{TFIDF, BM25} {
+		results, err := index.Search("", []string{"go", "database"}, scoring, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "doc1", results[0].DocumentID)
+		assert.Equal(t, "doc2", results[1].DocumentID)
+	}
+
+	_, err := index.Search("", []string{"go"}, "unknown", 10)
+	assert.Error(t, err)
+
+	index.Remove("doc1")
+	assert.Equal(t, []Posting{{"doc2", 1}}, index.Postings("", "go"))
+	assert.Empty(t, index.Postings("", "database"))
+	assert.Equal(t, []Posting{{"doc4", 1}}, index.Postings("other", "go"))
+}
+
+func TestInvertedIndexPhraseSearch(t *testing.T) {
+	index := NewInvertedIndex(&config.IndexOptions{
+		Enabled:    true,
+		Namespaces: map[string]config.NamespaceOptions{"news": {Positions: true}},
+	})
+
+	addTokens := func(id, namespace string, tokens ...string) {
+		words := make(map[string]int)
+		for _, token := range tokens {
+			words[token]++
+		}
+		index.Add(&Document{ID: id, Namespace: namespace, Words: words, Tokens: tokens})
+	}
+	addTokens("doc1", "news", "the", "quick", "brown", "fox", "and", "the", "quick", "brown", "fox")
+	addTokens("doc2", "news", "brown", "quick", "fox")
+	addTokens("doc3", "news", "a", "quick", "brown", "fox")
+
+	results, total, err := index.PhraseSearch("news", []string{"quick", "brown", "fox"}, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []PhraseResult{{"doc1", 2}, {"doc3", 1}}, results)
+
+	_, _, err = index.PhraseSearch("", []string{"quick", "brown"}, 10)
+	assert.Error(t, err)
+}
+
+func TestInvertedIndexSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp("", "snapshot_test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	options := &config.IndexOptions{
+		Enabled:    true,
+		Namespaces: map[string]config.NamespaceOptions{"news": {Positions: true}},
+	}
+	index := NewInvertedIndex(options)
+	index.Add(&Document{ID: "doc1", Words: map[string]int{"go": 3, "database": 1}})
+	index.Add(&Document{ID: "doc2", Namespace: "news", Words: map[string]int{"go": 1, "fast": 1}, Tokens: []string{"go", "fast"}})
+
+	snapshotter := &Snapshotter{dirPath: dir}
+	snapshotter.AddComponent(index)
+	assert.NoError(t, snapshotter.saveComponents())
+
+	restored := NewInvertedIndex(options)
+	snapshotter = &Snapshotter{dirPath: dir}
+	snapshotter.AddComponent(restored)
+	assert.NoError(t, snapshotter.LoadComponents())
+
+	assert.True(t, restored.Contains("doc1"))
+	assert.Equal(t, []Posting{{"doc1", 3}}, restored.Postings("", "go"))
+
+	_, total, err := restored.PhraseSearch("news", []string{"go", "fast"}, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	maxLabels           = 16
+	maxLabelValueLength = 128
+)
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LabelCounts keeps the counts of the words registered with labels, like source=twitter,
+// for every distinct set of labels. The totals are still kept by the datastore,
+// the words registered without labels are only counted there.
+type LabelCounts struct {
+	mutex sync.RWMutex
+	// labels of every label set by its key
+	sets map[string]map[string]string
+	// word -> label set key -> count
+	words map[string]map[string]int
+	// label set key of the labelled documents, used when they are retracted
+	documents map[string]string
+}
+
+// occurrences of a word for one value of the grouped labels
+type LabelGroup struct {
+	Labels      map[string]string `json:"labels"`
+	Occurrences int               `json:"occurrences"`
+}
+
+// WAL payload for the words registered with labels
+type labelRecord struct {
+	Labels map[string]string `json:"labels"`
+	Words  map[string]int    `json:"words"`
+}
+
+type labelDump struct {
+	Sets      []labelRecord                `json:"sets"`
+	Documents map[string]map[string]string `json:"documents,omitempty"`
+}
+
+func NewLabelCounts() *LabelCounts {
+	return &LabelCounts{
+		sets:      make(map[string]map[string]string),
+		words:     make(map[string]map[string]int),
+		documents: make(map[string]string),
+	}
+}
+
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("Text cannot have more than %d labels", maxLabels)
+	}
+	for name, value := range labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("Invalid label name %q", name)
+		}
+		if value == "" || len(value) > maxLabelValueLength {
+			return fmt.Errorf("Value of label %s must have between 1 and %d characters", name, maxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// the JSON encoding sorts the names, so equal label sets have the same key
+func labelSetKey(labels map[string]string) string {
+	key, _ := json.Marshal(labels)
+	return string(key)
+}
+
+// adds the words to the counts of the label set, nothing is kept without labels.
+// A nil LabelCounts keeps nothing.
+func (c *LabelCounts) Add(labels map[string]string, words map[string]int) {
+	if c == nil || len(labels) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.add(labelSetKey(labels), labels, words, 1)
+}
+
+// same as Add, the label set is remembered to subtract the words when the document is retracted
+func (c *LabelCounts) AddDocument(id string, labels map[string]string, words map[string]int) {
+	if c == nil || len(labels) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := labelSetKey(labels)
+	c.documents[id] = key
+	c.add(key, labels, words, 1)
+}
+
+func (c *LabelCounts) RemoveDocument(id string, words map[string]int) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, found := c.documents[id]
+	if !found {
+		return
+	}
+	delete(c.documents, id)
+	c.add(key, c.sets[key], words, -1)
+}
+
+// drops the labelled counts of a deleted word
+func (c *LabelCounts) RemoveWord(word string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.words, word)
+}
+
+func (c *LabelCounts) add(key string, labels map[string]string, words map[string]int, sign int) {
+	if _, found := c.sets[key]; !found {
+		copied := make(map[string]string, len(labels))
+		for name, value := range labels {
+			copied[name] = value
+		}
+		c.sets[key] = copied
+	}
+
+	for word, count := range words {
+		counts := c.words[word]
+		if counts == nil {
+			counts = make(map[string]int)
+			c.words[word] = counts
+		}
+
+		counts[key] += sign * count
+		if counts[key] <= 0 {
+			delete(counts, key)
+			if len(counts) == 0 {
+				delete(c.words, word)
+			}
+		}
+	}
+}
+
+// returns the occurrences of the words in the label sets matching filter.
+// With groupBy the occurrences are also split by the values of these labels,
+// the label sets without one of them are not counted.
+func (c *LabelCounts) Count(words []string, filter map[string]string, groupBy []string) (int, []LabelGroup) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	total := 0
+	groups := make(map[string]*LabelGroup)
+	for _, word := range words {
+		for key, count := range c.words[word] {
+			labels := c.sets[key]
+			if !matchLabels(labels, filter, groupBy) {
+				continue
+			}
+			total += count
+			if len(groupBy) == 0 {
+				continue
+			}
+
+			grouped := make(map[string]string, len(groupBy))
+			for _, name := range groupBy {
+				grouped[name] = labels[name]
+			}
+			groupKey := labelSetKey(grouped)
+			if group, found := groups[groupKey]; found {
+				group.Occurrences += count
+			} else {
+				groups[groupKey] = &LabelGroup{Labels: grouped, Occurrences: count}
+			}
+		}
+	}
+
+	if len(groupBy) == 0 {
+		return total, nil
+	}
+
+	result := make([]LabelGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Occurrences != result[j].Occurrences {
+			return result[i].Occurrences > result[j].Occurrences
+		}
+		return labelSetKey(result[i].Labels) < labelSetKey(result[j].Labels)
+	})
+	return total, result
+}
+
+func matchLabels(labels, filter map[string]string, groupBy []string) bool {
+	for name, value := range filter {
+		if labels[name] != value {
+			return false
+		}
+	}
+	for _, name := range groupBy {
+		if _, found := labels[name]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *LabelCounts) Encode() ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	records := make(map[string]*labelRecord, len(c.sets))
+	for word, counts := range c.words {
+		for key, count := range counts {
+			record := records[key]
+			if record == nil {
+				record = &labelRecord{Labels: c.sets[key], Words: make(map[string]int)}
+				records[key] = record
+			}
+			record.Words[word] = count
+		}
+	}
+
+	dump := &labelDump{Documents: make(map[string]map[string]string, len(c.documents))}
+	for _, record := range records {
+		dump.Sets = append(dump.Sets, *record)
+	}
+	for id, key := range c.documents {
+		dump.Documents[id] = c.sets[key]
+	}
+
+	data, err := json.Marshal(dump)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode label counts: %v", err)
+	}
+	return data, nil
+}
+
+func (c *LabelCounts) Load(encodedData []byte) error {
+	var dump labelDump
+	if err := json.Unmarshal(encodedData, &dump); err != nil {
+		return fmt.Errorf("Cannot decode label counts: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sets = make(map[string]map[string]string)
+	c.words = make(map[string]map[string]int)
+	c.documents = make(map[string]string)
+	for _, record := range dump.Sets {
+		if len(record.Labels) == 0 {
+			return errors.New("Cannot load label counts: label set is empty")
+		}
+		c.add(labelSetKey(record.Labels), record.Labels, record.Words, 1)
+	}
+	for id, labels := range dump.Documents {
+		key := labelSetKey(labels)
+		if _, found := c.sets[key]; !found {
+			c.sets[key] = labels
+		}
+		c.documents[id] = key
+	}
+	return nil
+}
+
+func (c *LabelCounts) replay(payload []byte) error {
+	var record labelRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("Cannot decode labels record: %v", err)
+	}
+
+	c.Add(record.Labels, record.Words)
+	return nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelCountsGroupBy(t *testing.T) {
+	counts := NewLabelCounts()
+	counts.Add(map[string]string{"source": "twitter", "lang": "en"}, map[string]int{"go": 2})
+	counts.Add(map[string]string{"lang": "en", "source": "twitter"}, map[string]int{"go": 1})
+	counts.Add(map[string]string{"source": "rss", "lang": "de"}, map[string]int{"go": 4})
+	counts.Add(map[string]string{"lang": "en"}, map[string]int{"go": 5})
+
+	total, groups := counts.Count([]string{"go"}, nil, nil)
+	assert.Equal(t, 12, total)
+	assert.Nil(t, groups)
+
+	total, groups = counts.Count([]string{"go"}, nil, []string{"source"})
+	assert.Equal(t, 7, total)
+	assert.Equal(t, []LabelGroup{
+		{Labels: map[string]string{"source": "rss"}, Occurrences: 4},
+		{Labels: map[string]string{"source": "twitter"}, Occurrences: 3},
+	}, groups)
+
+	total, _ = counts.Count([]string{"go"}, map[string]string{"lang": "en"}, nil)
+	assert.Equal(t, 8, total)
+	total, _ = counts.Count([]string{"rust"}, map[string]string{"lang": "en"}, nil)
+	assert.Equal(t, 0, total)
+}
+
+func TestLabelCountsDocuments(t *testing.T) {
+	counts := NewLabelCounts()
+	labels := map[string]string{"source": "twitter"}
+	counts.AddDocument("doc-1", labels, map[string]int{"go": 2, "rust": 1})
+	counts.Add(labels, map[string]int{"go": 1})
+
+	encoded, err := counts.Encode()
+	assert.NoError(t, err)
+	loaded := NewLabelCounts()
+	assert.NoError(t, loaded.Load(encoded))
+
+	loaded.RemoveDocument("doc-1", map[string]int{"go": 2, "rust": 1})
+	total, _ := loaded.Count([]string{"go"}, labels, nil)
+	assert.Equal(t, 1, total)
+	total, _ = loaded.Count([]string{"rust"}, labels, nil)
+	assert.Equal(t, 0, total)
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, ValidateLabels(map[string]string{"source": "twitter", "lang_code": "en-US"}))
+	assert.Error(t, ValidateLabels(map[string]string{"source=x": "twitter"}))
+	assert.Error(t, ValidateLabels(map[string]string{"source": ""}))
+}
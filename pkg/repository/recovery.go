@@ -8,7 +8,19 @@ import (
 	"sync"
 )
 
+// records may hold whole documents, so they can be longer than the default scanner buffer
+const maxRecordSize = 64 * 1024 * 1024
+
+// applies a structured WAL record to the recovered datastore
+type RecordHandler func(datastore *sync.Map, payload []byte) error
+
 func RecoverDB(walFilePath string) (*sync.Map, *os.File, error) {
+	return RecoverDBWithRecords(walFilePath, nil)
+}
+
+// replays the WAL: plain lines are counted as words, structured records
+// are passed to the handler registered for their type
+func RecoverDBWithRecords(walFilePath string, handlers map[string]RecordHandler) (*sync.Map, *os.File, error) {
 
 	file, err := os.OpenFile(walFilePath, os.O_RDWR, 0666)
 	if err != nil {
@@ -19,14 +31,28 @@ func RecoverDB(walFilePath string) (*sync.Map, *os.File, error) {
 	db := &sync.Map{}
 
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
 	for scanner.Scan() {
-		word := strings.TrimSpace(scanner.Text())
-		if word != "" {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		recordType, payload, isRecord := strings.Cut(line, " ")
+		if !isRecord {
 			// Increment the count for this word in the database.
-			val, loaded := db.LoadOrStore(word, 1)
-			if loaded {
-				db.Store(word, val.(int)+1)
-			}
+			addCount(db, line, 1)
+			continue
+		}
+
+		handler, found := handlers[recordType]
+		if !found {
+			file.Close()
+			return nil, nil, fmt.Errorf("Unknown WAL record type: %s", recordType)
+		}
+		if err := handler(db, []byte(payload)); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("Cannot replay WAL record %s: %v", recordType, err)
 		}
 	}
 
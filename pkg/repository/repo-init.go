@@ -10,7 +10,7 @@ import (
 	"sync"
 )
 
-func InitDBFromWal(ctx context.Context, options *config.WALOptions) (*Database, error) {
+func InitDBFromWal(ctx context.Context, options *config.WALOptions, handlers map[string]RecordHandler) (*Database, error) {
 	walFilePath := options.WalFilePath
 
 	// check if the path exists
@@ -31,7 +31,7 @@ func InitDBFromWal(ctx context.Context, options *config.WALOptions) (*Database,
 	if options.Restore && err == nil {
 
 		// retrieve data and move pointer to the end of the file
-		datastore, file, err := RecoverDB(walFilePath)
+		datastore, file, err := RecoverDBWithRecords(walFilePath, handlers)
 		if err != nil {
 			return nil, fmt.Errorf("Cannot recover database: %v", err.Error())
 		}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+)
+
+// longest edit distance accepted by the similar words queries
+const MaxEditDistance = 2
+
+// BKTree finds the known words within a small edit distance of a term.
+// Words are never removed from the tree, the words which are not counted
+// anymore are skipped by the searches and dropped when the tree is rebuilt on startup.
+type BKTree struct {
+	mutex sync.RWMutex
+	root  *bkNode
+}
+
+type bkNode struct {
+	word     string
+	children []bkEdge
+}
+
+type bkEdge struct {
+	distance int
+	node     *bkNode
+}
+
+type SimilarWord struct {
+	Word        string `json:"word"`
+	Distance    int    `json:"distance"`
+	Occurrences int    `json:"occurrences"`
+}
+
+func NewBKTree() *BKTree {
+	return &BKTree{}
+}
+
+func (t *BKTree) Add(word string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.add(word)
+}
+
+func (t *BKTree) add(word string) {
+	if t.root == nil {
+		t.root = &bkNode{word: word}
+		return
+	}
+
+	node := t.root
+	for {
+		distance := editDistance(word, node.word)
+		if distance == 0 {
+			return
+		}
+
+		next := node.child(distance)
+		if next == nil {
+			node.children = append(node.children, bkEdge{distance: distance, node: &bkNode{word: word}})
+			return
+		}
+		node = next
+	}
+}
+
+func (n *bkNode) child(distance int) *bkNode {
+	for _, edge := range n.children {
+		if edge.distance == distance {
+			return edge.node
+		}
+	}
+	return nil
+}
+
+// returns the counted words within maxDistance of term, the closest and most frequent first
+func (t *BKTree) Search(term string, maxDistance int, count func(word string) int, limit int) []SimilarWord {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	results := []SimilarWord{}
+	if t.root == nil {
+		return results
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := editDistance(term, node.word)
+		if distance > 0 && distance <= maxDistance {
+			if occurrences := count(node.word); occurrences > 0 {
+				results = append(results, SimilarWord{Word: node.word, Distance: distance, Occurrences: occurrences})
+			}
+		}
+
+		// by the triangle inequality only these children can be close enough
+		for _, edge := range node.children {
+			if edge.distance >= distance-maxDistance && edge.distance <= distance+maxDistance {
+				stack = append(stack, edge.node)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		if results[i].Occurrences != results[j].Occurrences {
+			return results[i].Occurrences > results[j].Occurrences
+		}
+		return results[i].Word < results[j].Word
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// replaces the content of the tree with the words of the datastore
+func (t *BKTree) Load(datastore *sync.Map) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.root = nil
+	datastore.Range(func(key, value interface{}) bool {
+		t.add(key.(string))
+		return true
+	})
+}
+
+// Levenshtein distance between the characters of a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
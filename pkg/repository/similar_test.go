@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("data", "data"))
+	assert.Equal(t, 1, editDistance("data", "date"))
+	assert.Equal(t, 1, editDistance("databse", "database"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 1, editDistance("café", "cafe"))
+}
+
+func TestBKTreeSearch(t *testing.T) {
+	counts := map[string]int{"data": 10, "date": 3, "dote": 7, "database": 4, "delta": 1}
+	count := func(word string) int { return counts[word] }
+
+	tree := NewBKTree()
+	for word := range counts {
+		tree.Add(word)
+	}
+	tree.Add("data")
+
+	results := tree.Search("data", 2, count, 0)
+	assert.Equal(t, []SimilarWord{
+		{Word: "date", Distance: 1, Occurrences: 3},
+		{Word: "dote", Distance: 2, Occurrences: 7},
+		{Word: "delta", Distance: 2, Occurrences: 1},
+	}, results)
+
+	assert.Equal(t, []SimilarWord{{Word: "date", Distance: 1, Occurrences: 3}}, tree.Search("data", 1, count, 0))
+	assert.Len(t, tree.Search("dta", 2, count, 2), 2)
+
+	// words which are not counted anymore are skipped
+	delete(counts, "date")
+	assert.Equal(t, "dote", tree.Search("data", 2, count, 1)[0].Word)
+}
+
+func TestBKTreeLoad(t *testing.T) {
+	datastore := &sync.Map{}
+	datastore.Store("apple", 2)
+	datastore.Store("apply", 1)
+
+	tree := NewBKTree()
+	tree.Add("stale")
+	tree.Load(datastore)
+
+	count := func(word string) int { return 1 }
+	assert.Equal(t, []SimilarWord{{Word: "apple", Distance: 1, Occurrences: 1}, {Word: "apply", Distance: 1, Occurrences: 1}}, tree.Search("appla", 1, count, 0))
+	assert.Empty(t, tree.Search("stale", 2, count, 0))
+}
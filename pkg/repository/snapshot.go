@@ -7,14 +7,24 @@ import (
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// data persisted in its own file next to the words snapshot, like the inverted index
+type SnapshotComponent interface {
+	SnapshotName() string
+	EncodeSnapshot() ([]byte, error)
+	LoadSnapshot(encodedData []byte) error
+}
+
 type Snapshotter struct {
-	dirPath   string
-	syncTimer *time.Ticker
-	logger    log.Logger
+	dirPath    string
+	syncTimer  *time.Ticker
+	logger     log.Logger
+	components []SnapshotComponent
 }
 
 func NewSnapshotter(ctx context.Context, options *config.SnapshotOptions) *Snapshotter {
@@ -29,6 +39,14 @@ func generateSnapshotFilename() string {
 	return fmt.Sprintf("snapshot_%s.json", time.Now().Format("20060102_150405"))
 }
 
+func generateComponentFilename(name string) string {
+	return fmt.Sprintf("%s_%s.json", name, time.Now().Format("20060102_150405"))
+}
+
+func (s *Snapshotter) AddComponent(component SnapshotComponent) {
+	s.components = append(s.components, component)
+}
+
 func (s *Snapshotter) SaveDataToFile(encodedData []byte) error {
 
 	snapshotPath := fmt.Sprintf("%s/%s", s.dirPath, generateSnapshotFilename())
@@ -68,6 +86,60 @@ func (s *Snapshotter) CreateSnapshot(db *Database) error {
 		return fmt.Errorf("Cannot encode map to json: %v", err)
 	}
 
+	return s.saveComponents()
+}
+
+func (s *Snapshotter) saveComponents() error {
+	for _, component := range s.components {
+		encodedData, err := component.EncodeSnapshot()
+		if err != nil {
+			return err
+		}
+
+		componentPath := fmt.Sprintf("%s/%s", s.dirPath, generateComponentFilename(component.SnapshotName()))
+		if err := os.WriteFile(componentPath, encodedData, 0666); err != nil {
+			return fmt.Errorf("Cannot write %s snapshot: %v", component.SnapshotName(), err)
+		}
+	}
+
+	return nil
+}
+
+// loads the latest snapshot of every component, components without snapshots are left empty
+func (s *Snapshotter) LoadComponents() error {
+	entries, err := os.ReadDir(s.dirPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Cannot read snapshot directory: %v", err)
+	}
+
+	// the timestamp format keeps the files sorted by creation time
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, component := range s.components {
+		latest := ""
+		for _, name := range names {
+			if strings.HasPrefix(name, component.SnapshotName()+"_") && strings.HasSuffix(name, ".json") {
+				latest = name
+			}
+		}
+
+		if latest == "" {
+			continue
+		}
+
+		encodedData, err := os.ReadFile(fmt.Sprintf("%s/%s", s.dirPath, latest))
+		if err != nil {
+			return fmt.Errorf("Cannot read snapshot %s: %v", latest, err)
+		}
+		if err := component.LoadSnapshot(encodedData); err != nil {
+			return fmt.Errorf("Cannot load snapshot %s: %v", latest, err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// suggestions returned at most for a prefix
+	MaxSuggestions = 50
+	// nodes with more words in their subtree cache their most frequent words,
+	// smaller subtrees are walked at query time
+	suggestCacheThreshold = 64
+)
+
+// SuggestTrie is a radix tree of the counted words, ranked by frequency.
+// It's kept in memory only, it's rebuilt from the datastore on startup.
+type SuggestTrie struct {
+	mutex sync.Mutex
+	root  *suggestNode
+}
+
+type suggestNode struct {
+	// part of the word on the edge from the parent
+	label string
+	// sorted by the first byte of their label
+	children []*suggestNode
+	word     string
+	count    int
+	// number of counted words in the subtree
+	size int
+	// most frequent words of the subtree, only for the nodes bigger than the threshold
+	top []*suggestNode
+}
+
+type Suggestion struct {
+	Word        string `json:"word"`
+	Occurrences int    `json:"occurrences"`
+}
+
+func NewSuggestTrie() *SuggestTrie {
+	return &SuggestTrie{root: &suggestNode{}}
+}
+
+// the most frequent words first, ties broken by word
+func rankedBefore(a, b *suggestNode) bool {
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return a.word < b.word
+}
+
+// adds delta to the count of word, words which are not counted anymore are removed
+func (t *SuggestTrie) Add(word string, delta int) {
+	if word == "" || delta == 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var path []*suggestNode
+	if delta > 0 {
+		path = t.root.insert(word)
+	} else {
+		path = t.root.lookup(word)
+	}
+	if path == nil {
+		return
+	}
+
+	terminal := path[len(path)-1]
+	wasCounted := terminal.count > 0
+	terminal.word = word
+	terminal.count += delta
+	if terminal.count < 0 {
+		terminal.count = 0
+	}
+	isCounted := terminal.count > 0
+
+	sizeDelta := 0
+	if !wasCounted && isCounted {
+		sizeDelta = 1
+	} else if wasCounted && !isCounted {
+		sizeDelta = -1
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		node := path[i]
+		node.size += sizeDelta
+
+		switch {
+		case node.size <= suggestCacheThreshold:
+			node.top = nil
+		case node.top == nil:
+			node.top = node.collectTop()
+		case delta > 0:
+			node.promote(terminal)
+		case node.caches(terminal):
+			// the words ranked below the cached ones are not known, the list is merged again
+			node.top = node.collectTop()
+		}
+	}
+
+	if !isCounted {
+		prune(path)
+	}
+}
+
+func (n *suggestNode) child(b byte) (int, *suggestNode) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].label[0] >= b
+	})
+	if i < len(n.children) && n.children[i].label[0] == b {
+		return i, n.children[i]
+	}
+	return i, nil
+}
+
+// returns the nodes from the root to the node of word, nil when the word is not in the tree
+func (n *suggestNode) lookup(word string) []*suggestNode {
+	path := []*suggestNode{n}
+	node := n
+	for word != "" {
+		_, child := node.child(word[0])
+		if child == nil || !strings.HasPrefix(word, child.label) {
+			return nil
+		}
+		word = word[len(child.label):]
+		node = child
+		path = append(path, node)
+	}
+	return path
+}
+
+// same as lookup, the missing nodes are created and the edges are split when needed
+func (n *suggestNode) insert(word string) []*suggestNode {
+	path := []*suggestNode{n}
+	node := n
+	for word != "" {
+		i, child := node.child(word[0])
+		if child == nil {
+			child = &suggestNode{label: word}
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = child
+			return append(path, child)
+		}
+
+		common := commonPrefix(child.label, word)
+		if common < len(child.label) {
+			// the new node has the same words as the child it replaces
+			middle := &suggestNode{
+				label:    child.label[:common],
+				children: []*suggestNode{child},
+				size:     child.size,
+			}
+			if child.top != nil {
+				middle.top = append([]*suggestNode(nil), child.top...)
+			}
+			child.label = child.label[common:]
+			node.children[i] = middle
+			child = middle
+		}
+
+		word = word[common:]
+		node = child
+		path = append(path, node)
+	}
+	return path
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// removes the nodes left without words and merges the edges of the nodes left with one child
+func prune(path []*suggestNode) {
+	for i := len(path) - 1; i > 0; i-- {
+		node, parent := path[i], path[i-1]
+		if node.count > 0 {
+			return
+		}
+
+		switch len(node.children) {
+		case 0:
+			j, _ := parent.child(node.label[0])
+			parent.children = append(parent.children[:j], parent.children[j+1:]...)
+		case 1:
+			child := node.children[0]
+			child.label = node.label + child.label
+			j, _ := parent.child(node.label[0])
+			parent.children[j] = child
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (n *suggestNode) caches(terminal *suggestNode) bool {
+	for _, cached := range n.top {
+		if cached == terminal {
+			return true
+		}
+	}
+	return false
+}
+
+// moves a word whose count increased into the cached list
+func (n *suggestNode) promote(terminal *suggestNode) {
+	// a cached word is never ranked below the last one
+	if len(n.top) == MaxSuggestions {
+		last := n.top[len(n.top)-1]
+		if last != terminal && !rankedBefore(terminal, last) {
+			return
+		}
+	}
+
+	for i, cached := range n.top {
+		if cached == terminal {
+			// the count only increased, the word can only move up
+			for ; i > 0 && rankedBefore(n.top[i], n.top[i-1]); i-- {
+				n.top[i], n.top[i-1] = n.top[i-1], n.top[i]
+			}
+			return
+		}
+	}
+
+	i := sort.Search(len(n.top), func(i int) bool {
+		return rankedBefore(terminal, n.top[i])
+	})
+	n.top = append(n.top, nil)
+	copy(n.top[i+1:], n.top[i:])
+	n.top[i] = terminal
+	if len(n.top) > MaxSuggestions {
+		n.top = n.top[:MaxSuggestions]
+	}
+}
+
+// merges the cached lists of the children, the small children are walked
+func (n *suggestNode) collectTop() []*suggestNode {
+	var candidates []*suggestNode
+	if n.count > 0 {
+		candidates = append(candidates, n)
+	}
+	for _, child := range n.children {
+		if child.top != nil {
+			candidates = append(candidates, child.top...)
+			continue
+		}
+		candidates = child.collect(candidates)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return rankedBefore(candidates[i], candidates[j])
+	})
+	if len(candidates) > MaxSuggestions {
+		candidates = candidates[:MaxSuggestions]
+	}
+	return candidates
+}
+
+// appends every counted word of the subtree
+func (n *suggestNode) collect(words []*suggestNode) []*suggestNode {
+	if n.count > 0 {
+		words = append(words, n)
+	}
+	for _, child := range n.children {
+		words = child.collect(words)
+	}
+	return words
+}
+
+// returns the most frequent words starting with prefix
+func (t *SuggestTrie) Suggest(prefix string, limit int) []Suggestion {
+	if limit <= 0 || limit > MaxSuggestions {
+		limit = MaxSuggestions
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	node := t.root
+	for prefix != "" {
+		_, child := node.child(prefix[0])
+		if child == nil {
+			return []Suggestion{}
+		}
+		// the prefix can end in the middle of an edge
+		if len(prefix) <= len(child.label) {
+			if !strings.HasPrefix(child.label, prefix) {
+				return []Suggestion{}
+			}
+			node = child
+			break
+		}
+		if !strings.HasPrefix(prefix, child.label) {
+			return []Suggestion{}
+		}
+		prefix = prefix[len(child.label):]
+		node = child
+	}
+
+	top := node.top
+	if top == nil {
+		top = node.collect(nil)
+		sort.Slice(top, func(i, j int) bool {
+			return rankedBefore(top[i], top[j])
+		})
+	}
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	suggestions := make([]Suggestion, len(top))
+	for i, node := range top {
+		suggestions[i] = Suggestion{Word: node.word, Occurrences: node.count}
+	}
+	return suggestions
+}
+
+// replaces the content of the trie with the words of the datastore,
+// the cached lists are computed once all the words are added
+func (t *SuggestTrie) Load(datastore *sync.Map) {
+	root := &suggestNode{}
+	datastore.Range(func(key, value interface{}) bool {
+		word, count := key.(string), value.(int)
+		if word == "" || count <= 0 {
+			return true
+		}
+
+		path := root.insert(word)
+		terminal := path[len(path)-1]
+		terminal.word = word
+		terminal.count = count
+		return true
+	})
+	root.build()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.root = root
+}
+
+// computes the sizes and the cached lists of the subtree
+func (n *suggestNode) build() {
+	n.size = 0
+	if n.count > 0 {
+		n.size = 1
+	}
+	for _, child := range n.children {
+		child.build()
+		n.size += child.size
+	}
+
+	n.top = nil
+	if n.size > suggestCacheThreshold {
+		n.top = n.collectTop()
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestTrie(t *testing.T) {
+	trie := NewSuggestTrie()
+	trie.Add("data", 3)
+	trie.Add("database", 5)
+	trie.Add("date", 3)
+	trie.Add("apple", 10)
+
+	assert.Equal(t, []Suggestion{{"database", 5}, {"data", 3}, {"date", 3}}, trie.Suggest("dat", 10))
+	assert.Equal(t, []Suggestion{{"database", 5}}, trie.Suggest("dat", 1))
+	assert.Equal(t, []Suggestion{}, trie.Suggest("x", 10))
+
+	trie.Add("database", -5)
+	assert.Equal(t, []Suggestion{{"data", 3}, {"date", 3}}, trie.Suggest("dat", 10))
+}
+
+// the cached lists must give the same results as sorting every word
+func TestSuggestTrieCache(t *testing.T) {
+	trie := NewSuggestTrie()
+	counts := make(map[string]int)
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		word := fmt.Sprintf("w%d", random.Intn(2000))
+		delta := random.Intn(5) + 1
+		if random.Intn(4) == 0 {
+			delta = -delta
+		}
+
+		trie.Add(word, delta)
+		counts[word] += delta
+		if counts[word] <= 0 {
+			delete(counts, word)
+		}
+	}
+
+	for _, prefix := range []string{"", "w", "w1", "w12", "w199"} {
+		assert.Equal(t, expectedSuggestions(counts, prefix, 20), trie.Suggest(prefix, 20), prefix)
+	}
+
+	loaded := NewSuggestTrie()
+	datastore := &sync.Map{}
+	for word, count := range counts {
+		datastore.Store(word, count)
+	}
+	loaded.Load(datastore)
+	assert.Equal(t, trie.Suggest("w", 20), loaded.Suggest("w", 20))
+}
+
+func expectedSuggestions(counts map[string]int, prefix string, limit int) []Suggestion {
+	suggestions := []Suggestion{}
+	for word, count := range counts {
+		if strings.HasPrefix(word, prefix) {
+			suggestions = append(suggestions, Suggestion{Word: word, Occurrences: count})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Occurrences != suggestions[j].Occurrences {
+			return suggestions[i].Occurrences > suggestions[j].Occurrences
+		}
+		return suggestions[i].Word < suggestions[j].Word
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
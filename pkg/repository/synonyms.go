@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// aliases are replaced by their canonical word before the words are counted
+	SynonymIngest = "ingest"
+	// aliases are counted as they are, their counts are added to the canonical word by the queries
+	SynonymQuery = "query"
+)
+
+var ErrSynonymNotFound = errors.New("Synonym not found")
+
+// SynonymTable maps aliases to a canonical word. Aliases of several words,
+// like "new york", can only be applied at ingest.
+// Changing a mapping doesn't change the words already counted.
+type SynonymTable struct {
+	mutex    sync.RWMutex
+	synonyms map[string]*Synonym
+	// ingest aliases by their first word, the longest first
+	phrases map[string][]*Synonym
+	// query aliases by their canonical word
+	groups map[string][]string
+}
+
+type Synonym struct {
+	// words separated by single spaces
+	Alias     string `json:"alias"`
+	Canonical string `json:"canonical"`
+	Mode      string `json:"mode"`
+}
+
+type synonymChange struct {
+	Synonym
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+func NewSynonymTable() *SynonymTable {
+	return &SynonymTable{
+		synonyms: make(map[string]*Synonym),
+		phrases:  make(map[string][]*Synonym),
+		groups:   make(map[string][]string),
+	}
+}
+
+// adds or replaces the mapping of the alias, normalized by the caller.
+// Mappings cannot be chained.
+func (t *SynonymTable) Put(synonym *Synonym) error {
+	if synonym.Mode != SynonymIngest && synonym.Mode != SynonymQuery {
+		return fmt.Errorf("Unknown synonym mode %s", synonym.Mode)
+	}
+	if synonym.Alias == "" || synonym.Canonical == "" {
+		return errors.New("Alias and canonical word cannot be empty")
+	}
+	if strings.Contains(synonym.Canonical, " ") {
+		return errors.New("Canonical word must be a single word")
+	}
+	if synonym.Mode == SynonymQuery && strings.Contains(synonym.Alias, " ") {
+		return errors.New("Aliases of several words can only be applied at ingest")
+	}
+	if synonym.Alias == synonym.Canonical {
+		return errors.New("Alias and canonical word must be different")
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, found := t.synonyms[synonym.Canonical]; found {
+		return fmt.Errorf("Canonical word %s is an alias itself", synonym.Canonical)
+	}
+	for _, existing := range t.synonyms {
+		if existing.Canonical == synonym.Alias {
+			return fmt.Errorf("Alias %s is the canonical word of %s", synonym.Alias, existing.Alias)
+		}
+	}
+
+	t.put(synonym)
+	return nil
+}
+
+func (t *SynonymTable) put(synonym *Synonym) {
+	t.remove(synonym.Alias)
+	stored := *synonym
+	t.synonyms[stored.Alias] = &stored
+
+	if stored.Mode == SynonymQuery {
+		t.groups[stored.Canonical] = append(t.groups[stored.Canonical], stored.Alias)
+		sort.Strings(t.groups[stored.Canonical])
+		return
+	}
+
+	first, _, _ := strings.Cut(stored.Alias, " ")
+	phrases := append(t.phrases[first], &stored)
+	sort.Slice(phrases, func(i, j int) bool {
+		return len(phrases[i].Alias) > len(phrases[j].Alias)
+	})
+	t.phrases[first] = phrases
+}
+
+func (t *SynonymTable) Remove(alias string) (*Synonym, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	synonym := t.remove(alias)
+	if synonym == nil {
+		return nil, ErrSynonymNotFound
+	}
+	return synonym, nil
+}
+
+func (t *SynonymTable) remove(alias string) *Synonym {
+	synonym, found := t.synonyms[alias]
+	if !found {
+		return nil
+	}
+	delete(t.synonyms, alias)
+
+	if synonym.Mode == SynonymQuery {
+		t.groups[synonym.Canonical] = removeString(t.groups[synonym.Canonical], alias)
+		if len(t.groups[synonym.Canonical]) == 0 {
+			delete(t.groups, synonym.Canonical)
+		}
+		return synonym
+	}
+
+	first, _, _ := strings.Cut(alias, " ")
+	phrases := t.phrases[first][:0]
+	for _, phrase := range t.phrases[first] {
+		if phrase.Alias != alias {
+			phrases = append(phrases, phrase)
+		}
+	}
+	if len(phrases) == 0 {
+		delete(t.phrases, first)
+	} else {
+		t.phrases[first] = phrases
+	}
+	return synonym
+}
+
+func removeString(values []string, value string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (t *SynonymTable) Get(alias string) (*Synonym, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	synonym, found := t.synonyms[alias]
+	if !found {
+		return nil, false
+	}
+	copied := *synonym
+	return &copied, true
+}
+
+// returns every mapping sorted by alias
+func (t *SynonymTable) All() []Synonym {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	synonyms := make([]Synonym, 0, len(t.synonyms))
+	for _, synonym := range t.synonyms {
+		synonyms = append(synonyms, *synonym)
+	}
+	sort.Slice(synonyms, func(i, j int) bool {
+		return synonyms[i].Alias < synonyms[j].Alias
+	})
+	return synonyms
+}
+
+// replaces the ingest aliases of the words by their canonical word,
+// the longest alias is used when several of them match.
+// A nil table has no synonyms.
+func (t *SynonymTable) Canonicalize(words []string) []string {
+	if t == nil {
+		return words
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if len(t.phrases) == 0 {
+		return words
+	}
+
+	result := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		matched := false
+		for _, phrase := range t.phrases[words[i]] {
+			if length := matchPhrase(words[i:], phrase.Alias); length > 0 {
+				result = append(result, phrase.Canonical)
+				i += length
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result = append(result, words[i])
+			i++
+		}
+	}
+	return result
+}
+
+// returns the number of words of the alias at the start of words, 0 when it doesn't match
+func matchPhrase(words []string, alias string) int {
+	length := 0
+	for _, part := range strings.Split(alias, " ") {
+		if length == len(words) || words[length] != part {
+			return 0
+		}
+		length++
+	}
+	return length
+}
+
+// returns the words counted together with word at query time, word included
+func (t *SynonymTable) Group(word string) []string {
+	if t == nil {
+		return []string{word}
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	canonical := word
+	if synonym, found := t.synonyms[word]; found && synonym.Mode == SynonymQuery {
+		canonical = synonym.Canonical
+	}
+
+	aliases := t.groups[canonical]
+	if len(aliases) == 0 {
+		return []string{word}
+	}
+	return append([]string{canonical}, aliases...)
+}
+
+func (t *SynonymTable) Load(synonyms []Synonym) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.synonyms = make(map[string]*Synonym)
+	t.phrases = make(map[string][]*Synonym)
+	t.groups = make(map[string][]string)
+	for i := range synonyms {
+		t.put(&synonyms[i])
+	}
+}
+
+func (t *SynonymTable) replay(payload []byte) error {
+	var record synonymChange
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("Cannot decode synonym record: %v", err)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if record.Deleted {
+		t.remove(record.Alias)
+		return nil
+	}
+	t.put(&record.Synonym)
+	return nil
+}
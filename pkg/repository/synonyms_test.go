@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymTableIngest(t *testing.T) {
+	table := NewSynonymTable()
+	assert.NoError(t, table.Put(&Synonym{Alias: "new york", Canonical: "nyc", Mode: SynonymIngest}))
+	assert.NoError(t, table.Put(&Synonym{Alias: "new york city", Canonical: "nyc", Mode: SynonymIngest}))
+	assert.NoError(t, table.Put(&Synonym{Alias: "colour", Canonical: "color", Mode: SynonymIngest}))
+
+	words := table.Canonicalize([]string{"new", "york", "city", "has", "colour", "new", "things", "new", "york"})
+	assert.Equal(t, []string{"nyc", "has", "color", "new", "things", "nyc"}, words)
+
+	_, err := table.Remove("new york city")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nyc", "city"}, table.Canonicalize([]string{"new", "york", "city"}))
+
+	_, err = table.Remove("new york city")
+	assert.ErrorIs(t, err, ErrSynonymNotFound)
+}
+
+func TestSynonymTableQuery(t *testing.T) {
+	table := NewSynonymTable()
+	assert.NoError(t, table.Put(&Synonym{Alias: "colour", Canonical: "color", Mode: SynonymQuery}))
+	assert.NoError(t, table.Put(&Synonym{Alias: "colr", Canonical: "color", Mode: SynonymQuery}))
+
+	assert.Equal(t, []string{"color", "colour", "colr"}, table.Group("color"))
+	assert.Equal(t, []string{"color", "colour", "colr"}, table.Group("colour"))
+	assert.Equal(t, []string{"red"}, table.Group("red"))
+	// query synonyms don't change the words at ingest
+	assert.Equal(t, []string{"colour"}, table.Canonicalize([]string{"colour"}))
+}
+
+func TestSynonymTableValidation(t *testing.T) {
+	table := NewSynonymTable()
+	assert.NoError(t, table.Put(&Synonym{Alias: "colour", Canonical: "color", Mode: SynonymIngest}))
+
+	assert.Error(t, table.Put(&Synonym{Alias: "new york", Canonical: "nyc", Mode: SynonymQuery}))
+	assert.Error(t, table.Put(&Synonym{Alias: "nyc", Canonical: "new york", Mode: SynonymIngest}))
+	assert.Error(t, table.Put(&Synonym{Alias: "nyc", Canonical: "nyc", Mode: SynonymIngest}))
+	assert.Error(t, table.Put(&Synonym{Alias: "nyc", Canonical: "city", Mode: "sometimes"}))
+	// mappings cannot be chained
+	assert.Error(t, table.Put(&Synonym{Alias: "kolor", Canonical: "colour", Mode: SynonymIngest}))
+	assert.Error(t, table.Put(&Synonym{Alias: "color", Canonical: "hue", Mode: SynonymIngest}))
+}
+
+func TestSynonymTableReplay(t *testing.T) {
+	table := NewSynonymTable()
+
+	put, _ := json.Marshal(&synonymChange{Synonym: Synonym{Alias: "colour", Canonical: "color", Mode: SynonymQuery}})
+	deleted, _ := json.Marshal(&synonymChange{Synonym: Synonym{Alias: "colour"}, Deleted: true})
+
+	assert.NoError(t, table.replay(put))
+	assert.Len(t, table.All(), 1)
+	assert.NoError(t, table.replay(deleted))
+	assert.Empty(t, table.All())
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	// "path/filepath"
@@ -9,9 +10,21 @@ import (
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Every line of the WAL is either a word, counted once, or a structured record
+// "<type> <json payload>". Words never contain spaces, so they can't be confused with records.
+const (
+	docRecord         = "doc"
+	retractRecord     = "retract"
+	idempotencyRecord = "idempotency"
+	synonymRecord     = "synonym"
+	labelsRecord      = "labels"
+	deleteRecord      = "delete"
+)
+
 type WriteAheadLog struct {
 	walFilePath  string
 	mutex        sync.RWMutex
@@ -20,6 +33,9 @@ type WriteAheadLog struct {
 	file         *os.File
 	syncMaxBytes int64
 	logger       log.Logger
+	// offsets in the file, the bytes after committed are still in the buffer
+	written   atomic.Int64
+	committed atomic.Int64
 }
 
 func NewWAL(ctx context.Context, options *config.WALOptions) *WriteAheadLog {
@@ -45,6 +61,13 @@ func (wal *WriteAheadLog) Init(ctx context.Context) error {
 		}
 	}
 
+	info, err := wal.file.Stat()
+	if err != nil {
+		return fmt.Errorf("Cannot read size of WALfile: %v", err)
+	}
+	wal.written.Store(info.Size())
+	wal.committed.Store(info.Size())
+
 	wal.bufWriter = bufio.NewWriter(wal.file)
 	go wal.KeepSyncing(ctx)
 
@@ -57,10 +80,31 @@ func (wal *WriteAheadLog) Write(data []byte) error {
 	defer wal.mutex.Unlock()
 
 	// Write data payload to the buffer
-	if _, err := wal.bufWriter.Write(data); err != nil {
-		return err
+	n, err := wal.bufWriter.Write(data)
+	wal.written.Add(int64(n))
+	return err
+}
+
+// returns the end of the appended data and the end of the data synced to disk,
+// an entry is durable once the committed position reaches the written one seen after appending it
+func (wal *WriteAheadLog) Position() (int64, int64) {
+	return wal.written.Load(), wal.committed.Load()
+}
+
+// appends a structured record to the log
+func (wal *WriteAheadLog) WriteRecord(recordType string, payload interface{}) error {
+	encodedPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("Cannot encode %s record: %v", recordType, err)
 	}
-	return nil
+
+	record := make([]byte, 0, len(recordType)+len(encodedPayload)+2)
+	record = append(record, recordType...)
+	record = append(record, ' ')
+	record = append(record, encodedPayload...)
+	record = append(record, '\n')
+
+	return wal.Write(record)
 }
 
 func (wal *WriteAheadLog) createWALFile() error {
@@ -83,7 +127,8 @@ func (wal *WriteAheadLog) KeepSyncing(ctx context.Context) {
 	for {
 		select {
 		case <-wal.syncTimer.C:
-			if wal.bufWriter.Buffered() == 0 {
+			// the buffer may have been flushed to the file without being synced
+			if written, committed := wal.Position(); written == committed {
 				continue
 			}
 			wal.mutex.Lock()
@@ -116,7 +161,11 @@ func (wal *WriteAheadLog) Sync() error {
 	if err != nil {
 		return fmt.Errorf("Cannot flush data: %v", err.Error())
 	}
-	return wal.file.Sync()
+	if err := wal.file.Sync(); err != nil {
+		return err
+	}
+	wal.committed.Store(wal.written.Load())
+	return nil
 }
 
 func (wal *WriteAheadLog) Close() error {
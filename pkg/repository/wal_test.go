@@ -6,6 +6,7 @@ import (
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -144,6 +145,40 @@ func TestSync(t *testing.T) {
 	os.Remove(options.WalFilePath)
 }
 
+func TestPosition(t *testing.T) {
+	options := &config.WALOptions{
+		WalFilePath: filepath.Join(t.TempDir(), "wal"),
+		SyncTimer:   3600,
+	}
+	os.WriteFile(options.WalFilePath, []byte("apple\n"), 0666)
+
+	ctx := getLoggerContext()
+	wal := NewWAL(ctx, options)
+	if err := wal.Init(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer wal.Close()
+
+	if written, committed := wal.Position(); written != 6 || committed != 6 {
+		t.Fatalf("expected the position to start at the end of the file, got %d %d", written, committed)
+	}
+
+	wal.Write([]byte("banana\n"))
+	if written, committed := wal.Position(); written != 13 || committed != 6 {
+		t.Fatalf("expected only the written position to move, got %d %d", written, committed)
+	}
+
+	wal.mutex.Lock()
+	err := wal.Sync()
+	wal.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if written, committed := wal.Position(); written != 13 || committed != 13 {
+		t.Fatalf("expected the synced data to be committed, got %d %d", written, committed)
+	}
+}
+
 func TestKeepSyncing(t *testing.T) {
 	options := &config.WALOptions{
 		WalFilePath:  "/home/adrian/Documents/mem-db/data/test_wal.log",
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	config "mem-db/cmd/config"
+	"strings"
+	"sync"
+)
+
+const (
+	DedupSkip = "skip"
+	DedupFlag = "flag"
+
+	defaultDedupThreshold = 0.8
+	defaultDedupWindow    = 10000
+	// the shingles of shorter texts are too few to compare them, these texts are always counted
+	minFingerprintWords = 10
+	// words of every shingle
+	shingleSize = 3
+	// the signature is split in bands, texts with an equal band are compared
+	minHashBands    = 16
+	minHashRows     = 4
+	minHashFunction = minHashBands * minHashRows
+)
+
+// DuplicateDetector keeps the MinHash signatures of the texts counted recently.
+// The similarity of two texts is the Jaccard similarity of their shingles, estimated
+// from the share of equal signature values. The signatures are kept in memory only.
+type DuplicateDetector struct {
+	mutex     sync.Mutex
+	action    string
+	threshold float64
+	// ring of the most recent signatures
+	recent []*signature
+	next   int
+	// signatures by the hash of every band
+	bands [minHashBands]map[uint64][]*signature
+	stats DedupStats
+}
+
+type signature struct {
+	values     [minHashFunction]uint64
+	documentID string
+}
+
+// recent text a registered text is a near duplicate of
+type DuplicateMatch struct {
+	// empty when the recent text was registered without an id
+	DocumentID string  `json:"documentId,omitempty"`
+	Similarity float64 `json:"similarity"`
+}
+
+type DedupStats struct {
+	Action     string  `json:"action"`
+	Threshold  float64 `json:"threshold"`
+	Window     int     `json:"window"`
+	Checked    int     `json:"checked"`
+	Duplicates int     `json:"duplicates"`
+	Skipped    int     `json:"skipped"`
+}
+
+func NewDuplicateDetector(options *config.DedupOptions) (*DuplicateDetector, error) {
+	action := options.Action
+	if action == "" {
+		action = DedupSkip
+	}
+	if action != DedupSkip && action != DedupFlag {
+		return nil, fmt.Errorf("Unknown dedup action %s", action)
+	}
+
+	threshold := options.Threshold
+	if threshold == 0 {
+		threshold = defaultDedupThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("Dedup threshold must be between 0 and 1, got %v", threshold)
+	}
+
+	window := options.Window
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+
+	d := &DuplicateDetector{
+		action:    action,
+		threshold: threshold,
+		recent:    make([]*signature, 0, window),
+		stats:     DedupStats{Action: action, Threshold: threshold, Window: window},
+	}
+	for i := range d.bands {
+		d.bands[i] = make(map[uint64][]*signature)
+	}
+	return d, nil
+}
+
+// compares the words with the recent texts, the text is remembered when it's counted.
+// Returns the most similar recent text when it's a near duplicate and whether the text should be counted.
+func (d *DuplicateDetector) Check(words []string, documentID string) (*DuplicateMatch, bool) {
+	if len(words) < minFingerprintWords {
+		return nil, true
+	}
+	sig := minHash(words)
+	sig.documentID = documentID
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.stats.Checked++
+
+	var match *DuplicateMatch
+	compared := make(map[*signature]struct{})
+	for band := range d.bands {
+		for _, candidate := range d.bands[band][sig.band(band)] {
+			if _, found := compared[candidate]; found {
+				continue
+			}
+			compared[candidate] = struct{}{}
+
+			similarity := sig.similarity(candidate)
+			if similarity >= d.threshold && (match == nil || similarity > match.Similarity) {
+				match = &DuplicateMatch{DocumentID: candidate.documentID, Similarity: similarity}
+			}
+		}
+	}
+
+	if match != nil {
+		d.stats.Duplicates++
+		if d.action == DedupSkip {
+			d.stats.Skipped++
+			return match, false
+		}
+	}
+
+	d.add(sig)
+	return match, true
+}
+
+// the oldest signature is replaced once the window is full
+func (d *DuplicateDetector) add(sig *signature) {
+	if len(d.recent) < cap(d.recent) {
+		d.recent = append(d.recent, sig)
+	} else {
+		d.remove(d.recent[d.next])
+		d.recent[d.next] = sig
+		d.next = (d.next + 1) % len(d.recent)
+	}
+
+	for band := range d.bands {
+		key := sig.band(band)
+		d.bands[band][key] = append(d.bands[band][key], sig)
+	}
+}
+
+func (d *DuplicateDetector) remove(sig *signature) {
+	for band := range d.bands {
+		key := sig.band(band)
+		bucket := d.bands[band][key]
+		for i, candidate := range bucket {
+			if candidate == sig {
+				bucket = append(bucket[:i], bucket[i+1:]...)
+				break
+			}
+		}
+		if len(bucket) == 0 {
+			delete(d.bands[band], key)
+		} else {
+			d.bands[band][key] = bucket
+		}
+	}
+}
+
+func (d *DuplicateDetector) Stats() DedupStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.stats
+}
+
+// every value of the signature is the smallest hash of the shingles for one hash function,
+// two texts have the same value with a probability equal to the Jaccard similarity of their shingles
+func minHash(words []string) *signature {
+	sig := &signature{}
+	for i := range sig.values {
+		sig.values[i] = math.MaxUint64
+	}
+
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		shingle := h.Sum64()
+
+		for j := range sig.values {
+			if value := mix(shingle ^ uint64(j+1)*0x9e3779b97f4a7c15); value < sig.values[j] {
+				sig.values[j] = value
+			}
+		}
+	}
+	return sig
+}
+
+// finalizer of splitmix64, spreads the bits of the seeded hashes
+func mix(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func (s *signature) band(band int) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, value := range s.values[band*minHashRows : (band+1)*minHashRows] {
+		binary.LittleEndian.PutUint64(buf[:], value)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+func (s *signature) similarity(other *signature) float64 {
+	equal := 0
+	for i := range s.values {
+		if s.values[i] == other.values[i] {
+			equal++
+		}
+	}
+	return float64(equal) / minHashFunction
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	config "mem-db/cmd/config"
+	"strings"
+	"testing"
+)
+
+const article = `The central bank kept its interest rates unchanged on Thursday and said inflation
+is expected to slow down over the coming months as energy prices fall, while the labour market
+remains tight and wages keep growing faster than productivity in most sectors of the economy`
+
+func TestDuplicateDetectorSkip(t *testing.T) {
+	d, err := NewDuplicateDetector(&config.DedupOptions{})
+	if err != nil {
+		t.Fatalf("NewDuplicateDetector failed: %v", err)
+	}
+
+	if match, counted := d.Check(Tokenize(article), "original"); match != nil || !counted {
+		t.Fatalf("Expected the first text to be counted, got %+v", match)
+	}
+
+	// syndicated copy with a different day
+	copied := strings.Replace(article, "Thursday", "Friday", 1)
+	match, counted := d.Check(Tokenize(copied), "copy")
+	if counted || match == nil || match.DocumentID != "original" {
+		t.Fatalf("Expected the copy to be skipped, got %+v", match)
+	}
+
+	other := `Heavy rain is expected across the north of the country this weekend and the
+authorities have asked drivers to avoid the mountain roads until the storm has passed`
+	if match, counted := d.Check(Tokenize(other), "other"); match != nil || !counted {
+		t.Fatalf("Expected a different text to be counted, got %+v", match)
+	}
+
+	// short texts are always counted
+	if _, counted := d.Check(Tokenize("good morning"), ""); !counted {
+		t.Fatalf("Expected a short text to be counted")
+	}
+	if _, counted := d.Check(Tokenize("good morning"), ""); !counted {
+		t.Fatalf("Expected a short text to be counted")
+	}
+
+	if stats := d.Stats(); stats.Checked != 3 || stats.Duplicates != 1 || stats.Skipped != 1 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestDuplicateDetectorFlagAndWindow(t *testing.T) {
+	d, err := NewDuplicateDetector(&config.DedupOptions{Action: DedupFlag, Window: 1})
+	if err != nil {
+		t.Fatalf("NewDuplicateDetector failed: %v", err)
+	}
+
+	d.Check(Tokenize(article), "first")
+	if match, counted := d.Check(Tokenize(article), "second"); match == nil || match.Similarity != 1 || !counted {
+		t.Fatalf("Expected the duplicate to be flagged and counted, got %+v", match)
+	}
+
+	// the window keeps only the last text, the second one
+	if match, _ := d.Check(Tokenize(article), "third"); match == nil || match.DocumentID != "second" {
+		t.Fatalf("Expected a match with the second text, got %+v", match)
+	}
+
+	if _, err := NewDuplicateDetector(&config.DedupOptions{Action: "drop"}); err == nil {
+		t.Fatalf("Expected an error for an unknown action")
+	}
+}
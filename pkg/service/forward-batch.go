@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the lines of the streams are forwarded to the workers in batches up to this size
+	maxBatchLines = 1000
+	maxBatchBytes = 1 << 20
+	// the lines waiting for a batch are forwarded after this delay
+	batchInterval = time.Second
+	// route of the workers which counts the batches, it's not part of the client API
+	forwardedLinesPath = "/internal/lines"
+)
+
+// lines counted by the master, sent to the workers in one request
+type forwardedLines struct {
+	Lines  []string          `json:"lines"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// collects the lines counted by the streams, the workers get one request per batch
+// instead of one per line
+type lineBatch struct {
+	mutex  sync.Mutex
+	lines  []string
+	labels map[string]string
+	size   int
+	// sends a batch to the workers, called without the lock
+	flush func(batch *forwardedLines)
+}
+
+func newLineBatch(flush func(batch *forwardedLines)) *lineBatch {
+	return &lineBatch{flush: flush}
+}
+
+// the batch is forwarded when it's full or when the line has other labels
+func (b *lineBatch) Add(line string, labels map[string]string) {
+	b.mutex.Lock()
+	var ready []*forwardedLines
+	if len(b.lines) > 0 && !maps.Equal(b.labels, labels) {
+		ready = append(ready, b.take())
+	}
+	b.lines = append(b.lines, line)
+	b.labels = labels
+	b.size += len(line)
+	if len(b.lines) >= maxBatchLines || b.size >= maxBatchBytes {
+		ready = append(ready, b.take())
+	}
+	b.mutex.Unlock()
+
+	for _, batch := range ready {
+		b.flush(batch)
+	}
+}
+
+// forwards the pending lines
+func (b *lineBatch) Flush() {
+	b.mutex.Lock()
+	if len(b.lines) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mutex.Unlock()
+
+	b.flush(batch)
+}
+
+func (b *lineBatch) take() *forwardedLines {
+	batch := &forwardedLines{Lines: b.lines, Labels: b.labels}
+	b.lines, b.labels, b.size = nil, nil, 0
+	return batch
+}
+
+// forwards the lines of the quiet streams every interval, the lines pending at the stop are dropped
+// like the other requests which were not forwarded yet
+func (b *lineBatch) Run(ctx context.Context) {
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *wordService) forwardLines(batch *forwardedLines) {
+	bodyBytes, err := json.Marshal(batch)
+	if err != nil {
+		s.logger.Error("Cannot encode lines for workers: ", err)
+		return
+	}
+	s.forward(http.MethodPost, apiPrefix+forwardedLinesPath, bodyBytes, "")
+}
+
+// POST /internal/lines, the batches forwarded by the master. The lines were already checked
+// for near duplicates, they are counted as plain words.
+func (s *wordService) registerForwardedLines(w http.ResponseWriter, r *http.Request) {
+	var batch forwardedLines
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(batch.Lines) == 0 {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Lines field is empty")
+		return
+	}
+	if err := repo.ValidateLabels(batch.Labels); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if !s.reserveWords(w, r, len(Tokenize(strings.Join(batch.Lines, "\n")))) {
+		return
+	}
+
+	for _, line := range batch.Lines {
+		s.RegisterWords(line, batch.Labels)
+	}
+	writeResponse(w, r, http.StatusOK, &Response{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Message:    fmt.Sprintf("%d lines processed successfully", len(batch.Lines))})
+}
@@ -1,85 +1,634 @@
 package service
 
-// import (
-// 	"context"
-// 	"fmt"
-// 	config "mem-db/cmd/config"
-// 	"net"
-
-// 	"google.golang.org/grpc"
-// 	"google.golang.org/grpc/codes"
-// 	// "google.golang.org/grpc/status"
-// 	log "mem-db/cmd/logger"
-// 	word "mem-db/pkg/proto/mem-db/pkg/proto/service"
-// )
-
-// type WSGrpcServer struct {
-// 	word.UnimplementedWordServiceServer
-// 	grpcServer *grpc.Server
-// 	logger     log.Logger
-// }
-
-// func NewGrpcServer(ctx context.Context, options *config.ServiceOptions, svc *wordService) *WSGrpcServer {
-
-// 	server := grpc.NewServer()
-// 	word.RegisterWordServiceServer(server, svc)
-
-// 	return &WSGrpcServer{
-// 		grpcServer: server,
-// 		logger:     ctx.Value(log.LoggerKey).(log.Logger),
-// 	}
-// }
-
-// func (s *WSGrpcServer) Start() error {
-// 	s.logger.Info("Starting server for WordService")
-
-// 	lis, err := net.Listen("tcp", ":8080")
-// 	if err != nil {
-// 		return fmt.Errorf("Failed to listen on port 8080: %v", err)
-// 	}
-
-// 	return s.grpcServer.Serve(lis)
-// }
-
-// func (s *WSGrpcServer) Stop(ctx context.Context) error {
-// 	s.grpcServer.GracefulStop()
-// 	return nil
-// }
-
-// func (s *wordService) GetWordOccurences(ctx context.Context, req *word.GetWordOccurrencesRequest) (*word.GetWordOccurrencesResponse, error) {
-// 	terms := req.GetTerms()
-// 	if terms == "" {
-// 		return &word.GetWordOccurrencesResponse{
-// 			Status:     "Bad Request",
-// 			StatusCode: int32(codes.InvalidArgument),
-// 			Message:    "No words provided into request",
-// 		}, nil
-// 	}
-
-// 	results := s.GetOccurences(terms)
-
-// 	return &word.GetWordOccurrencesResponse{
-// 		Status:     "Success",
-// 		StatusCode: int32(codes.OK),
-// 		Data:       results,
-// 	}, nil
-// }
-
-// func (s *wordService) RegisterWordsG(ctx context.Context, req *word.RegisterWordsRequest) (*word.RegisterWordsResponse, error) {
-// 	text := req.GetText()
-// 	if text == "" {
-// 		return &word.RegisterWordsResponse{
-// 			Status:     "Bad Request",
-// 			StatusCode: int32(codes.InvalidArgument),
-// 			Message:    "Text field is empty",
-// 		}, nil
-// 	}
-
-// 	s.RegisterWords(text)
-
-// 	return &word.RegisterWordsResponse{
-// 		Status:     "Success",
-// 		StatusCode: int32(codes.OK),
-// 		Message:    "Text processed successfully",
-// 	}, nil
-// }
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	grpcserver "mem-db/pkg/api/grpc"
+	word "mem-db/pkg/proto/service"
+	ratelimit "mem-db/pkg/ratelimit"
+	repo "mem-db/pkg/repository"
+	util "mem-db/pkg/util"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// metadata of the calls changing data, like the Idempotency-Key header
+	idempotencyKeyMetadata = "idempotency-key"
+	// domain of the ErrorInfo details
+	errorDomain = "mem-db"
+)
+
+type DBGrpcServer struct {
+	word.UnimplementedWordServiceServer
+	server *grpcserver.GRPCServer
+	ws     *wordService
+	logger log.Logger
+}
+
+func NewDBGrpcServer(ctx context.Context, options *config.ServiceOptions, ws *wordService) *DBGrpcServer {
+	dbGrpcServer := &DBGrpcServer{
+		server: grpcserver.NewServer(ctx, options.ApiOptions),
+		ws:     ws,
+		logger: ctx.Value(log.LoggerKey).(log.Logger),
+	}
+
+	word.RegisterWordServiceServer(dbGrpcServer.server.Server, dbGrpcServer)
+	dbGrpcServer.server.Health.SetServingStatus(word.WordService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return dbGrpcServer
+}
+
+func (s *DBGrpcServer) Start() error {
+	s.logger.Info("Starting gRPC server for WordService")
+	return s.server.Start()
+}
+
+func (s *DBGrpcServer) Stop(ctx context.Context) error {
+	return s.server.Stop(ctx)
+}
+
+// the code of the HTTP problem is sent as the reason of an ErrorInfo detail
+func grpcError(code codes.Code, reason, message string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// the rate limits and the quotas are ResourceExhausted with the code of the 429 problem,
+// nil for the other errors
+func limitError(err error) error {
+	var quotaErr *ratelimit.QuotaError
+	if errors.As(err, &quotaErr) {
+		return grpcError(codes.ResourceExhausted, CodeQuotaExceeded, err.Error())
+	}
+	if errors.Is(err, errRateLimited) {
+		return grpcError(codes.ResourceExhausted, CodeRateLimited, err.Error())
+	}
+	return nil
+}
+
+// address of the client of the call, nil when it's unknown
+func peerAddr(ctx context.Context) net.Addr {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr
+	}
+	return nil
+}
+
+// returns defaultLimit when the field is not set
+func grpcLimit(limit int32, defaultLimit int) (int, error) {
+	if limit < 0 {
+		return 0, grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Invalid limit %d", limit))
+	}
+	if limit == 0 {
+		return defaultLimit, nil
+	}
+	return int(limit), nil
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// calls retried with the same idempotency-key metadata get the original response
+// instead of being applied again, the failed calls can be retried
+func idempotentCall[T proto.Message](s *DBGrpcServer, ctx context.Context, method string, call func() (T, error)) (T, error) {
+	var response T
+
+	key := metadataValue(ctx, idempotencyKeyMetadata)
+	if key == "" {
+		return call()
+	}
+
+	// the keys of the HTTP requests start with the method of the request
+	requestKey := "grpc " + method + " " + key
+
+	stored, err := s.ws.db.BeginRequest(requestKey)
+	if errors.Is(err, repo.ErrRequestInProgress) {
+		return response, grpcError(codes.Aborted, CodeRequestInProgress, err.Error())
+	}
+
+	if stored != nil {
+		s.logger.Debug("Replaying response of call with idempotency key ", key)
+		response = response.ProtoReflect().New().Interface().(T)
+		if err := proto.Unmarshal(stored.Body, response); err != nil {
+			return response, grpcError(codes.Internal, CodeInternal, err.Error())
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(IdempotentReplayedHeader), "true"))
+		return response, nil
+	}
+
+	response, err = call()
+	if err != nil {
+		s.ws.db.ReleaseRequest(requestKey)
+		return response, err
+	}
+
+	body, err := proto.Marshal(response)
+	if err != nil {
+		s.ws.db.ReleaseRequest(requestKey)
+		return response, nil
+	}
+	s.ws.db.CompleteRequest(requestKey, http.StatusOK, "", body)
+	return response, nil
+}
+
+func (s *DBGrpcServer) RegisterWords(ctx context.Context, req *word.RegisterWordsRequest) (*word.RegisterWordsResponse, error) {
+	return idempotentCall(s, ctx, "RegisterWords", func() (*word.RegisterWordsResponse, error) {
+		return s.registerWords(ctx, req)
+	})
+}
+
+func (s *DBGrpcServer) registerWords(ctx context.Context, req *word.RegisterWordsRequest) (*word.RegisterWordsResponse, error) {
+	if len(req.GetText()) == 0 {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "Text field is empty")
+	}
+	if err := repo.ValidateLabels(req.GetLabels()); err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+	}
+	addr := peerAddr(ctx)
+	if err := s.ws.allowWrite(addr); err != nil {
+		return nil, limitError(err)
+	}
+
+	textInput := &TextInput{
+		Text:       req.GetText(),
+		DocumentID: req.GetDocumentId(),
+		Namespace:  req.GetNamespace(),
+		Labels:     req.GetLabels(),
+		Force:      req.GetForce(),
+	}
+	// the index needs an id for every document
+	if textInput.DocumentID == "" && s.ws.db.Index() != nil {
+		textInput.DocumentID = util.NewID()
+	}
+
+	if req.GetAsync() {
+		return s.enqueueText(addr, textInput)
+	}
+
+	registration, err := s.ws.registerCharged(addr, textInput)
+	if limitErr := limitError(err); limitErr != nil {
+		return nil, limitErr
+	}
+	if errors.Is(err, repo.ErrDocumentExists) {
+		return nil, grpcError(codes.AlreadyExists, CodeDocumentExists, fmt.Sprintf("Document %s is already registered", textInput.DocumentID))
+	}
+	if err != nil {
+		s.logger.Error("Cannot register document: ", err)
+		return nil, grpcError(codes.Internal, CodeInternal, err.Error())
+	}
+
+	response := &word.RegisterWordsResponse{
+		Words:     int32(registration.Words),
+		Counted:   registration.Counted,
+		Duplicate: duplicateMessage(registration.Duplicate),
+	}
+	if registration.Counted {
+		response.DocumentId = textInput.DocumentID
+		s.ws.forwardText(textInput, metadataValue(ctx, idempotencyKeyMetadata))
+	}
+	return response, nil
+}
+
+// the words stay charged once the text is queued, even when it's skipped later
+func (s *DBGrpcServer) enqueueText(addr net.Addr, textInput *TextInput) (*word.RegisterWordsResponse, error) {
+	words := textInput.words()
+	if err := s.ws.reserveFor(addr, words); err != nil {
+		return nil, limitError(err)
+	}
+	response, err := s.enqueue(textInput)
+	if err != nil {
+		s.ws.refundFor(addr, words)
+	}
+	return response, err
+}
+
+func (s *DBGrpcServer) enqueue(textInput *TextInput) (*word.RegisterWordsResponse, error) {
+	if textInput.DocumentID != "" {
+		if _, found := s.ws.db.GetDocument(textInput.DocumentID); found {
+			return nil, grpcError(codes.AlreadyExists, CodeDocumentExists, fmt.Sprintf("Document %s is already registered", textInput.DocumentID))
+		}
+	}
+
+	job, err := s.ws.jobs.Enqueue(textInput)
+	if errors.Is(err, ErrJobQueueFull) {
+		s.logger.Warn("Rejecting job: ", err.Error())
+		return nil, grpcError(codes.ResourceExhausted, CodeQueueFull, err.Error())
+	}
+	if err != nil {
+		s.logger.Error("Cannot enqueue job: ", err)
+		return nil, grpcError(codes.Internal, CodeInternal, err.Error())
+	}
+
+	return &word.RegisterWordsResponse{DocumentId: textInput.DocumentID, Job: jobMessage(job)}, nil
+}
+
+func (s *DBGrpcServer) UploadFiles(ctx context.Context, req *word.UploadFilesRequest) (*word.UploadFilesResponse, error) {
+	return idempotentCall(s, ctx, "UploadFiles", func() (*word.UploadFilesResponse, error) {
+		if len(req.GetFiles()) == 0 {
+			return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No files provided into request")
+		}
+
+		options := &ExtractOptions{CSVColumn: req.GetColumn()}
+		response := &word.UploadFilesResponse{}
+		for _, file := range req.GetFiles() {
+			result := s.ws.registerReader(file.GetName(), file.GetContentType(), bytes.NewReader(file.GetContent()), req.GetNamespace(), options)
+			response.Data = append(response.Data, &word.FileResult{
+				File:       result.File,
+				DocumentId: result.DocumentID,
+				Words:      int32(result.Words),
+				Counted:    result.Counted,
+				Duplicate:  duplicateMessage(result.Duplicate),
+				Message:    result.Message,
+			})
+		}
+		return response, nil
+	})
+}
+
+func (s *DBGrpcServer) GetWordOccurrences(ctx context.Context, req *word.GetWordOccurrencesRequest) (*word.GetWordOccurrencesResponse, error) {
+	if len(req.GetTerms()) == 0 && req.GetRegex() == "" {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No words provided into request")
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultOccurrencesLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.ws.QueryOccurences(&OccurrenceQuery{
+		Terms:       req.GetTerms(),
+		Regex:       req.GetRegex(),
+		Sort:        req.GetSort(),
+		Order:       req.GetOrder(),
+		Limit:       limit,
+		Cursor:      req.GetCursor(),
+		Suggestions: req.GetSuggestions(),
+		Labels:      req.GetLabels(),
+		GroupBy:     req.GetGroupBy(),
+	})
+	if errors.Is(err, ErrSuggestionsDisabled) {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, err.Error())
+	}
+	if errors.Is(err, ErrQueryTooExpensive) {
+		s.logger.Warn("Rejecting query: ", err.Error())
+		return nil, grpcError(codes.ResourceExhausted, CodeQueryTooExpensive, err.Error())
+	}
+	if err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+	}
+
+	return &word.GetWordOccurrencesResponse{
+		Data:       wordMessages(page.Data),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *DBGrpcServer) GetWordOccurrencesBatch(ctx context.Context, req *word.GetWordOccurrencesBatchRequest) (*word.GetWordOccurrencesBatchResponse, error) {
+	terms := req.GetTerms()
+	if len(terms) == 0 {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No words provided into request")
+	}
+	if len(terms) > maxBatchTerms {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Batch cannot contain more than %d terms", maxBatchTerms))
+	}
+
+	counts := s.ws.GetOccurencesBatch(terms)
+	data := make(map[string]int32, len(counts))
+	for term, count := range counts {
+		data[term] = int32(count)
+	}
+	return &word.GetWordOccurrencesBatchResponse{Data: data}, nil
+}
+
+func (s *DBGrpcServer) SuggestWords(ctx context.Context, req *word.SuggestWordsRequest) (*word.SuggestWordsResponse, error) {
+	suggestions := s.ws.db.Suggestions()
+	if suggestions == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "The autocomplete is disabled")
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultSuggestLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit > repo.MaxSuggestions {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Limit cannot be greater than %d", repo.MaxSuggestions))
+	}
+
+	response := &word.SuggestWordsResponse{}
+	for _, suggestion := range suggestions.Suggest(strings.ToLower(req.GetPrefix()), limit) {
+		response.Data = append(response.Data, &word.Suggestion{Word: suggestion.Word, Occurrences: int32(suggestion.Occurrences)})
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) SimilarWords(ctx context.Context, req *word.SimilarWordsRequest) (*word.SimilarWordsResponse, error) {
+	similar := s.ws.db.Similar()
+	if similar == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, ErrSuggestionsDisabled.Error())
+	}
+
+	term := strings.ToLower(req.GetTerm())
+	if term == "" {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No word provided into request")
+	}
+
+	distance, err := grpcLimit(req.GetDistance(), repo.MaxEditDistance)
+	if err != nil {
+		return nil, err
+	}
+	if distance > repo.MaxEditDistance {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Distance cannot be greater than %d", repo.MaxEditDistance))
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultSimilarLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &word.SimilarWordsResponse{
+		Word: term,
+		Data: similarMessages(similar.Search(term, distance, s.ws.db.Get, limit)),
+	}, nil
+}
+
+func (s *DBGrpcServer) GetCooccurring(ctx context.Context, req *word.GetCooccurringRequest) (*word.GetCooccurringResponse, error) {
+	counter := s.ws.db.Cooccurrences()
+	if counter == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "Co-occurrence counting is disabled")
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultCooccurrenceLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	term := strings.ToLower(req.GetWord())
+	results, err := counter.Top(term, req.GetSort(), limit)
+	if err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+	}
+
+	response := &word.GetCooccurringResponse{Word: term}
+	for _, result := range results {
+		response.Data = append(response.Data, &word.Cooccurrence{Word: result.Word, Count: int32(result.Count), Pmi: result.PMI})
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) GetDocument(ctx context.Context, req *word.GetDocumentRequest) (*word.DocumentResponse, error) {
+	words, found := s.ws.db.GetDocument(req.GetId())
+	if !found {
+		return nil, grpcError(codes.NotFound, CodeDocumentNotFound, fmt.Sprintf("Document %s is not registered", req.GetId()))
+	}
+
+	return &word.DocumentResponse{Id: req.GetId(), Data: wordMessages(sortedWords(words))}, nil
+}
+
+func (s *DBGrpcServer) RetractDocument(ctx context.Context, req *word.RetractDocumentRequest) (*word.DocumentResponse, error) {
+	return idempotentCall(s, ctx, "RetractDocument", func() (*word.DocumentResponse, error) {
+		id := req.GetId()
+		words, err := s.ws.RetractDocument(id)
+		if errors.Is(err, repo.ErrDocumentNotFound) {
+			return nil, grpcError(codes.NotFound, CodeDocumentNotFound, fmt.Sprintf("Document %s is not registered", id))
+		}
+		if err != nil {
+			s.logger.Error("Cannot retract document: ", err)
+			return nil, grpcError(codes.Internal, CodeInternal, err.Error())
+		}
+
+		s.ws.forward(http.MethodDelete, apiPrefix+"/documents/"+url.PathEscape(id), nil, metadataValue(ctx, idempotencyKeyMetadata))
+
+		return &word.DocumentResponse{Id: id, Data: wordMessages(sortedWords(words))}, nil
+	})
+}
+
+func (s *DBGrpcServer) ListSynonyms(ctx context.Context, req *word.ListSynonymsRequest) (*word.ListSynonymsResponse, error) {
+	response := &word.ListSynonymsResponse{}
+	for _, synonym := range s.ws.db.Synonyms().All() {
+		response.Data = append(response.Data, synonymMessage(&synonym))
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) GetSynonym(ctx context.Context, req *word.GetSynonymRequest) (*word.Synonym, error) {
+	alias := normalizeAlias(req.GetAlias())
+	synonym, found := s.ws.db.Synonyms().Get(alias)
+	if !found {
+		return nil, grpcError(codes.NotFound, CodeSynonymNotFound, fmt.Sprintf("Synonym %s does not exist", alias))
+	}
+	return synonymMessage(synonym), nil
+}
+
+func (s *DBGrpcServer) PutSynonym(ctx context.Context, req *word.PutSynonymRequest) (*word.Synonym, error) {
+	return idempotentCall(s, ctx, "PutSynonym", func() (*word.Synonym, error) {
+		synonym, err := s.ws.saveSynonym(normalizeAlias(req.GetAlias()), &SynonymInput{Canonical: req.GetCanonical(), Mode: req.GetMode()})
+		if err != nil {
+			return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+		}
+
+		bodyBytes, err := json.Marshal(&SynonymInput{Canonical: synonym.Canonical, Mode: synonym.Mode})
+		if err != nil {
+			return nil, grpcError(codes.Internal, CodeInternal, err.Error())
+		}
+
+		s.ws.forward(http.MethodPut, apiPrefix+"/synonyms/"+url.PathEscape(synonym.Alias), bodyBytes, metadataValue(ctx, idempotencyKeyMetadata))
+		return synonymMessage(synonym), nil
+	})
+}
+
+func (s *DBGrpcServer) DeleteSynonym(ctx context.Context, req *word.DeleteSynonymRequest) (*word.Synonym, error) {
+	return idempotentCall(s, ctx, "DeleteSynonym", func() (*word.Synonym, error) {
+		alias := normalizeAlias(req.GetAlias())
+		synonym, err := s.ws.db.RemoveSynonym(alias)
+		if errors.Is(err, repo.ErrSynonymNotFound) {
+			return nil, grpcError(codes.NotFound, CodeSynonymNotFound, fmt.Sprintf("Synonym %s does not exist", alias))
+		}
+
+		s.ws.forward(http.MethodDelete, apiPrefix+"/synonyms/"+url.PathEscape(alias), nil, metadataValue(ctx, idempotencyKeyMetadata))
+		return synonymMessage(synonym), nil
+	})
+}
+
+func (s *DBGrpcServer) Search(ctx context.Context, req *word.SearchRequest) (*word.SearchResponse, error) {
+	index := s.ws.db.Index()
+	if index == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "The inverted index is disabled")
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// the documents were indexed with their ingest synonyms
+	terms := s.ws.tokenize(req.GetQuery())
+	if len(terms) == 0 {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No words provided into request")
+	}
+
+	results, err := index.Search(req.GetNamespace(), terms, req.GetScoring(), limit)
+	if err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+	}
+
+	response := &word.SearchResponse{}
+	for _, result := range results {
+		response.Data = append(response.Data, &word.SearchResult{DocumentId: result.DocumentID, Score: result.Score})
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) SearchPhrase(ctx context.Context, req *word.SearchPhraseRequest) (*word.SearchPhraseResponse, error) {
+	index := s.ws.db.Index()
+	if index == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "The inverted index is disabled")
+	}
+
+	limit, err := grpcLimit(req.GetLimit(), defaultSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	words := s.ws.tokenize(strings.Trim(req.GetPhrase(), `"' `))
+	if len(words) == 0 {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No words provided into phrase")
+	}
+
+	results, occurrences, err := index.PhraseSearch(req.GetNamespace(), words, limit)
+	if err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, err.Error())
+	}
+
+	response := &word.SearchPhraseResponse{Occurrences: int32(occurrences)}
+	for _, result := range results {
+		response.Data = append(response.Data, &word.PhraseResult{DocumentId: result.DocumentID, Occurrences: int32(result.Occurrences)})
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) GetPostings(ctx context.Context, req *word.GetPostingsRequest) (*word.GetPostingsResponse, error) {
+	index := s.ws.db.Index()
+	if index == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "The inverted index is disabled")
+	}
+
+	term := strings.ToLower(req.GetTerm())
+	if term == "" {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidRequest, "No word provided into request")
+	}
+
+	postings := index.Postings(req.GetNamespace(), term)
+	response := &word.GetPostingsResponse{Word: term, DocumentFrequency: int32(len(postings))}
+	for _, posting := range postings {
+		response.Data = append(response.Data, &word.Posting{DocumentId: posting.DocumentID, TermFrequency: int32(posting.TermFrequency)})
+	}
+	return response, nil
+}
+
+func (s *DBGrpcServer) GetJob(ctx context.Context, req *word.GetJobRequest) (*word.Job, error) {
+	job, found := s.ws.jobs.Get(req.GetId())
+	if !found {
+		return nil, grpcError(codes.NotFound, CodeJobNotFound, fmt.Sprintf("Job %s does not exist", req.GetId()))
+	}
+	return jobMessage(job), nil
+}
+
+func (s *DBGrpcServer) GetJobStats(ctx context.Context, req *word.GetJobStatsRequest) (*word.JobStats, error) {
+	stats := s.ws.jobs.Stats()
+	return &word.JobStats{
+		Queued:   int32(stats.Queued),
+		Running:  int32(stats.Running),
+		Capacity: int32(stats.Capacity),
+	}, nil
+}
+
+func (s *DBGrpcServer) GetDedupStats(ctx context.Context, req *word.GetDedupStatsRequest) (*word.DedupStats, error) {
+	if s.ws.dedup == nil {
+		return nil, grpcError(codes.Unimplemented, CodeFeatureDisabled, "The near duplicate detection is disabled")
+	}
+
+	stats := s.ws.dedup.Stats()
+	return &word.DedupStats{
+		Action:     stats.Action,
+		Threshold:  stats.Threshold,
+		Window:     int32(stats.Window),
+		Checked:    int32(stats.Checked),
+		Duplicates: int32(stats.Duplicates),
+		Skipped:    int32(stats.Skipped),
+	}, nil
+}
+
+func wordMessages(words []WordResponse) []*word.WordResponse {
+	messages := make([]*word.WordResponse, 0, len(words))
+	for _, w := range words {
+		message := &word.WordResponse{
+			Word:        w.Word,
+			Occurrences: int32(w.Occurrences),
+			Suggestions: similarMessages(w.Suggestions),
+		}
+		for _, group := range w.Groups {
+			message.Groups = append(message.Groups, &word.LabelGroup{Labels: group.Labels, Occurrences: int32(group.Occurrences)})
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func similarMessages(words []repo.SimilarWord) []*word.SimilarWord {
+	var messages []*word.SimilarWord
+	for _, w := range words {
+		messages = append(messages, &word.SimilarWord{Word: w.Word, Distance: int32(w.Distance), Occurrences: int32(w.Occurrences)})
+	}
+	return messages
+}
+
+func duplicateMessage(match *DuplicateMatch) *word.DuplicateMatch {
+	if match == nil {
+		return nil
+	}
+	return &word.DuplicateMatch{DocumentId: match.DocumentID, Similarity: match.Similarity}
+}
+
+func synonymMessage(synonym *repo.Synonym) *word.Synonym {
+	return &word.Synonym{Alias: synonym.Alias, Canonical: synonym.Canonical, Mode: synonym.Mode}
+}
+
+func jobMessage(job *Job) *word.Job {
+	message := &word.Job{
+		Id:        job.ID,
+		Status:    job.Status,
+		Words:     int32(job.Words),
+		Duplicate: duplicateMessage(job.Duplicate),
+		Message:   job.Message,
+		Created:   job.Created.UnixMilli(),
+		Updated:   job.Updated.UnixMilli(),
+	}
+	if job.Counted != nil {
+		message.Counted = *job.Counted
+	}
+	return message
+}
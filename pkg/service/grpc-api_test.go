@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	word "mem-db/pkg/proto/service"
+	repo "mem-db/pkg/repository"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// starts the gRPC server of a word service on an in-memory listener
+func newGrpcTestClient(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	ctx := getLoggerContext()
+
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	return dialGrpcTestServer(t, &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)})
+}
+
+func dialGrpcTestServer(t *testing.T, ws *wordService) *grpc.ClientConn {
+	t.Helper()
+	ctx := getLoggerContext()
+
+	server := NewDBGrpcServer(ctx, &config.ServiceOptions{ApiOptions: &config.ApiOptions{}}, ws)
+	lis := bufconn.Listen(1 << 20)
+	go server.server.Serve(lis)
+	t.Cleanup(func() { server.Stop(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Cannot connect to the gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGrpcRegisterAndQuery(t *testing.T) {
+	client := word.NewWordServiceClient(newGrpcTestClient(t))
+	ctx := context.Background()
+
+	registered, err := client.RegisterWords(ctx, &word.RegisterWordsRequest{Text: "Apple banana apple", DocumentId: "doc-1"})
+	if err != nil {
+		t.Fatalf("RegisterWords failed: %v", err)
+	}
+	if registered.Words != 3 || !registered.Counted || registered.DocumentId != "doc-1" {
+		t.Fatalf("Unexpected registration %+v", registered)
+	}
+
+	occurrences, err := client.GetWordOccurrences(ctx, &word.GetWordOccurrencesRequest{Terms: []string{"apple", "banana", "cherry"}})
+	if err != nil {
+		t.Fatalf("GetWordOccurrences failed: %v", err)
+	}
+	expected := map[string]int32{"apple": 2, "banana": 1, "cherry": 0}
+	for _, result := range occurrences.Data {
+		if expected[result.Word] != result.Occurrences {
+			t.Fatalf("Expected %v, got %v", expected, occurrences.Data)
+		}
+	}
+
+	batch, err := client.GetWordOccurrencesBatch(ctx, &word.GetWordOccurrencesBatchRequest{Terms: []string{"Apple"}})
+	if err != nil || batch.Data["Apple"] != 2 {
+		t.Fatalf("Expected 2 occurrences of Apple, got %v %v", batch, err)
+	}
+
+	_, err = client.RegisterWords(ctx, &word.RegisterWordsRequest{Text: "apple", DocumentId: "doc-1"})
+	if status.Code(err) != codes.AlreadyExists || errorReason(err) != CodeDocumentExists {
+		t.Fatalf("Expected AlreadyExists, got %v", err)
+	}
+
+	retracted, err := client.RetractDocument(ctx, &word.RetractDocumentRequest{Id: "doc-1"})
+	if err != nil || len(retracted.Data) != 2 {
+		t.Fatalf("Unexpected retraction %v %v", retracted, err)
+	}
+	_, err = client.GetDocument(ctx, &word.GetDocumentRequest{Id: "doc-1"})
+	if status.Code(err) != codes.NotFound || errorReason(err) != CodeDocumentNotFound {
+		t.Fatalf("Expected NotFound, got %v", err)
+	}
+
+	_, err = client.SuggestWords(ctx, &word.SuggestWordsRequest{Prefix: "ap"})
+	if status.Code(err) != codes.Unimplemented || errorReason(err) != CodeFeatureDisabled {
+		t.Fatalf("Expected Unimplemented, got %v", err)
+	}
+	_, err = client.GetWordOccurrences(ctx, &word.GetWordOccurrencesRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGrpcIdempotencyKey(t *testing.T) {
+	client := word.NewWordServiceClient(newGrpcTestClient(t))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), idempotencyKeyMetadata, "retry-1")
+
+	for i := 0; i < 2; i++ {
+		var header metadata.MD
+		if _, err := client.RegisterWords(ctx, &word.RegisterWordsRequest{Text: "kiwi"}, grpc.Header(&header)); err != nil {
+			t.Fatalf("RegisterWords failed: %v", err)
+		}
+		if replayed := len(header.Get("idempotent-replayed")) > 0; replayed != (i == 1) {
+			t.Fatalf("Unexpected replay header on call %d: %v", i, header)
+		}
+	}
+
+	occurrences, err := client.GetWordOccurrences(context.Background(), &word.GetWordOccurrencesRequest{Terms: []string{"kiwi"}})
+	if err != nil || occurrences.Data[0].Occurrences != 1 {
+		t.Fatalf("Expected the retried text to be counted once, got %v %v", occurrences, err)
+	}
+}
+
+func TestGrpcHealthAndReflection(t *testing.T) {
+	conn := newGrpcTestClient(t)
+	ctx := context.Background()
+
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: word.WordService_ServiceDesc.ServiceName})
+	if err != nil || health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected the word service to be serving, got %v %v", health, err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("Cannot open reflection stream: %v", err)
+	}
+	stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	response, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Reflection failed: %v", err)
+	}
+	found := false
+	for _, service := range response.GetListServicesResponse().GetService() {
+		found = found || service.Name == word.WordService_ServiceDesc.ServiceName
+	}
+	if !found {
+		t.Fatalf("Expected %s in the reflected services, got %v", word.WordService_ServiceDesc.ServiceName, response)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"io"
+	word "mem-db/pkg/proto/service"
+	repo "mem-db/pkg/repository"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// the end of a chunk is kept until the next one, unless it grows bigger than this
+	maxPendingChunk = 1 << 20
+
+	defaultWatchInterval = time.Second
+	minWatchInterval     = 100 * time.Millisecond
+	maxWatchTerms        = 1000
+)
+
+// counters of an ingest stream
+type ingestSummary struct {
+	messages int
+	lines    int
+	words    int
+	skipped  int
+}
+
+// every line is registered like the text of RegisterWords, the chunks are joined and
+// registered up to their last complete word
+func (s *DBGrpcServer) IngestStream(stream grpc.ClientStreamingServer[word.IngestStreamRequest, word.IngestStreamResponse]) error {
+	// the stream is one request, its words are charged to the quota of the client
+	addr := peerAddr(stream.Context())
+	if err := s.ws.allowWrite(addr); err != nil {
+		return limitError(err)
+	}
+
+	var summary ingestSummary
+	var pending string
+	var pendingLabels map[string]string
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		summary.messages++
+
+		if err := repo.ValidateLabels(req.GetLabels()); err != nil {
+			return grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Message %d: %v", summary.messages, err))
+		}
+
+		switch payload := req.GetPayload().(type) {
+		case *word.IngestStreamRequest_Line:
+			if err := s.ingest(addr, payload.Line, req.GetLabels(), false, &summary); err != nil {
+				return err
+			}
+			summary.lines++
+		case *word.IngestStreamRequest_Chunk:
+			var complete string
+			complete, pending = splitChunk(pending + payload.Chunk)
+			pendingLabels = req.GetLabels()
+			if err := s.ingest(addr, complete, pendingLabels, true, &summary); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the end of the stream completes the last word of the chunks
+	if err := s.ingest(addr, pending, pendingLabels, true, &summary); err != nil {
+		return err
+	}
+	s.ws.flushLines()
+
+	s.logger.Info(fmt.Sprintf("Ingest stream closed after %d messages, %d words", summary.messages, summary.words))
+	return stream.SendAndClose(&word.IngestStreamResponse{
+		Messages: int32(summary.messages),
+		Lines:    int32(summary.lines),
+		Words:    int32(summary.words),
+		Skipped:  int32(summary.skipped),
+	})
+}
+
+// the lines and the parts of the chunks are counted as plain words, the parts of the chunks
+// are not checked for near duplicates, they are not whole texts. The stream is closed once
+// the quota of the client is used.
+func (s *DBGrpcServer) ingest(addr net.Addr, text string, labels map[string]string, chunk bool, summary *ingestSummary) error {
+	if len(Tokenize(text)) == 0 {
+		return nil
+	}
+
+	registration, err := s.ws.registerCharged(addr, &TextInput{Text: text, Labels: labels, Force: chunk})
+	if limitErr := limitError(err); limitErr != nil {
+		return limitErr
+	}
+	if err != nil {
+		s.logger.Error("Cannot register streamed text: ", err)
+		return grpcError(codes.Internal, CodeInternal, err.Error())
+	}
+	if !registration.Counted {
+		summary.skipped++
+		return nil
+	}
+
+	summary.words += registration.Words
+	s.ws.forwardLine(text, labels)
+	return nil
+}
+
+// returns the text up to the last separator and the rest, which may be the beginning of a word
+func splitChunk(text string) (string, string) {
+	cut := strings.LastIndexFunc(text, isSeparator)
+	if cut < 0 {
+		if len(text) > maxPendingChunk {
+			return text, ""
+		}
+		return "", text
+	}
+
+	_, size := utf8.DecodeRuneInString(text[cut:])
+	if len(text)-cut-size > maxPendingChunk {
+		return text, ""
+	}
+	return text[:cut+size], text[cut+size:]
+}
+
+// the counts are compared every interval, so the updates of the same term are merged
+func (s *DBGrpcServer) WatchWords(req *word.WatchWordsRequest, stream grpc.ServerStreamingServer[word.WatchWordsResponse]) error {
+	var terms []string
+	for _, term := range req.GetTerms() {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return grpcError(codes.InvalidArgument, CodeInvalidRequest, "No words provided into request")
+	}
+	if len(terms) > maxWatchTerms {
+		return grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Cannot watch more than %d terms", maxWatchTerms))
+	}
+
+	interval := time.Duration(req.GetIntervalMs()) * time.Millisecond
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+	if interval < minWatchInterval {
+		return grpcError(codes.InvalidArgument, CodeInvalidRequest, fmt.Sprintf("Interval cannot be lower than %d ms", minWatchInterval.Milliseconds()))
+	}
+
+	counts := make(map[string]int, len(terms))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var changed []WordResponse
+		for _, term := range terms {
+			count := s.ws.occurrencesOf(term)
+			if previous, found := counts[term]; !found || previous != count {
+				counts[term] = count
+				changed = append(changed, WordResponse{Word: term, Occurrences: count})
+			}
+		}
+
+		if len(changed) > 0 {
+			if err := stream.Send(&word.WatchWordsResponse{Data: wordMessages(changed)}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	word "mem-db/pkg/proto/service"
+	ratelimit "mem-db/pkg/ratelimit"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitChunk(t *testing.T) {
+	complete, pending := splitChunk("the quick bro")
+	if complete != "the quick " || pending != "bro" {
+		t.Fatalf("Unexpected split %q %q", complete, pending)
+	}
+
+	complete, pending = splitChunk("brown")
+	if complete != "" || pending != "brown" {
+		t.Fatalf("Unexpected split %q %q", complete, pending)
+	}
+}
+
+func TestGrpcIngestStream(t *testing.T) {
+	client := word.NewWordServiceClient(newGrpcTestClient(t))
+	ctx := context.Background()
+
+	stream, err := client.IngestStream(ctx)
+	if err != nil {
+		t.Fatalf("Cannot open ingest stream: %v", err)
+	}
+	messages := []*word.IngestStreamRequest{
+		{Payload: &word.IngestStreamRequest_Line{Line: "error disk full"}},
+		{Payload: &word.IngestStreamRequest_Line{Line: "error timeout"}},
+		{Payload: &word.IngestStreamRequest_Chunk{Chunk: "the time"}},
+		{Payload: &word.IngestStreamRequest_Chunk{Chunk: "out happened "}},
+		{Payload: &word.IngestStreamRequest_Chunk{Chunk: "twice"}},
+	}
+	for _, message := range messages {
+		if err := stream.Send(message); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("IngestStream failed: %v", err)
+	}
+	if summary.Messages != 5 || summary.Lines != 2 || summary.Words != 9 {
+		t.Fatalf("Unexpected summary %+v", summary)
+	}
+
+	// the word split between the chunks is counted once
+	occurrences, err := client.GetWordOccurrencesBatch(ctx, &word.GetWordOccurrencesBatchRequest{Terms: []string{"error", "timeout", "time", "twice"}})
+	if err != nil {
+		t.Fatalf("GetWordOccurrencesBatch failed: %v", err)
+	}
+	expected := map[string]int32{"error": 2, "timeout": 2, "time": 0, "twice": 1}
+	for term, count := range expected {
+		if occurrences.Data[term] != count {
+			t.Fatalf("Expected %v, got %v", expected, occurrences.Data)
+		}
+	}
+}
+
+func TestGrpcIngestStreamForwardedInBatches(t *testing.T) {
+	ctx := getLoggerContext()
+	newDatabase := func() repo.DBService {
+		dir := t.TempDir()
+		return repo.NewDatabase(ctx, &config.Config{
+			WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+			SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+			IndexOptions:    config.IndexOptions{Enabled: true},
+		}, true)
+	}
+	logger := ctx.Value(log.LoggerKey).(log.Logger)
+
+	forwarded := make(chan *ForwardedRequest, 10)
+	master := &wordService{db: newDatabase(), logger: logger, forwarding: true, forwardingCh: forwarded}
+	master.lines = newLineBatch(master.forwardLines)
+	client := word.NewWordServiceClient(dialGrpcTestServer(t, master))
+
+	stream, err := client.IngestStream(context.Background())
+	if err != nil {
+		t.Fatalf("Cannot open ingest stream: %v", err)
+	}
+	for _, line := range []string{"error disk full", "error timeout"} {
+		stream.Send(&word.IngestStreamRequest{Payload: &word.IngestStreamRequest_Line{Line: line}})
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatalf("IngestStream failed: %v", err)
+	}
+
+	// the lines are plain words, not documents of the index
+	if postings := master.db.Index().Postings("", "error"); len(postings) != 0 {
+		t.Fatalf("Expected the lines not to be indexed, got %v", postings)
+	}
+	// the end of the stream forwards its lines in one request
+	if len(forwarded) != 1 {
+		t.Fatalf("Expected one request for the batch, got %d", len(forwarded))
+	}
+	request := <-forwarded
+	var batch forwardedLines
+	if err := json.Unmarshal(request.Payload, &batch); err != nil || len(batch.Lines) != 2 {
+		t.Fatalf("Unexpected batch %+v %v", batch, err)
+	}
+
+	worker := &wordService{db: newDatabase(), logger: logger}
+	handler := NewDBHttpServer(ctx, &config.ServiceOptions{ApiOptions: &config.ApiOptions{}}, worker).(*DBHttpServer).server.Router
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(request.Method, request.Endpoint, strings.NewReader(string(request.Payload))))
+	if w.Code != http.StatusOK || worker.occurrencesOf("error") != 2 {
+		t.Fatalf("Expected the worker to count the batch, got %d: %s", w.Code, w.Body.String())
+	}
+	if postings := worker.db.Index().Postings("", "error"); len(postings) != 0 {
+		t.Fatalf("Expected the worker not to index the lines, got %v", postings)
+	}
+}
+
+func TestGrpcIngestStreamLimits(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{
+		db:           db,
+		logger:       ctx.Value(log.LoggerKey).(log.Logger),
+		writeLimiter: ratelimit.NewLimiter(0.001, 1),
+		quotas:       ratelimit.NewQuotas(3, nil),
+	}
+	client := word.NewWordServiceClient(dialGrpcTestServer(t, ws))
+
+	ingest := func(lines ...string) error {
+		stream, err := client.IngestStream(context.Background())
+		if err != nil {
+			t.Fatalf("Cannot open ingest stream: %v", err)
+		}
+		for _, line := range lines {
+			stream.Send(&word.IngestStreamRequest{Payload: &word.IngestStreamRequest_Line{Line: line}})
+		}
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+
+	// the stream is closed once the quota is used, the lines before are counted
+	err := ingest("apple banana", "cherry date")
+	if status.Code(err) != codes.ResourceExhausted || errorReason(err) != CodeQuotaExceeded {
+		t.Fatalf("Expected the quota to be exceeded, got %v", err)
+	}
+	if ws.occurrencesOf("apple") != 1 || ws.occurrencesOf("cherry") != 0 {
+		t.Fatalf("Expected only the first line to be counted")
+	}
+
+	// a stream is one write request
+	if err := ingest("elderberry"); status.Code(err) != codes.ResourceExhausted || errorReason(err) != CodeRateLimited {
+		t.Fatalf("Expected the stream to be rate limited, got %v", err)
+	}
+}
+
+func TestGrpcWatchWords(t *testing.T) {
+	client := word.NewWordServiceClient(newGrpcTestClient(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.RegisterWords(ctx, &word.RegisterWordsRequest{Text: "apple"}); err != nil {
+		t.Fatalf("RegisterWords failed: %v", err)
+	}
+
+	stream, err := client.WatchWords(ctx, &word.WatchWordsRequest{Terms: []string{"apple", "banana"}, IntervalMs: 100})
+	if err != nil {
+		t.Fatalf("Cannot open watch stream: %v", err)
+	}
+
+	first, err := stream.Recv()
+	if err != nil || len(first.Data) != 2 || first.Data[0].Occurrences != 1 || first.Data[1].Occurrences != 0 {
+		t.Fatalf("Expected the counts of every term, got %v %v", first, err)
+	}
+
+	if _, err := client.RegisterWords(ctx, &word.RegisterWordsRequest{Text: "banana banana"}); err != nil {
+		t.Fatalf("RegisterWords failed: %v", err)
+	}
+	update, err := stream.Recv()
+	if err != nil || len(update.Data) != 1 || update.Data[0].Word != "banana" || update.Data[0].Occurrences != 2 {
+		t.Fatalf("Expected only the banana update, got %v %v", update, err)
+	}
+
+	stream, err = client.WatchWords(ctx, &word.WatchWordsRequest{Terms: []string{"apple"}, IntervalMs: 10})
+	if err != nil {
+		t.Fatalf("Cannot open watch stream: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for a short interval, got %v", err)
+	}
+}
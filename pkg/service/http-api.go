@@ -3,15 +3,49 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	api "mem-db/pkg/api"
+	"mime/multipart"
 	// httpclient "mem-db/pkg/api/http/client"
 	// "time"
 	httpserver "mem-db/pkg/api/http/server"
+	auth "mem-db/pkg/auth"
+	repo "mem-db/pkg/repository"
+	util "mem-db/pkg/util"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSearchLimit = 10
+
+const defaultCooccurrenceLimit = 20
+
+const defaultSuggestLimit = 10
+
+const defaultSimilarLimit = 10
+
+// prefix of the label filters of the occurrences queries
+const labelParamPrefix = "label."
+
+const (
+	maxBatchTerms = 100000
+	// limit of the decompressed batch body
+	maxBatchBodySize = 16 << 20
+)
+
+const (
+	// files bigger than this are stored in temporary files while parsing the upload
+	maxUploadMemory = 32 << 20
+	// used when maxUploadSize and maxFileSize are not configured
+	defaultMaxUploadSize = 64 << 20
+	defaultMaxFileSize   = 16 << 20
 )
 
 type DBHttpServer struct {
@@ -21,24 +55,203 @@ type DBHttpServer struct {
 
 type TextInput struct {
 	Text string `json:"text"`
+	// optional, documents registered with an id can be retracted
+	DocumentID string `json:"documentId,omitempty"`
+	// index namespace of the document, the default namespace is used when it's empty
+	Namespace string `json:"namespace,omitempty"`
+	// optional, the words are also counted for this set of labels, like source=twitter
+	Labels map[string]string `json:"labels,omitempty"`
+	// counts the text even when it's a near duplicate of a recent one
+	Force bool `json:"force,omitempty"`
+}
+
+// the words charged to the quota of the client
+func (t *TextInput) words() int {
+	return len(Tokenize(t.Text))
+}
+
+type DocumentResponse struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	ID         string         `json:"id,omitempty"`
+	Data       []WordResponse `json:"data,omitempty"`
+	Message    string         `json:"message,omitempty"`
+}
+
+type FileResponse struct {
+	File       string          `json:"file"`
+	DocumentID string          `json:"documentId,omitempty"`
+	Words      int             `json:"words"`
+	Counted    bool            `json:"counted"`
+	Duplicate  *DuplicateMatch `json:"duplicate,omitempty"`
+	Message    string          `json:"message,omitempty"`
+}
+
+type UploadResponse struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	Data       []FileResponse `json:"data,omitempty"`
+	Message    string         `json:"message,omitempty"`
 }
 
 type Response struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	DocumentID string         `json:"documentId,omitempty"`
+	Data       []WordResponse `json:"data,omitempty"`
+	// false when the text was skipped as a near duplicate
+	Counted   *bool           `json:"counted,omitempty"`
+	Duplicate *DuplicateMatch `json:"duplicate,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+type OccurrencesResponse struct {
 	Status     string         `json:"status"`
 	StatusCode int            `json:"statusCode"`
 	Data       []WordResponse `json:"data,omitempty"`
+	// passed as cursor to get the next page
+	NextCursor string `json:"nextCursor,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+type BatchResponse struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	Data       map[string]int `json:"data,omitempty"`
 	Message    string         `json:"message,omitempty"`
 }
 
+type CooccurrenceResponse struct {
+	Status     string              `json:"status"`
+	StatusCode int                 `json:"statusCode"`
+	Word       string              `json:"word,omitempty"`
+	Data       []repo.Cooccurrence `json:"data,omitempty"`
+	Message    string              `json:"message,omitempty"`
+}
+
+type SuggestResponse struct {
+	Status     string            `json:"status"`
+	StatusCode int               `json:"statusCode"`
+	Data       []repo.Suggestion `json:"data,omitempty"`
+	Message    string            `json:"message,omitempty"`
+}
+
+type SimilarResponse struct {
+	Status     string             `json:"status"`
+	StatusCode int                `json:"statusCode"`
+	Word       string             `json:"word,omitempty"`
+	Data       []repo.SimilarWord `json:"data,omitempty"`
+	Message    string             `json:"message,omitempty"`
+}
+
+type SynonymInput struct {
+	Canonical string `json:"canonical"`
+	// "ingest" (default) or "query"
+	Mode string `json:"mode,omitempty"`
+}
+
+type SynonymResponse struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	Data       []repo.Synonym `json:"data,omitempty"`
+	Message    string         `json:"message,omitempty"`
+}
+
+type SearchResponse struct {
+	Status     string              `json:"status"`
+	StatusCode int                 `json:"statusCode"`
+	Data       []repo.SearchResult `json:"data,omitempty"`
+	Message    string              `json:"message,omitempty"`
+}
+
+type PhraseResponse struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode"`
+	// occurrences of the phrase in the whole namespace
+	Occurrences int                 `json:"occurrences"`
+	Data        []repo.PhraseResult `json:"data,omitempty"`
+	Message     string              `json:"message,omitempty"`
+}
+
+type PostingsResponse struct {
+	Status            string         `json:"status"`
+	StatusCode        int            `json:"statusCode"`
+	Word              string         `json:"word,omitempty"`
+	DocumentFrequency int            `json:"documentFrequency"`
+	Data              []repo.Posting `json:"data,omitempty"`
+	Message           string         `json:"message,omitempty"`
+}
+
+type JobResponse struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode"`
+	Data       *Job   `json:"data,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+type DedupStatsResponse struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"statusCode"`
+	Data       *DedupStats `json:"data,omitempty"`
+	Message    string      `json:"message,omitempty"`
+}
+
+type JobStatsResponse struct {
+	Status     string    `json:"status"`
+	StatusCode int       `json:"statusCode"`
+	Data       *JobStats `json:"data,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
 func NewDBHttpServer(ctx context.Context, options *config.ServiceOptions, ws *wordService) api.Server {
 
 	dbHttpServer := &DBHttpServer{
-		server: httpserver.NewServer(ctx, options.ApiOptions.Port),
+		server: httpserver.NewServer(ctx, options.ApiOptions),
 		logger: ctx.Value(log.LoggerKey).(log.Logger),
 	}
 
-	dbHttpServer.server.Router.AddRoute("GET", "/words/occurences", ws.getWordOccurences)
-	dbHttpServer.server.Router.AddRoute("POST", "/words/register", ws.registerWords)
+	routes := []struct {
+		method  string
+		path    string
+		handler http.HandlerFunc
+		role    auth.Role
+	}{
+		{"GET", "/words/occurences", ws.getWordOccurences, auth.RoleReader},
+		{"POST", "/words/occurences:batch", ws.getWordOccurencesBatch, auth.RoleReader},
+		{"POST", "/words/register", ws.idempotent(ws.registerWords), auth.RoleWriter},
+		{"GET", "/words/suggest", ws.suggestWords, auth.RoleReader},
+		{"GET", "/words/similar", ws.similarWords, auth.RoleReader},
+		{"GET", "/words/{word}/cooccurring", ws.getCooccurring, auth.RoleReader},
+		{"DELETE", "/words/{word}", ws.idempotent(ws.deleteWord), auth.RoleWriter},
+		{"POST", "/words/upload", ws.idempotent(ws.uploadFiles), auth.RoleWriter},
+		{"GET", "/documents/{id}", ws.getDocument, auth.RoleReader},
+		{"DELETE", "/documents/{id}", ws.idempotent(ws.retractDocument), auth.RoleWriter},
+		{"GET", "/synonyms", ws.listSynonyms, auth.RoleReader},
+		{"GET", "/synonyms/{alias}", ws.getSynonym, auth.RoleReader},
+		// the synonyms change how every text is counted
+		{"PUT", "/synonyms/{alias}", ws.idempotent(ws.putSynonym), auth.RoleAdmin},
+		{"DELETE", "/synonyms/{alias}", ws.idempotent(ws.deleteSynonym), auth.RoleAdmin},
+		{"GET", "/search", ws.search, auth.RoleReader},
+		{"GET", "/index/postings", ws.getPostings, auth.RoleReader},
+		{"GET", "/jobs", ws.getJobStats, auth.RoleReader},
+		{"GET", "/jobs/{id}", ws.getJob, auth.RoleReader},
+		{"GET", "/dedup", ws.getDedupStats, auth.RoleReader},
+		{"GET", "/quotas", ws.getQuotas, auth.RoleReader},
+	}
+
+	// the unversioned paths answer every request with HTTP 200, they are kept for the old clients
+	r := dbHttpServer.server.Router
+	v1 := r.Group(apiPrefix)
+	for _, route := range routes {
+		handler := dbHttpServer.server.Auth.Require(route.role, r.WriteError)(ws.rateLimit(route.role)(route.handler)).ServeHTTP
+		v1.AddRoute(route.method, route.path, handler)
+		if options.LegacyAPIEnabled() {
+			r.AddRoute(route.method, route.path, handler)
+		}
+	}
+	// the batches of lines forwarded by the master, only the nodes send them
+	v1.AddRoute("POST", forwardedLinesPath, dbHttpServer.server.Auth.Require(auth.RoleAdmin, r.WriteError)(ws.rateLimit(auth.RoleAdmin)(ws.idempotent(ws.registerForwardedLines))).ServeHTTP)
+	r.SetErrorHandler(writeRouterError)
 
 	return dbHttpServer
 }
@@ -52,43 +265,111 @@ func (s *DBHttpServer) Stop(ctx context.Context) error {
 	return s.server.Stop(ctx)
 }
 
-// GET /words/occurences?terms=apple,banana,orange
+// GET /words/occurences?terms=apple,micro*&regex=^data.*s$&sort=count|word&order=asc|desc&limit=100&cursor=...&suggestions=true
 func (s *wordService) getWordOccurences(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	terms := query["terms"]
-
-	s.logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
 
-	if len(terms) == 0 {
+	if len(query["terms"]) == 0 && query.Get("regex") == "" {
 		s.logger.Error("No words provided into request")
-		json.NewEncoder(w).Encode(&Response{
-			Status:     "Bad Request",
-			StatusCode: http.StatusBadRequest,
-			Message:    "No words provided into request"})
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No words provided into request")
 		return
 	}
 
-	results := s.GetOccurences(terms[0])
+	limit, err := parseLimit(query.Get("limit"), defaultOccurrencesLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	var terms []string
+	for _, value := range query["terms"] {
+		terms = append(terms, strings.Split(value, ",")...)
+	}
+
+	// label.source=twitter keeps only the words registered with this label
+	labels := make(map[string]string)
+	for key, values := range query {
+		if name, found := strings.CutPrefix(key, labelParamPrefix); found {
+			labels[name] = values[0]
+		}
+	}
+	var groupBy []string
+	if value := query.Get("group_by"); value != "" {
+		groupBy = strings.Split(value, ",")
+	}
 
-	s.logger.Debug("Results of the request: ", results)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&Response{
+	page, err := s.QueryOccurences(&OccurrenceQuery{
+		Terms:       terms,
+		Regex:       query.Get("regex"),
+		Sort:        query.Get("sort"),
+		Order:       query.Get("order"),
+		Limit:       limit,
+		Cursor:      query.Get("cursor"),
+		Suggestions: query.Get("suggestions") == "true",
+		Labels:      labels,
+		GroupBy:     groupBy,
+	})
+	if errors.Is(err, ErrSuggestionsDisabled) {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, err.Error())
+		return
+	}
+	if errors.Is(err, ErrQueryTooExpensive) {
+		s.logger.Warn("Rejecting query: ", err.Error())
+		writeError(w, r, http.StatusUnprocessableEntity, CodeQueryTooExpensive, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	s.logger.Debug("Results of the request: ", page.Data)
+	writeResponse(w, r, http.StatusOK, &OccurrencesResponse{
 		Status:     "Success",
 		StatusCode: http.StatusOK,
-		Data:       results})
+		Data:       page.Data,
+		NextCursor: page.NextCursor})
 
 }
 
-func (s *wordService) registerWords(w http.ResponseWriter, r *http.Request) {
+// POST /words/occurences:batch ["apple","banana"], the request and the response can be gzip compressed
+func (s *wordService) getWordOccurencesBatch(w http.ResponseWriter, r *http.Request) {
+	writer, closeWriter := compressResponse(w, r)
+	defer closeWriter()
+
+	body, err := requestBody(r)
+	if err != nil {
+		writeError(writer, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	var terms []string
+	if err := json.NewDecoder(io.LimitReader(body, maxBatchBodySize)).Decode(&terms); err != nil {
+		s.logger.Error("Cannot decode incoming request: ", err)
+		writeError(writer, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Body must be a JSON array of terms: %v", err))
+		return
+	}
+
+	if len(terms) == 0 {
+		writeError(writer, r, http.StatusBadRequest, CodeInvalidRequest, "No words provided into request")
+		return
+	}
+	if len(terms) > maxBatchTerms {
+		writeError(writer, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Batch cannot contain more than %d terms", maxBatchTerms))
+		return
+	}
 
-	s.logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL))
+	writeResponse(writer, r, http.StatusOK, &BatchResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       s.GetOccurencesBatch(terms)})
+}
 
+func (s *wordService) registerWords(w http.ResponseWriter, r *http.Request) {
 	var bodyBytes []byte
 	if r.Body == nil {
-		json.NewEncoder(w).Encode(&Response{
-			Status:     "Bad Request",
-			StatusCode: http.StatusBadRequest,
-			Message:    "Body is empty"})
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Body is empty")
 		return
 	}
 
@@ -96,6 +377,7 @@ func (s *wordService) registerWords(w http.ResponseWriter, r *http.Request) {
 	bodyBytes, err = io.ReadAll(r.Body)
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("Error reading request body: %v", err))
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Cannot read request body: %v", err))
 		return
 	}
 
@@ -104,30 +386,674 @@ func (s *wordService) registerWords(w http.ResponseWriter, r *http.Request) {
 	err = json.Unmarshal(bodyBytes, &textInput)
 	if err != nil {
 		s.logger.Error("Cannot decode incoming request: ", err)
-		json.NewEncoder(w).Encode(&Response{
-			Status:     "Bad Request",
-			StatusCode: http.StatusBadRequest,
-			Message:    err.Error()})
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
 		return
 	}
 
 	s.logger.Debug("Data from request: ", textInput)
 	if len(textInput.Text) == 0 {
-		json.NewEncoder(w).Encode(&Response{
-			Status:     "Bad Request",
-			StatusCode: http.StatusBadRequest,
-			Message:    "Text field is empty"})
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Text field is empty")
+		return
+	}
+	if err := repo.ValidateLabels(textInput.Labels); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if !s.allowNamespace(w, r, textInput.Namespace) {
+		return
+	}
+	words := textInput.words()
+	if !s.reserveWords(w, r, words) {
 		return
 	}
 
-	if s.forwarding {
-		s.forwardingCh <- bodyBytes
+	// the index needs an id for every document
+	if textInput.DocumentID == "" && s.db.Index() != nil {
+		textInput.DocumentID = util.NewID()
 	}
 
-	s.RegisterWords(textInput.Text)
+	if r.URL.Query().Get("async") == "true" {
+		s.enqueueText(w, r, &textInput, words)
+		return
+	}
 
-	json.NewEncoder(w).Encode(&Response{
+	registration, err := s.registerText(&textInput)
+	if err != nil {
+		s.refundWords(r, words)
+	}
+	if errors.Is(err, repo.ErrDocumentExists) {
+		writeError(w, r, http.StatusConflict, CodeDocumentExists, fmt.Sprintf("Document %s is already registered", textInput.DocumentID))
+		return
+	}
+	if err != nil {
+		s.logger.Error("Cannot register document: ", err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	if !registration.Counted {
+		s.refundWords(r, words)
+		writeResponse(w, r, http.StatusOK, &Response{
+			Status:     "Success",
+			StatusCode: http.StatusOK,
+			Counted:    &registration.Counted,
+			Duplicate:  registration.Duplicate,
+			Message:    "Text skipped as a near duplicate"})
+		return
+	}
+
+	s.forwardText(&textInput, r.Header.Get(IdempotencyKeyHeader))
+
+	writeResponse(w, r, http.StatusOK, &Response{
 		Status:     "Success",
 		StatusCode: http.StatusOK,
+		DocumentID: textInput.DocumentID,
+		Counted:    &registration.Counted,
+		Duplicate:  registration.Duplicate,
 		Message:    "Text processed successfully"})
 }
+
+// POST /words/register?async=true, the text is registered in the background.
+// The words stay charged once the text is queued, even when it's skipped later.
+func (s *wordService) enqueueText(w http.ResponseWriter, r *http.Request, textInput *TextInput, words int) {
+	if textInput.DocumentID != "" {
+		if _, found := s.db.GetDocument(textInput.DocumentID); found {
+			s.refundWords(r, words)
+			writeError(w, r, http.StatusConflict, CodeDocumentExists, fmt.Sprintf("Document %s is already registered", textInput.DocumentID))
+			return
+		}
+	}
+
+	job, err := s.jobs.Enqueue(textInput)
+	if err != nil {
+		s.refundWords(r, words)
+	}
+	if errors.Is(err, ErrJobQueueFull) {
+		s.logger.Warn("Rejecting job: ", err.Error())
+		writeError(w, r, http.StatusServiceUnavailable, CodeQueueFull, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Cannot enqueue job: ", err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	writeResponse(w, r, http.StatusAccepted, &JobResponse{
+		Status:     "Accepted",
+		StatusCode: http.StatusAccepted,
+		Data:       job,
+		Message:    "Text queued for processing"})
+}
+
+// GET /jobs/{id}
+func (s *wordService) getJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	job, found := s.jobs.Get(id)
+	if !found {
+		writeError(w, r, http.StatusNotFound, CodeJobNotFound, fmt.Sprintf("Job %s does not exist", id))
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &JobResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       job})
+}
+
+// GET /jobs, depth of the job queue
+func (s *wordService) getJobStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.jobs.Stats()
+	writeResponse(w, r, http.StatusOK, &JobStatsResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       &stats})
+}
+
+// GET /dedup, counters of the near duplicate detection
+func (s *wordService) getDedupStats(w http.ResponseWriter, r *http.Request) {
+	if s.dedup == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "The near duplicate detection is disabled")
+		return
+	}
+
+	stats := s.dedup.Stats()
+	writeResponse(w, r, http.StatusOK, &DedupStatsResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       &stats})
+}
+
+// POST /words/upload (multipart/form-data, one or more files, optional "column" field for csv files
+// and "namespace" field for the index)
+func (s *wordService) uploadFiles(w http.ResponseWriter, r *http.Request) {
+	maxUploadSize, maxFileSize := s.maxUploadSize, s.maxFileSize
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+
+	// the body is cut before it fills the memory or the temporary files
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("Upload is larger than %d bytes", maxUploadSize))
+			return
+		}
+		s.logger.Error("Cannot parse multipart form: ", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	// nothing is registered when one of the files is too large
+	for _, fileHeaders := range r.MultipartForm.File {
+		for _, fileHeader := range fileHeaders {
+			if fileHeader.Size > maxFileSize {
+				writeError(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("File %s is larger than %d bytes", fileHeader.Filename, maxFileSize))
+				return
+			}
+		}
+	}
+
+	options := &ExtractOptions{CSVColumn: r.FormValue("column")}
+
+	// keep the order of the files deterministic
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if !s.allowNamespace(w, r, r.FormValue("namespace")) {
+		return
+	}
+	// the words of the files are only known once they're extracted, the last file may go over the quota
+	if !s.checkQuota(w, r) {
+		return
+	}
+
+	var results []FileResponse
+	for _, field := range fields {
+		for _, fileHeader := range r.MultipartForm.File[field] {
+			result := s.registerFile(fileHeader, r.FormValue("namespace"), options)
+			s.chargeWords(r, result.Words)
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No files provided into request")
+		return
+	}
+
+	s.logger.Debug("Results of the upload: ", results)
+	writeResponse(w, r, http.StatusOK, &UploadResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       results})
+}
+
+func (s *wordService) registerFile(fileHeader *multipart.FileHeader, namespace string, options *ExtractOptions) FileResponse {
+	result := FileResponse{File: fileHeader.Filename}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		s.logger.Error("Cannot open uploaded file: ", err)
+		result.Message = err.Error()
+		return result
+	}
+	defer file.Close()
+
+	return s.registerReader(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), file, namespace, options)
+}
+
+// extracts the text of an uploaded file and registers it
+func (s *wordService) registerReader(filename, contentType string, file io.Reader, namespace string, options *ExtractOptions) FileResponse {
+	result := FileResponse{File: filename}
+
+	text, err := ExtractText(filename, contentType, file, options)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Cannot extract text from %s: %v", filename, err))
+		result.Message = err.Error()
+		return result
+	}
+
+	textInput := &TextInput{Text: text, Namespace: namespace}
+	if s.db.Index() != nil {
+		textInput.DocumentID = util.NewID()
+	}
+
+	registration, err := s.registerText(textInput)
+	if err != nil {
+		s.logger.Error("Cannot register document: ", err)
+		result.Message = err.Error()
+		return result
+	}
+	result.Words = registration.Words
+	result.Counted = registration.Counted
+	result.Duplicate = registration.Duplicate
+	if !registration.Counted {
+		return result
+	}
+
+	result.DocumentID = textInput.DocumentID
+	s.forwardText(textInput, "")
+	return result
+}
+
+// GET /documents/{id}
+func (s *wordService) getDocument(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.allowDocuments(w, r) {
+		return
+	}
+
+	words, found := s.db.GetDocument(id)
+	if !found {
+		writeError(w, r, http.StatusNotFound, CodeDocumentNotFound, fmt.Sprintf("Document %s is not registered", id))
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &DocumentResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		ID:         id,
+		Data:       sortedWords(words)})
+}
+
+// DELETE /documents/{id}
+func (s *wordService) retractDocument(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.allowDocuments(w, r) {
+		return
+	}
+
+	words, err := s.RetractDocument(id)
+	if errors.Is(err, repo.ErrDocumentNotFound) {
+		writeError(w, r, http.StatusNotFound, CodeDocumentNotFound, fmt.Sprintf("Document %s is not registered", id))
+		return
+	}
+	if err != nil {
+		s.logger.Error("Cannot retract document: ", err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	s.forward(http.MethodDelete, apiPrefix+"/documents/"+url.PathEscape(id), nil, r.Header.Get(IdempotencyKeyHeader))
+
+	writeResponse(w, r, http.StatusOK, &DocumentResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		ID:         id,
+		Data:       sortedWords(words),
+		Message:    "Document retracted successfully"})
+}
+
+// DELETE /words/{word}, the word is no longer counted
+func (s *wordService) deleteWord(w http.ResponseWriter, r *http.Request) {
+	word, ok := s.singleWord(r.PathValue("word"))
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Path must name a single word")
+		return
+	}
+	if !s.allowWords(w, r) {
+		return
+	}
+
+	count := s.DeleteWord(word, r.Header.Get(IdempotencyKeyHeader))
+	if count == 0 {
+		writeError(w, r, http.StatusNotFound, CodeWordNotFound, fmt.Sprintf("Word %s is not counted", word))
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &Response{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       []WordResponse{{Word: word, Occurrences: count}},
+		Message:    "Word deleted successfully"})
+}
+
+func sortedWords(words map[string]int) []WordResponse {
+	response := make([]WordResponse, 0, len(words))
+	for word, occurrences := range words {
+		response = append(response, WordResponse{Word: word, Occurrences: occurrences})
+	}
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].Word < response[j].Word
+	})
+	return response
+}
+
+// GET /words/suggest?prefix=dat&limit=10, the most frequent words starting with prefix
+func (s *wordService) suggestWords(w http.ResponseWriter, r *http.Request) {
+	suggestions := s.db.Suggestions()
+	if suggestions == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "The autocomplete is disabled")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"), defaultSuggestLimit)
+	if err == nil && limit > repo.MaxSuggestions {
+		err = fmt.Errorf("Limit cannot be greater than %d", repo.MaxSuggestions)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	prefix := strings.ToLower(r.URL.Query().Get("prefix"))
+	writeResponse(w, r, http.StatusOK, &SuggestResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       suggestions.Suggest(prefix, limit)})
+}
+
+// GET /words/similar?term=databse&distance=1|2&limit=10, the known words closest to term
+func (s *wordService) similarWords(w http.ResponseWriter, r *http.Request) {
+	similar := s.db.Similar()
+	if similar == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, ErrSuggestionsDisabled.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	term := strings.ToLower(query.Get("term"))
+	if term == "" {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No word provided into request")
+		return
+	}
+
+	distance, err := parseLimit(query.Get("distance"), repo.MaxEditDistance)
+	if err == nil && distance > repo.MaxEditDistance {
+		err = fmt.Errorf("Distance cannot be greater than %d", repo.MaxEditDistance)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(query.Get("limit"), defaultSimilarLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &SimilarResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Word:       term,
+		Data:       similar.Search(term, distance, s.db.Get, limit)})
+}
+
+// GET /words/{word}/cooccurring?limit=20&sort=count|pmi
+func (s *wordService) getCooccurring(w http.ResponseWriter, r *http.Request) {
+	counter := s.db.Cooccurrences()
+	if counter == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "Co-occurrence counting is disabled")
+		return
+	}
+
+	word := strings.ToLower(r.PathValue("word"))
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"), defaultCooccurrenceLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	results, err := counter.Top(word, r.URL.Query().Get("sort"), limit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &CooccurrenceResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Word:       word,
+		Data:       results})
+}
+
+// GET /synonyms
+func (s *wordService) listSynonyms(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, http.StatusOK, &SynonymResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       s.db.Synonyms().All()})
+}
+
+func synonymAlias(r *http.Request) string {
+	return normalizeAlias(r.PathValue("alias"))
+}
+
+// aliases are normalized like the words of the texts
+func normalizeAlias(alias string) string {
+	return strings.Join(Tokenize(alias), " ")
+}
+
+// GET /synonyms/{alias}
+func (s *wordService) getSynonym(w http.ResponseWriter, r *http.Request) {
+	alias := synonymAlias(r)
+	synonym, found := s.db.Synonyms().Get(alias)
+	if !found {
+		writeError(w, r, http.StatusNotFound, CodeSynonymNotFound, fmt.Sprintf("Synonym %s does not exist", alias))
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &SynonymResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       []repo.Synonym{*synonym}})
+}
+
+// PUT /synonyms/{alias} {"canonical": "color", "mode": "ingest|query"}
+func (s *wordService) putSynonym(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Error reading request body: %v", err))
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Cannot read request body: %v", err))
+		return
+	}
+
+	var input SynonymInput
+	if err := json.Unmarshal(bodyBytes, &input); err != nil {
+		s.logger.Error("Cannot decode incoming request: ", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	synonym, err := s.saveSynonym(synonymAlias(r), &input)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	s.forward(http.MethodPut, apiPrefix+"/synonyms/"+url.PathEscape(synonym.Alias), bodyBytes, r.Header.Get(IdempotencyKeyHeader))
+
+	writeResponse(w, r, http.StatusOK, &SynonymResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       []repo.Synonym{*synonym},
+		Message:    "Synonym saved successfully"})
+}
+
+// the alias must be normalized already
+func (s *wordService) saveSynonym(alias string, input *SynonymInput) (*repo.Synonym, error) {
+	synonym := &repo.Synonym{
+		Alias:     alias,
+		Canonical: normalizeAlias(input.Canonical),
+		Mode:      input.Mode,
+	}
+	if synonym.Mode == "" {
+		synonym.Mode = repo.SynonymIngest
+	}
+
+	if err := s.db.PutSynonym(synonym); err != nil {
+		return nil, err
+	}
+	return synonym, nil
+}
+
+// DELETE /synonyms/{alias}
+func (s *wordService) deleteSynonym(w http.ResponseWriter, r *http.Request) {
+	alias := synonymAlias(r)
+	synonym, err := s.db.RemoveSynonym(alias)
+	if errors.Is(err, repo.ErrSynonymNotFound) {
+		writeError(w, r, http.StatusNotFound, CodeSynonymNotFound, fmt.Sprintf("Synonym %s does not exist", alias))
+		return
+	}
+
+	s.forward(http.MethodDelete, apiPrefix+"/synonyms/"+url.PathEscape(alias), nil, r.Header.Get(IdempotencyKeyHeader))
+
+	writeResponse(w, r, http.StatusOK, &SynonymResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       []repo.Synonym{*synonym},
+		Message:    "Synonym deleted successfully"})
+}
+
+// GET /search?q=quick+brown&scoring=bm25|tfidf&limit=10&namespace=default
+// GET /search?phrase="quick brown fox"&limit=10&namespace=news
+func (s *wordService) search(w http.ResponseWriter, r *http.Request) {
+	index := s.db.Index()
+	if index == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "The inverted index is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parseLimit(query.Get("limit"), defaultSearchLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if !s.allowNamespace(w, r, query.Get("namespace")) {
+		return
+	}
+
+	if query.Has("phrase") {
+		s.searchPhrase(w, r, index, query.Get("namespace"), query.Get("phrase"), limit)
+		return
+	}
+
+	// the documents were indexed with their ingest synonyms
+	terms := s.tokenize(query.Get("q"))
+	if len(terms) == 0 {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No words provided into request")
+		return
+	}
+
+	results, err := index.Search(query.Get("namespace"), terms, query.Get("scoring"), limit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &SearchResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       results})
+}
+
+func (s *wordService) searchPhrase(w http.ResponseWriter, r *http.Request, index *repo.InvertedIndex, namespace, phrase string, limit int) {
+	words := s.tokenize(strings.Trim(phrase, `"' `))
+	if len(words) == 0 {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No words provided into phrase")
+		return
+	}
+
+	results, occurrences, err := index.PhraseSearch(namespace, words, limit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, &PhraseResponse{
+		Status:      "Success",
+		StatusCode:  http.StatusOK,
+		Occurrences: occurrences,
+		Data:        results})
+}
+
+// GET /index/postings?term=apple&namespace=default
+func (s *wordService) getPostings(w http.ResponseWriter, r *http.Request) {
+	index := s.db.Index()
+	if index == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "The inverted index is disabled")
+		return
+	}
+
+	term := strings.ToLower(r.URL.Query().Get("term"))
+	if term == "" {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No word provided into request")
+		return
+	}
+
+	if !s.allowNamespace(w, r, r.URL.Query().Get("namespace")) {
+		return
+	}
+
+	postings := index.Postings(r.URL.Query().Get("namespace"), term)
+	writeResponse(w, r, http.StatusOK, &PostingsResponse{
+		Status:            "Success",
+		StatusCode:        http.StatusOK,
+		Word:              term,
+		DocumentFrequency: len(postings),
+		Data:              postings})
+}
+
+// answers 403 when the client is scoped to other index namespaces
+func (s *wordService) allowNamespace(w http.ResponseWriter, r *http.Request, namespace string) bool {
+	if namespace == "" {
+		namespace = repo.DefaultNamespace
+	}
+	if auth.FromContext(r.Context()).CanAccess(namespace) {
+		return true
+	}
+
+	s.auth.Deny(r, "namespace "+namespace)
+	writeError(w, r, http.StatusForbidden, CodeForbidden, fmt.Sprintf("Cannot use namespace %s", namespace))
+	return false
+}
+
+// the registry doesn't keep the namespaces of the documents, so only the clients
+// allowed to use every namespace can read or retract them by id
+func (s *wordService) allowDocuments(w http.ResponseWriter, r *http.Request) bool {
+	if !auth.FromContext(r.Context()).Scoped() {
+		return true
+	}
+
+	s.auth.Deny(r, "documents by id")
+	writeError(w, r, http.StatusForbidden, CodeForbidden, "Documents can only be accessed with a key for every namespace")
+	return false
+}
+
+// the counts of the words are shared by every namespace, so only the clients allowed to use
+// every namespace and the admins can delete them
+func (s *wordService) allowWords(w http.ResponseWriter, r *http.Request) bool {
+	if principal := auth.FromContext(r.Context()); !principal.Scoped() || principal.Role == auth.RoleAdmin {
+		return true
+	}
+
+	s.auth.Deny(r, "delete word")
+	writeError(w, r, http.StatusForbidden, CodeForbidden, "Words can only be deleted with a key for every namespace")
+	return false
+}
+
+// returns defaultLimit when the parameter is missing
+func parseLimit(value string, defaultLimit int) (int, error) {
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("Invalid limit %s", value)
+	}
+	return limit, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	auth "mem-db/pkg/auth"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serves the routes of a word service which requires API keys
+func newAuthTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	ctx := getLoggerContext()
+
+	authenticator, err := auth.NewAuthenticator(ctx, &config.AuthOptions{
+		Enabled: true,
+		Keys: []config.APIKey{
+			{Name: "reports", Key: "reader-key-for-tests", Role: "reader"},
+			{Name: "producer", Key: "news-key-for-tests", Role: "writer", Namespaces: []string{"news"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Cannot create authenticator: %v", err)
+	}
+	ctx = context.WithValue(ctx, auth.AuthenticatorKey, authenticator)
+
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger), auth: authenticator}
+
+	server := NewDBHttpServer(ctx, &config.ServiceOptions{ApiOptions: &config.ApiOptions{}}, ws)
+	return server.(*DBHttpServer).server.Router
+}
+
+func TestHttpAuthorization(t *testing.T) {
+	handler := newAuthTestHandler(t)
+
+	tests := []struct {
+		method, path, key, body string
+		statusCode              int
+		code                    string
+	}{
+		{"GET", "/v1/words/occurences?terms=apple", "", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"GET", "/v1/words/occurences?terms=apple", "wrong-key", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"GET", "/v1/words/occurences?terms=apple", "reader-key-for-tests", "", http.StatusOK, ""},
+		{"POST", "/v1/words/register", "reader-key-for-tests", `{"text":"apple"}`, http.StatusForbidden, CodeForbidden},
+		{"POST", "/v1/words/register", "news-key-for-tests", `{"text":"apple"}`, http.StatusForbidden, CodeForbidden},
+		{"POST", "/v1/words/register", "news-key-for-tests", `{"text":"apple","namespace":"news"}`, http.StatusOK, ""},
+		{"GET", "/v1/documents/doc-1", "news-key-for-tests", "", http.StatusForbidden, CodeForbidden},
+		{"DELETE", "/v1/words/apple", "news-key-for-tests", "", http.StatusForbidden, CodeForbidden},
+		{"PUT", "/v1/synonyms/colour", "news-key-for-tests", `{"canonical":"color"}`, http.StatusForbidden, CodeForbidden},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.key != "" {
+			r.Header.Set("Authorization", "Bearer "+test.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Fatalf("Expected %d for %s %s with %q, got %d: %s", test.statusCode, test.method, test.path, test.key, w.Code, w.Body.String())
+		}
+		if test.code == "" {
+			continue
+		}
+		var problem Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != test.code {
+			t.Fatalf("Expected code %s for %s %s, got %+v %v", test.code, test.method, test.path, problem, err)
+		}
+	}
+}
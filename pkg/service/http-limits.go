@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	router "mem-db/pkg/api/http/router"
+	auth "mem-db/pkg/auth"
+	ratelimit "mem-db/pkg/ratelimit"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type QuotasResponse struct {
+	Status     string            `json:"status"`
+	StatusCode int               `json:"statusCode"`
+	Data       []ratelimit.Usage `json:"data,omitempty"`
+	Message    string            `json:"message,omitempty"`
+}
+
+// rounded up to seconds, as the Retry-After header expects
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+}
+
+// rejects with 429 the clients which sent too many requests, the routes of the readers use the read limit
+func (s *wordService) rateLimit(role auth.Role) router.Middleware {
+	limiter := s.writeLimiter
+	if role == auth.RoleReader {
+		limiter = s.readLimiter
+	}
+
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := ratelimit.Client(r)
+			if allowed, wait := limiter.Allow(client); !allowed {
+				s.logger.Debug(fmt.Sprintf("Rate limited %s for %s %s", client, r.Method, r.URL.Path))
+				setRetryAfter(w, wait)
+				writeError(w, r, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// the requests of the other nodes are recognized by their principal, or by the nodeKey they send
+// when the authentication is disabled
+func (s *wordService) exempt(r *http.Request) bool {
+	return ratelimit.Exempt(r, s.nodeKey)
+}
+
+// charges the words to the daily quota of the client, false when the request was rejected
+func (s *wordService) reserveWords(w http.ResponseWriter, r *http.Request, words int) bool {
+	if s.quotas == nil || s.exempt(r) {
+		return true
+	}
+	return s.allowQuota(w, r, s.quotas.Reserve(ratelimit.Client(r), int64(words)))
+}
+
+// gives back the reserved words which were not counted
+func (s *wordService) refundWords(r *http.Request, words int) {
+	if s.quotas != nil && words > 0 && !s.exempt(r) {
+		s.quotas.Refund(ratelimit.Client(r), int64(words))
+	}
+}
+
+// rejects the clients without words left, the words of the request are charged after
+func (s *wordService) checkQuota(w http.ResponseWriter, r *http.Request) bool {
+	if s.quotas == nil || s.exempt(r) {
+		return true
+	}
+	return s.allowQuota(w, r, s.quotas.Check(ratelimit.Client(r)))
+}
+
+func (s *wordService) chargeWords(r *http.Request, words int) {
+	if s.quotas != nil && !s.exempt(r) {
+		s.quotas.Add(ratelimit.Client(r), int64(words))
+	}
+}
+
+func (s *wordService) allowQuota(w http.ResponseWriter, r *http.Request, err error) bool {
+	var quotaErr *ratelimit.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return true
+	}
+	s.logger.Warn(err.Error())
+	setRetryAfter(w, quotaErr.RetryAfter)
+	writeError(w, r, http.StatusTooManyRequests, CodeQuotaExceeded, err.Error())
+	return false
+}
+
+// GET /quotas, the admins get the usage of every client, the other clients their own
+func (s *wordService) getQuotas(w http.ResponseWriter, r *http.Request) {
+	if s.quotas == nil {
+		writeError(w, r, http.StatusNotImplemented, CodeFeatureDisabled, "The daily quotas are disabled")
+		return
+	}
+
+	var usages []ratelimit.Usage
+	if auth.FromContext(r.Context()).Role == auth.RoleAdmin {
+		usages = s.quotas.All()
+	} else {
+		usages = []ratelimit.Usage{s.quotas.Usage(ratelimit.Client(r))}
+	}
+
+	writeResponse(w, r, http.StatusOK, &QuotasResponse{
+		Status:     "Success",
+		StatusCode: http.StatusOK,
+		Data:       usages})
+}
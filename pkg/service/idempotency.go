@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	repo "mem-db/pkg/repository"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// set on the responses replayed for a retried request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// keeps a copy of the response written by a handler
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	// status inside the body of the legacy responses, which are sent with HTTP 200
+	bodyStatus int
+	body       bytes.Buffer
+}
+
+func (w *recordingWriter) status() int {
+	if w.bodyStatus != 0 {
+		return w.bodyStatus
+	}
+	return w.statusCode
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// requests retried with the same Idempotency-Key get the original response
+// instead of being applied again
+func (s *wordService) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		// the same key can be used for different endpoints
+		requestKey := r.Method + " " + r.URL.Path + " " + key
+
+		stored, err := s.db.BeginRequest(requestKey)
+		if errors.Is(err, repo.ErrRequestInProgress) {
+			writeError(w, r, http.StatusConflict, CodeRequestInProgress, err.Error())
+			return
+		}
+
+		if stored != nil {
+			s.logger.Debug("Replaying response of request with idempotency key ", key)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next(recorder, r)
+
+		// rejected and failed requests can be retried, their answer can depend on the
+		// credentials or the limits of the moment
+		status := recorder.status()
+		if status >= http.StatusBadRequest || recorder.body.Len() == 0 {
+			s.db.ReleaseRequest(requestKey)
+			return
+		}
+		s.db.CompleteRequest(requestKey, recorder.statusCode, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+	}
+}
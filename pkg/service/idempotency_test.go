@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/json"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdempotentLegacyFailure(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)}
+
+	// fails inside the body of a legacy response, sent with HTTP 200
+	handler := ws.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "Cannot write the WAL")
+	})
+	send := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/words/register", strings.NewReader(`{"text":"apple"}`))
+		r.Header.Set(IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	var response Response
+	w := send(handler)
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || w.Code != http.StatusOK || response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected a legacy 500, got %d %+v %v", w.Code, response, err)
+	}
+
+	// the retry is applied, not replayed
+	w = send(ws.idempotent(ws.registerWords))
+	if w.Header().Get(IdempotentReplayedHeader) != "" || db.Get("apple") != 1 {
+		t.Fatalf("Expected the retry to be applied, got %s", w.Body.String())
+	}
+	w = send(ws.idempotent(ws.registerWords))
+	if w.Header().Get(IdempotentReplayedHeader) != "true" || db.Get("apple") != 1 {
+		t.Fatalf("Expected the second retry to be replayed, got %s", w.Body.String())
+	}
+}
+
+func TestIdempotentReplayKeepsContentType(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)}
+
+	send := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", apiPrefix+"/words/register", strings.NewReader(`{"text":"apple"}`))
+		r.Header.Set(IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	// rejected with the credentials of the moment, not stored
+	w := send(ws.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusForbidden, CodeForbidden, "Forbidden")
+	}))
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("Expected a 403 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	applied := send(ws.idempotent(ws.registerWords))
+	if applied.Header().Get(IdempotentReplayedHeader) != "" || db.Get("apple") != 1 {
+		t.Fatalf("Expected the retry to be applied, got %d %s", applied.Code, applied.Body.String())
+	}
+
+	replayed := send(ws.idempotent(ws.registerWords))
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" || db.Get("apple") != 1 {
+		t.Fatalf("Expected the second retry to be replayed, got %s", replayed.Body.String())
+	}
+	if replayed.Code != applied.Code || replayed.Header().Get("Content-Type") != applied.Header().Get("Content-Type") {
+		t.Fatalf("Expected the replay to match %d %s, got %d %s", applied.Code, applied.Header().Get("Content-Type"),
+			replayed.Code, replayed.Header().Get("Content-Type"))
+	}
+}
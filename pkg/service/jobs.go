@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	util "mem-db/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+
+	defaultJobQueueSize = 100
+	defaultJobWorkers   = 1
+	// finished jobs are kept for lookups, they are purged after this
+	jobRetention = 24 * time.Hour
+	// how often the expired jobs are purged while the node runs
+	jobPurgeInterval = time.Hour
+)
+
+var ErrJobQueueFull = errors.New("The job queue is full, try again later")
+
+type Job struct {
+	ID     string     `json:"id"`
+	Status string     `json:"status"`
+	Input  *TextInput `json:"input,omitempty"`
+	Words  int        `json:"words"`
+	// set once the job is done, false when the text was skipped as a near duplicate
+	Counted   *bool           `json:"counted,omitempty"`
+	Duplicate *DuplicateMatch `json:"duplicate,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Created   time.Time       `json:"created"`
+	Updated   time.Time       `json:"updated"`
+}
+
+type JobStats struct {
+	Queued   int `json:"queued"`
+	Running  int `json:"running"`
+	Capacity int `json:"capacity"`
+}
+
+// JobQueue is a bounded queue of texts registered in the background.
+// Every job is saved in its own file, so the queued jobs survive restarts.
+type JobQueue struct {
+	mutex   sync.Mutex
+	jobs    map[string]*Job
+	queue   chan string
+	dirPath string
+	workers int
+	running int
+	logger  log.Logger
+}
+
+func NewJobQueue(ctx context.Context, options *config.JobOptions) (*JobQueue, error) {
+	queueSize := options.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultJobQueueSize
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = defaultJobWorkers
+	}
+
+	q := &JobQueue{
+		jobs:    make(map[string]*Job),
+		queue:   make(chan string, queueSize),
+		dirPath: options.DirPath,
+		workers: workers,
+		logger:  ctx.Value(log.LoggerKey).(log.Logger),
+	}
+
+	if q.dirPath == "" {
+		return q, nil
+	}
+	if err := os.MkdirAll(q.dirPath, 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create jobs directory: %v", err)
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// restores the jobs saved before the restart
+func (q *JobQueue) load() error {
+	entries, err := os.ReadDir(q.dirPath)
+	if err != nil {
+		return fmt.Errorf("Cannot read jobs directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		jobPath := filepath.Join(q.dirPath, entry.Name())
+
+		data, err := os.ReadFile(jobPath)
+		if err != nil {
+			return fmt.Errorf("Cannot read job %s: %v", entry.Name(), err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			q.logger.Warn(fmt.Sprintf("Skipping corrupted job file %s: %v", entry.Name(), err))
+			continue
+		}
+
+		switch job.Status {
+		case JobQueued:
+			if len(q.queue) == cap(q.queue) {
+				q.fail(&job, "The job queue was full after restart")
+				break
+			}
+			q.queue <- job.ID
+		case JobRunning:
+			// the words may have been partially counted, running it again would count them twice
+			q.fail(&job, "The job was interrupted by a restart, its words may be partially counted")
+		default:
+			if time.Since(job.Updated) > jobRetention {
+				os.Remove(jobPath)
+				continue
+			}
+		}
+		q.jobs[job.ID] = &job
+	}
+
+	q.logger.Info(fmt.Sprintf("Restored %d jobs, %d queued", len(q.jobs), len(q.queue)))
+	return nil
+}
+
+func (q *JobQueue) Enqueue(input *TextInput) (*Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.queue) == cap(q.queue) {
+		return nil, ErrJobQueueFull
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:      util.NewID(),
+		Status:  JobQueued,
+		Input:   input,
+		Created: now,
+		Updated: now,
+	}
+
+	// the job is saved before it's accepted
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+
+	q.jobs[job.ID] = job
+	q.queue <- job.ID
+	return job.view(), nil
+}
+
+// returns a copy of the job without its text
+func (q *JobQueue) Get(id string) (*Job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, found := q.jobs[id]
+	if !found {
+		return nil, false
+	}
+	return job.view(), true
+}
+
+func (q *JobQueue) Stats() JobStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return JobStats{
+		Queued:   len(q.queue),
+		Running:  q.running,
+		Capacity: cap(q.queue),
+	}
+}
+
+// starts the workers which process the jobs until the context is done
+func (q *JobQueue) Start(ctx context.Context, process func(job *Job) (*Registration, error)) {
+	for i := 0; i < q.workers; i++ {
+		go q.worker(ctx, process)
+	}
+	go q.purgeRoutine(ctx)
+}
+
+func (q *JobQueue) purgeRoutine(ctx context.Context) {
+	ticker := time.NewTicker(jobPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.purge(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drops the jobs which finished more than jobRetention before now, with their files
+func (q *JobQueue) purge(now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	purged := 0
+	for id, job := range q.jobs {
+		if job.Status != JobDone && job.Status != JobFailed {
+			continue
+		}
+		if now.Sub(job.Updated) <= jobRetention {
+			continue
+		}
+		if q.dirPath != "" {
+			if err := os.Remove(filepath.Join(q.dirPath, id+".json")); err != nil && !os.IsNotExist(err) {
+				q.logger.Error(fmt.Sprintf("Cannot remove job %s: %v", id, err))
+				continue
+			}
+		}
+		delete(q.jobs, id)
+		purged++
+	}
+
+	if purged > 0 {
+		q.logger.Debug(fmt.Sprintf("Purged %d expired jobs", purged))
+	}
+}
+
+func (q *JobQueue) worker(ctx context.Context, process func(job *Job) (*Registration, error)) {
+	for {
+		select {
+		case id := <-q.queue:
+			q.run(id, process)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *JobQueue) run(id string, process func(job *Job) (*Registration, error)) {
+	q.mutex.Lock()
+	job := q.jobs[id]
+	job.Status = JobRunning
+	job.Updated = time.Now()
+	q.running++
+	if err := q.save(job); err != nil {
+		q.logger.Error(err)
+	}
+	q.mutex.Unlock()
+
+	registration, err := process(job)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.running--
+	if err != nil {
+		q.fail(job, err.Error())
+		return
+	}
+
+	job.Words = registration.Words
+	job.Counted = &registration.Counted
+	job.Duplicate = registration.Duplicate
+
+	job.Status = JobDone
+	job.Updated = time.Now()
+	// the text is not needed anymore
+	job.Input = nil
+	if err := q.save(job); err != nil {
+		q.logger.Error(err)
+	}
+}
+
+func (q *JobQueue) fail(job *Job, message string) {
+	job.Status = JobFailed
+	job.Message = message
+	job.Updated = time.Now()
+	job.Input = nil
+	if err := q.save(job); err != nil {
+		q.logger.Error(err)
+	}
+}
+
+// writes the job into a temporary file first, so a crash never leaves a partial file
+func (q *JobQueue) save(job *Job) error {
+	if q.dirPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("Cannot encode job %s: %v", job.ID, err)
+	}
+
+	jobPath := filepath.Join(q.dirPath, job.ID+".json")
+	if err := os.WriteFile(jobPath+".tmp", data, 0666); err != nil {
+		return fmt.Errorf("Cannot save job %s: %v", job.ID, err)
+	}
+	if err := os.Rename(jobPath+".tmp", jobPath); err != nil {
+		return fmt.Errorf("Cannot save job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+func (job *Job) view() *Job {
+	view := *job
+	view.Input = nil
+	return &view
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func getLoggerContext() context.Context {
+	logger, _ := log.NewConsoleLogger(&log.LoggerOptions{LogLevel: "error", Console: true})
+	return context.WithValue(context.Background(), log.LoggerKey, logger)
+}
+
+func waitForJob(t *testing.T, q *JobQueue, id string) *Job {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := q.Get(id)
+		if job.Status == JobDone || job.Status == JobFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return nil
+}
+
+func TestJobQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(getLoggerContext())
+	defer cancel()
+
+	q, err := NewJobQueue(ctx, &config.JobOptions{DirPath: t.TempDir(), QueueSize: 2})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+
+	first, _ := q.Enqueue(&TextInput{Text: "one two three"})
+	second, _ := q.Enqueue(&TextInput{Text: "fail"})
+	if _, err := q.Enqueue(&TextInput{Text: "too many"}); !errors.Is(err, ErrJobQueueFull) {
+		t.Fatalf("Expected full queue error, got %v", err)
+	}
+	if stats := q.Stats(); stats.Queued != 2 || stats.Capacity != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	q.Start(ctx, func(job *Job) (*Registration, error) {
+		if job.Input.Text == "fail" {
+			return nil, errors.New("Cannot register text")
+		}
+		return &Registration{Words: len(Tokenize(job.Input.Text)), Counted: true}, nil
+	})
+
+	if job := waitForJob(t, q, first.ID); job.Status != JobDone || job.Words != 3 {
+		t.Fatalf("Expected done job with 3 words, got %+v", job)
+	}
+	if job := waitForJob(t, q, second.ID); job.Status != JobFailed || job.Message == "" {
+		t.Fatalf("Expected failed job, got %+v", job)
+	}
+}
+
+func TestJobQueueRestore(t *testing.T) {
+	ctx := getLoggerContext()
+	options := &config.JobOptions{DirPath: t.TempDir()}
+
+	q, _ := NewJobQueue(ctx, options)
+	queued, _ := q.Enqueue(&TextInput{Text: "queued"})
+	interrupted, _ := q.Enqueue(&TextInput{Text: "interrupted"})
+
+	// simulate a crash while the second job was running
+	q.jobs[interrupted.ID].Status = JobRunning
+	if err := q.save(q.jobs[interrupted.ID]); err != nil {
+		t.Fatalf("Cannot save job: %v", err)
+	}
+
+	restored, err := NewJobQueue(ctx, options)
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+
+	if job, _ := restored.Get(queued.ID); job.Status != JobQueued {
+		t.Fatalf("Expected queued job, got %+v", job)
+	}
+	if job, _ := restored.Get(interrupted.ID); job.Status != JobFailed {
+		t.Fatalf("Expected failed job, got %+v", job)
+	}
+	if stats := restored.Stats(); stats.Queued != 1 {
+		t.Fatalf("Expected 1 queued job, got %+v", stats)
+	}
+}
+
+func TestJobQueuePurge(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+
+	q, _ := NewJobQueue(ctx, &config.JobOptions{DirPath: dir})
+	finished, _ := q.Enqueue(&TextInput{Text: "finished"})
+	queued, _ := q.Enqueue(&TextInput{Text: "queued"})
+	q.fail(q.jobs[finished.ID], "Cannot register text")
+
+	// nothing expired yet
+	q.purge(time.Now())
+	if _, found := q.Get(finished.ID); !found {
+		t.Fatalf("Expected the finished job to be kept")
+	}
+
+	q.purge(time.Now().Add(jobRetention + time.Minute))
+	if _, found := q.Get(finished.ID); found {
+		t.Fatalf("Expected the finished job to be purged")
+	}
+	if _, err := os.Stat(filepath.Join(dir, finished.ID+".json")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the file of the finished job to be removed, got %v", err)
+	}
+	if job, found := q.Get(queued.ID); !found || job.Status != JobQueued {
+		t.Fatalf("Expected the queued job to be kept, got %+v", job)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	line "mem-db/pkg/api/line"
+	"net"
+)
+
+// DBLineServer counts every line like the text of POST /words/register
+type DBLineServer struct {
+	server *line.LineServer
+	ws     *wordService
+	logger log.Logger
+}
+
+func NewDBLineServer(ctx context.Context, options *config.ServiceOptions, ws *wordService) *DBLineServer {
+	s := &DBLineServer{
+		ws:     ws,
+		logger: ctx.Value(log.LoggerKey).(log.Logger),
+	}
+	s.server = line.NewServer(ctx, &options.LineOptions, s)
+	return s
+}
+
+func (s *DBLineServer) Start() error {
+	s.logger.Info("Starting line server for WordService")
+	return s.server.Start()
+}
+
+func (s *DBLineServer) Stop(ctx context.Context) error {
+	return s.server.Stop(ctx)
+}
+
+// a connection is one write request of the producer
+func (s *DBLineServer) Accept(addr net.Addr) error {
+	return s.ws.allowWrite(addr)
+}
+
+// the lines without words and the near duplicates don't write to the WAL,
+// the words of every line are charged to the quota of the producer
+func (s *DBLineServer) Ingest(addr net.Addr, text string) (int64, error) {
+	if len(Tokenize(text)) == 0 {
+		return 0, nil
+	}
+
+	// the lines are counted as plain words, not as documents
+	registration, err := s.ws.registerCharged(addr, &TextInput{Text: text})
+	if limited(err) {
+		return 0, err
+	}
+	if err != nil {
+		s.logger.Error("Cannot register line: ", err)
+		return 0, err
+	}
+	if !registration.Counted {
+		return 0, nil
+	}
+
+	s.ws.forwardLine(text, nil)
+	written, _ := s.ws.db.WALPosition()
+	return written, nil
+}
+
+func (s *DBLineServer) Committed() int64 {
+	_, committed := s.ws.db.WALPosition()
+	return committed
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	ratelimit "mem-db/pkg/ratelimit"
+	repo "mem-db/pkg/repository"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLineIngestWALPosition(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)}
+	server := NewDBLineServer(ctx, &config.ServiceOptions{}, ws)
+
+	position, err := server.Ingest(nil, "Apple banana apple")
+	if err != nil {
+		t.Fatalf("Cannot ingest line: %v", err)
+	}
+	if position == 0 || ws.occurrencesOf("apple") != 2 {
+		t.Fatalf("Expected the line to be counted and written, got position %d", position)
+	}
+	if position, err := server.Ingest(nil, "  , "); err != nil || position != 0 {
+		t.Fatalf("Expected a line without words to be skipped, got %d %v", position, err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for server.Committed() < position {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected position %d to be committed, got %d", position, server.Committed())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestLineIngestForwardedInBatches(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+		IndexOptions:    config.IndexOptions{Enabled: true},
+	}, true)
+
+	forwarded := make(chan *ForwardedRequest, 10)
+	master := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger), forwarding: true, forwardingCh: forwarded}
+	master.lines = newLineBatch(master.forwardLines)
+	server := NewDBLineServer(ctx, &config.ServiceOptions{}, master)
+
+	for _, line := range []string{"error disk full", "error timeout", "  "} {
+		if _, err := server.Ingest(nil, line); err != nil {
+			t.Fatalf("Cannot ingest line: %v", err)
+		}
+	}
+	// the lines are plain words, not documents of the index
+	if postings := db.Index().Postings("", "error"); len(postings) != 0 {
+		t.Fatalf("Expected the lines not to be indexed, got %v", postings)
+	}
+	if len(forwarded) != 0 {
+		t.Fatalf("Expected the lines to wait for the batch, got %d requests", len(forwarded))
+	}
+
+	master.flushLines()
+	if len(forwarded) != 1 {
+		t.Fatalf("Expected one request for the batch, got %d", len(forwarded))
+	}
+	request := <-forwarded
+	var batch forwardedLines
+	if err := json.Unmarshal(request.Payload, &batch); err != nil || len(batch.Lines) != 2 || request.Endpoint != apiPrefix+forwardedLinesPath {
+		t.Fatalf("Unexpected batch %+v %s %v", batch, request.Endpoint, err)
+	}
+}
+
+func TestLineIngestLimits(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{
+		db:           db,
+		logger:       ctx.Value(log.LoggerKey).(log.Logger),
+		writeLimiter: ratelimit.NewLimiter(0.001, 1),
+		quotas:       ratelimit.NewQuotas(3, nil),
+	}
+	server := NewDBLineServer(ctx, &config.ServiceOptions{}, ws)
+	producer := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+	other := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 40000}
+
+	// a connection is one request of the producer
+	if err := server.Accept(producer); err != nil {
+		t.Fatalf("Expected the first connection to be accepted, got %v", err)
+	}
+	if err := server.Accept(&net.TCPAddr{IP: producer.IP, Port: 40001}); err == nil {
+		t.Fatalf("Expected the second connection of the producer to be rate limited")
+	}
+	if err := server.Accept(other); err != nil {
+		t.Fatalf("Expected another producer to have its own limit, got %v", err)
+	}
+
+	// the words of the lines are charged to the quota of the producer
+	if _, err := server.Ingest(producer, "apple banana"); err != nil {
+		t.Fatalf("Cannot ingest line: %v", err)
+	}
+	var quotaErr *ratelimit.QuotaError
+	if _, err := server.Ingest(producer, "cherry date"); !errors.As(err, &quotaErr) {
+		t.Fatalf("Expected the quota to be exceeded, got %v", err)
+	}
+	if ws.occurrencesOf("cherry") != 0 {
+		t.Fatalf("Expected the rejected line not to be counted")
+	}
+	if _, err := server.Ingest(other, "cherry date"); err != nil {
+		t.Fatalf("Expected another producer to have its own quota, got %v", err)
+	}
+}
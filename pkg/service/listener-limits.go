@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	ratelimit "mem-db/pkg/ratelimit"
+	"net"
+)
+
+// errRateLimited is sent to the clients of the gRPC, RESP and line listeners which sent too many writes
+var errRateLimited = errors.New("Too many requests, retry later")
+
+// true when the client of a listener sent too many writes or used its quota
+func limited(err error) bool {
+	var quotaErr *ratelimit.QuotaError
+	return errors.Is(err, errRateLimited) || errors.As(err, &quotaErr)
+}
+
+// takes a write token of the client of a listener, a gRPC call or stream, a line connection
+// and a RESP command are one request. The listeners don't check the credentials, so the clients are their ip.
+func (s *wordService) allowWrite(addr net.Addr) error {
+	if allowed, _ := s.writeLimiter.Allow(ratelimit.ClientAddr(addr)); !allowed {
+		return errRateLimited
+	}
+	return nil
+}
+
+// charges the words to the daily quota of the client of a listener
+func (s *wordService) reserveFor(addr net.Addr, words int) error {
+	if s.quotas == nil {
+		return nil
+	}
+	return s.quotas.Reserve(ratelimit.ClientAddr(addr), int64(words))
+}
+
+// gives back the reserved words which were not counted
+func (s *wordService) refundFor(addr net.Addr, words int) {
+	if s.quotas != nil && words > 0 {
+		s.quotas.Refund(ratelimit.ClientAddr(addr), int64(words))
+	}
+}
+
+// registers a text sent to a listener, its words are charged to the daily quota of the client
+// unless they are not counted
+func (s *wordService) registerCharged(addr net.Addr, input *TextInput) (*Registration, error) {
+	if s.quotas == nil {
+		return s.registerText(input)
+	}
+
+	words := input.words()
+	if err := s.reserveFor(addr, words); err != nil {
+		return nil, err
+	}
+	registration, err := s.registerText(input)
+	if err != nil || !registration.Counted {
+		s.refundFor(addr, words)
+	}
+	return registration, err
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	repo "mem-db/pkg/repository"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	SortByWord  = "word"
+	SortByCount = "count"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	defaultOccurrencesLimit = 100
+	maxOccurrencesLimit     = 1000
+	// used when maxScanWords is not configured
+	defaultMaxScanWords = 1000000
+	// pattern queries sort all their matches, so the matches are bounded too
+	maxPatternMatches = 100000
+	maxRegexLength    = 256
+	// spelling suggestions returned for every word which is not counted
+	maxSpellingSuggestions = 5
+)
+
+var (
+	ErrQueryTooExpensive   = errors.New("Query is too expensive")
+	ErrSuggestionsDisabled = errors.New("The spelling suggestions are disabled")
+)
+
+// OccurrenceQuery selects words by exact terms, wildcard patterns (micro*, colo?r)
+// and a regular expression. A word is returned when it matches any of them.
+type OccurrenceQuery struct {
+	Terms []string
+	Regex string
+	Sort  string
+	Order string
+	Limit int
+	// opaque position returned with the previous page
+	Cursor string
+	// adds the closest known words to the exact terms which are not counted
+	Suggestions bool
+	// only the words registered with these labels are counted
+	Labels map[string]string
+	// splits the occurrences by the values of these labels
+	GroupBy []string
+}
+
+type OccurrencePage struct {
+	Data []WordResponse
+	// empty on the last page
+	NextCursor string
+}
+
+type occurrenceCursor struct {
+	Word  string `json:"w"`
+	Count int    `json:"c"`
+	// the cursor can only be used with the query it was created for
+	Query uint64 `json:"q"`
+}
+
+// returns the words matching the query, sorted and paginated.
+// Exact terms are returned even when they are not counted, the query synonyms
+// are applied to them but not to the words matched by the patterns.
+func (s *wordService) QueryOccurences(query *OccurrenceQuery) (*OccurrencePage, error) {
+	if err := normalizeQuery(query); err != nil {
+		return nil, err
+	}
+
+	exact := make(map[string]struct{})
+	var patterns []*regexp.Regexp
+	for _, term := range query.Terms {
+		if strings.ContainsAny(term, "*?") {
+			patterns = append(patterns, globToRegexp(term))
+			continue
+		}
+		exact[term] = struct{}{}
+	}
+	if query.Regex != "" {
+		if len(query.Regex) > maxRegexLength {
+			return nil, fmt.Errorf("Regex is longer than %d characters", maxRegexLength)
+		}
+		re, err := regexp.Compile(query.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regex: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	if len(exact) == 0 && len(patterns) == 0 {
+		return nil, errors.New("No words provided into request")
+	}
+
+	var similar *repo.BKTree
+	if query.Suggestions {
+		if similar = s.db.Similar(); similar == nil {
+			return nil, ErrSuggestionsDisabled
+		}
+	}
+
+	results := make([]WordResponse, 0, len(exact))
+	for word := range exact {
+		results = append(results, WordResponse{Word: word, Occurrences: s.occurrencesOf(word)})
+	}
+
+	if len(patterns) > 0 {
+		matches, err := s.scan(patterns, exact)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, matches...)
+	}
+
+	// the label-less queries return the totals
+	if len(query.Labels) > 0 || len(query.GroupBy) > 0 {
+		results = s.countLabelled(results, exact, query)
+	}
+
+	less := occurrenceOrder(query.Sort, query.Order)
+	sort.Slice(results, func(i, j int) bool {
+		return less(&results[i], &results[j])
+	})
+
+	fingerprint := queryFingerprint(query)
+	start := 0
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		last := &WordResponse{Word: cursor.Word, Occurrences: cursor.Count}
+		start = sort.Search(len(results), func(i int) bool {
+			return less(last, &results[i])
+		})
+	}
+
+	page := &OccurrencePage{Data: results[start:]}
+	if len(page.Data) > query.Limit {
+		page.Data = page.Data[:query.Limit]
+		last := page.Data[len(page.Data)-1]
+		page.NextCursor = encodeCursor(&occurrenceCursor{Word: last.Word, Count: last.Occurrences, Query: fingerprint})
+	}
+
+	// only the exact terms can be returned without occurrences
+	for i := range page.Data {
+		if similar != nil && page.Data[i].Occurrences == 0 {
+			page.Data[i].Suggestions = similar.Search(page.Data[i].Word, repo.MaxEditDistance, s.db.Get, maxSpellingSuggestions)
+		}
+	}
+	return page, nil
+}
+
+func normalizeQuery(query *OccurrenceQuery) error {
+	terms := query.Terms[:0]
+	for _, term := range query.Terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	query.Terms = terms
+
+	if query.Sort == "" {
+		query.Sort = SortByWord
+	}
+	if query.Sort != SortByWord && query.Sort != SortByCount {
+		return fmt.Errorf("Unknown sort %s", query.Sort)
+	}
+
+	// the most frequent words come first by default
+	if query.Order == "" {
+		query.Order = OrderAsc
+		if query.Sort == SortByCount {
+			query.Order = OrderDesc
+		}
+	}
+	if query.Order != OrderAsc && query.Order != OrderDesc {
+		return fmt.Errorf("Unknown order %s", query.Order)
+	}
+
+	for _, name := range query.GroupBy {
+		if name == "" {
+			return errors.New("Grouped label name cannot be empty")
+		}
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultOccurrencesLimit
+	}
+	if query.Limit > maxOccurrencesLimit {
+		return fmt.Errorf("Limit cannot be greater than %d", maxOccurrencesLimit)
+	}
+	return nil
+}
+
+// walks the database once, the scan stops when it exceeds its budget
+func (s *wordService) scan(patterns []*regexp.Regexp, exact map[string]struct{}) ([]WordResponse, error) {
+	maxScanWords := s.maxScanWords
+	if maxScanWords <= 0 {
+		maxScanWords = defaultMaxScanWords
+	}
+
+	var matches []WordResponse
+	var err error
+	scanned := 0
+	s.db.Range(func(word string, count int) bool {
+		scanned++
+		if scanned > maxScanWords {
+			err = fmt.Errorf("%w: more than %d words would be scanned", ErrQueryTooExpensive, maxScanWords)
+			return false
+		}
+
+		if _, found := exact[word]; found {
+			return true
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(word) {
+				matches = append(matches, WordResponse{Word: word, Occurrences: count})
+				break
+			}
+		}
+
+		if len(matches) > maxPatternMatches {
+			err = fmt.Errorf("%w: more than %d words match, use a narrower pattern", ErrQueryTooExpensive, maxPatternMatches)
+			return false
+		}
+		return true
+	})
+	return matches, err
+}
+
+// replaces the totals by the counts of the matching label sets,
+// the words matched by the patterns are dropped when they have none
+func (s *wordService) countLabelled(results []WordResponse, exact map[string]struct{}, query *OccurrenceQuery) []WordResponse {
+	labelled := results[:0]
+	for _, result := range results {
+		occurrences, groups := s.labelledOccurrencesOf(result.Word, query.Labels, query.GroupBy)
+		if _, found := exact[result.Word]; !found && occurrences == 0 {
+			continue
+		}
+		labelled = append(labelled, WordResponse{Word: result.Word, Occurrences: occurrences, Groups: groups})
+	}
+	return labelled
+}
+
+// * matches any sequence of characters and ? matches a single character
+func globToRegexp(glob string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(glob)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// words are unique, so the order is total and stays the same between pages
+func occurrenceOrder(sortBy, order string) func(a, b *WordResponse) bool {
+	desc := order == OrderDesc
+	if sortBy == SortByCount {
+		return func(a, b *WordResponse) bool {
+			if a.Occurrences != b.Occurrences {
+				return (a.Occurrences < b.Occurrences) != desc
+			}
+			return a.Word < b.Word
+		}
+	}
+	return func(a, b *WordResponse) bool {
+		return (a.Word < b.Word) != desc
+	}
+}
+
+func queryFingerprint(query *OccurrenceQuery) uint64 {
+	terms := append([]string(nil), query.Terms...)
+	sort.Strings(terms)
+	labels, _ := json.Marshal(query.Labels)
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(terms, ",") + "\x00" + query.Regex + "\x00" + query.Sort + "\x00" + query.Order))
+	h.Write([]byte("\x00" + string(labels) + "\x00" + strings.Join(query.GroupBy, ",")))
+	return h.Sum64()
+}
+
+func encodeCursor(cursor *occurrenceCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string, fingerprint uint64) (*occurrenceCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	var cursor occurrenceCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	if cursor.Query != fingerprint {
+		return nil, errors.New("Cursor does not belong to this query")
+	}
+	return &cursor, nil
+}
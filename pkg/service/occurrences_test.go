@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	log "mem-db/cmd/logger"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// in-memory database with only the methods needed by the queries
+type mapDB struct {
+	repo.DBService
+	words    map[string]int
+	synonyms *repo.SynonymTable
+	labels   *repo.LabelCounts
+}
+
+func (db *mapDB) Synonyms() *repo.SynonymTable {
+	return db.synonyms
+}
+
+func (db *mapDB) Labels() *repo.LabelCounts {
+	return db.labels
+}
+
+func (db *mapDB) Get(word string) int {
+	return db.words[word]
+}
+
+func (db *mapDB) Range(f func(word string, count int) bool) {
+	for word, count := range db.words {
+		if !f(word, count) {
+			return
+		}
+	}
+}
+
+func newQueryService(words map[string]int) *wordService {
+	return &wordService{db: &mapDB{words: words, synonyms: repo.NewSynonymTable(), labels: repo.NewLabelCounts()}}
+}
+
+func wordsOf(results []WordResponse) []string {
+	words := make([]string, len(results))
+	for i, result := range results {
+		words[i] = result.Word
+	}
+	return words
+}
+
+func assertWords(t *testing.T, expected []string, results []WordResponse) {
+	t.Helper()
+	actual := wordsOf(results)
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, actual)
+		}
+	}
+}
+
+func TestQueryOccurencesPatterns(t *testing.T) {
+	s := newQueryService(map[string]int{"microsoft": 3, "microbe": 1, "data": 2, "datasets": 5, "colour": 1})
+
+	page, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"Micro*", "apple"}})
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	// exact terms are returned even when they are not counted
+	assertWords(t, []string{"apple", "microbe", "microsoft"}, page.Data)
+
+	page, _ = s.QueryOccurences(&OccurrenceQuery{Regex: "^data.*s$", Terms: []string{"colo?r"}})
+	assertWords(t, []string{"colour", "datasets"}, page.Data)
+
+	if _, err := s.QueryOccurences(&OccurrenceQuery{Regex: "("}); err == nil {
+		t.Fatalf("Expected invalid regex error")
+	}
+}
+
+func TestQueryOccurencesSortAndCursor(t *testing.T) {
+	s := newQueryService(map[string]int{"a": 1, "b": 3, "c": 3, "d": 2, "e": 5})
+
+	query := &OccurrenceQuery{Terms: []string{"*"}, Sort: SortByCount, Limit: 2}
+	page, err := s.QueryOccurences(query)
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	assertWords(t, []string{"e", "b"}, page.Data)
+
+	var words []string
+	words = append(words, wordsOf(page.Data)...)
+	for page.NextCursor != "" {
+		page, err = s.QueryOccurences(&OccurrenceQuery{Terms: []string{"*"}, Sort: SortByCount, Limit: 2, Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatalf("QueryOccurences failed: %v", err)
+		}
+		words = append(words, wordsOf(page.Data)...)
+	}
+
+	expected := []string{"e", "b", "c", "d", "a"}
+	if len(words) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, words)
+	}
+	for i := range expected {
+		if words[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, words)
+		}
+	}
+
+	// a cursor cannot be used with another query
+	first, _ := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"*"}, Limit: 1})
+	if _, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"*"}, Order: OrderDesc, Cursor: first.NextCursor}); err == nil {
+		t.Fatalf("Expected cursor error")
+	}
+}
+
+func TestQueryOccurencesTooExpensive(t *testing.T) {
+	s := newQueryService(map[string]int{"a": 1, "b": 1, "c": 1})
+	s.maxScanWords = 2
+
+	if _, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"a*"}}); !errors.Is(err, ErrQueryTooExpensive) {
+		t.Fatalf("Expected too expensive error, got %v", err)
+	}
+
+	// exact terms don't scan the database
+	if _, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"a", "b", "c"}}); err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+}
+
+func TestGetWordOccurencesBatchGzip(t *testing.T) {
+	s := newQueryService(map[string]int{"apple": 2, "banana": 1})
+	s.logger = getLoggerContext().Value(log.LoggerKey).(log.Logger)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(`["apple","Banana","cherry"]`))
+	gz.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/words/occurences:batch", &body)
+	r.Header.Set("Content-Encoding", "gzip")
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.getWordOccurencesBatch(w, r)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip response")
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Cannot decompress response: %v", err)
+	}
+
+	var response BatchResponse
+	if err := json.NewDecoder(reader).Decode(&response); err != nil {
+		t.Fatalf("Cannot decode response: %v", err)
+	}
+	expected := map[string]int{"apple": 2, "Banana": 1, "cherry": 0}
+	if len(response.Data) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, response.Data)
+	}
+	for term, count := range expected {
+		if response.Data[term] != count {
+			t.Fatalf("Expected %v, got %v", expected, response.Data)
+		}
+	}
+}
+
+func TestQueryOccurencesSynonyms(t *testing.T) {
+	s := newQueryService(map[string]int{"color": 2, "colour": 3})
+	s.db.Synonyms().Put(&repo.Synonym{Alias: "colour", Canonical: "color", Mode: repo.SynonymQuery})
+
+	page, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"color", "colour"}})
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	for _, result := range page.Data {
+		if result.Occurrences != 5 {
+			t.Fatalf("Expected 5 occurrences of %s, got %d", result.Word, result.Occurrences)
+		}
+	}
+}
+
+func TestQueryOccurencesLabels(t *testing.T) {
+	s := newQueryService(map[string]int{"go": 6, "gopher": 1, "rust": 2})
+	s.db.Labels().Add(map[string]string{"source": "twitter", "lang": "en"}, map[string]int{"go": 3, "rust": 2})
+	s.db.Labels().Add(map[string]string{"source": "rss"}, map[string]int{"go": 1})
+
+	// label-less queries keep returning the totals
+	page, err := s.QueryOccurences(&OccurrenceQuery{Terms: []string{"go"}})
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	if page.Data[0].Occurrences != 6 || page.Data[0].Groups != nil {
+		t.Fatalf("Expected the total of go, got %+v", page.Data[0])
+	}
+
+	page, err = s.QueryOccurences(&OccurrenceQuery{Terms: []string{"go"}, GroupBy: []string{"source"}})
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	groups := page.Data[0].Groups
+	if page.Data[0].Occurrences != 4 || len(groups) != 2 || groups[0].Labels["source"] != "twitter" || groups[0].Occurrences != 3 {
+		t.Fatalf("Unexpected groups %+v", page.Data[0])
+	}
+
+	// the words matched by a pattern without labelled occurrences are dropped
+	page, err = s.QueryOccurences(&OccurrenceQuery{Terms: []string{"go*", "rust"}, Labels: map[string]string{"source": "rss"}})
+	if err != nil {
+		t.Fatalf("QueryOccurences failed: %v", err)
+	}
+	assertWords(t, []string{"go", "rust"}, page.Data)
+	if page.Data[0].Occurrences != 1 || page.Data[1].Occurrences != 0 {
+		t.Fatalf("Unexpected occurrences %+v", page.Data)
+	}
+}
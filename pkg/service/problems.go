@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	router "mem-db/pkg/api/http/router"
+	"net/http"
+	"strings"
+)
+
+// prefix of the versioned API, the handlers answer the unversioned legacy paths
+// with HTTP 200 and the status code inside the body
+const apiPrefix = "/v1"
+
+// machine readable codes of the errors
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeNotFound          = "not_found"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeDocumentExists    = "document_exists"
+	CodeDocumentNotFound  = "document_not_found"
+	CodeJobNotFound       = "job_not_found"
+	CodeWordNotFound      = "word_not_found"
+	CodeSynonymNotFound   = "synonym_not_found"
+	CodeRequestInProgress = "request_in_progress"
+	CodeFeatureDisabled   = "feature_disabled"
+	CodeQueryTooExpensive = "query_too_expensive"
+	CodeQueueFull         = "queue_full"
+	CodePayloadTooLarge   = "payload_too_large"
+	CodeRateLimited       = "rate_limited"
+	CodeQuotaExceeded     = "quota_exceeded"
+	CodeInternal          = "internal_error"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is the body of the errors of the versioned API (RFC 9457)
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+	// path of the request
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func isLegacy(r *http.Request) bool {
+	return !strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+// the versioned API sends the status code of the body as HTTP status
+func writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if !isLegacy(r) {
+		w.WriteHeader(statusCode)
+	} else if recorder, ok := w.(*recordingWriter); ok {
+		// the idempotency needs the real status to let the failed requests be retried
+		recorder.bodyStatus = statusCode
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	if isLegacy(r) {
+		writeResponse(w, r, statusCode, &Response{
+			Status:     legacyStatus(statusCode),
+			StatusCode: statusCode,
+			Message:    message})
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    message,
+		Code:      code,
+		Instance:  r.URL.Path,
+		RequestID: router.RequestID(r.Context()),
+	})
+}
+
+// the legacy bodies used "Error" for the internal errors
+func legacyStatus(statusCode int) string {
+	if statusCode == http.StatusInternalServerError {
+		return "Error"
+	}
+	return http.StatusText(statusCode)
+}
+
+// answers the requests without a route or rejected by the authentication,
+// the legacy paths keep the plain text errors
+func writeRouterError(w http.ResponseWriter, r *http.Request, statusCode int) {
+	if isLegacy(r) {
+		http.Error(w, http.StatusText(statusCode), statusCode)
+		return
+	}
+
+	code := CodeNotFound
+	switch statusCode {
+	case http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case http.StatusForbidden:
+		code = CodeForbidden
+	}
+	writeError(w, r, statusCode, code, http.StatusText(statusCode))
+}
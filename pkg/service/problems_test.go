@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	router "mem-db/pkg/api/http/router"
+	repo "mem-db/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteErrorVersioned(t *testing.T) {
+	s := newQueryService(map[string]int{"apple": 2})
+	s.logger = getLoggerContext().Value(log.LoggerKey).(log.Logger)
+
+	r := httptest.NewRequest(http.MethodGet, apiPrefix+"/words/occurences", nil)
+	w := httptest.NewRecorder()
+	s.getWordOccurences(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != problemContentType {
+		t.Fatalf("Expected %s, got %s", problemContentType, contentType)
+	}
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Cannot decode response: %v", err)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Code != CodeInvalidRequest || problem.Instance != apiPrefix+"/words/occurences" {
+		t.Fatalf("Unexpected problem %+v", problem)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, apiPrefix+"/words/occurences?terms=apple", nil)
+	w = httptest.NewRecorder()
+	s.getWordOccurences(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestWriteErrorLegacy(t *testing.T) {
+	s := newQueryService(map[string]int{"apple": 2})
+	s.logger = getLoggerContext().Value(log.LoggerKey).(log.Logger)
+
+	r := httptest.NewRequest(http.MethodGet, "/words/occurences", nil)
+	w := httptest.NewRecorder()
+	s.getWordOccurences(w, r)
+
+	// the legacy endpoints keep the status code inside the body
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Cannot decode response: %v", err)
+	}
+	if response.StatusCode != http.StatusBadRequest || response.Status != "Bad Request" {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+}
+
+func TestWriteRouterError(t *testing.T) {
+	rt := router.NewRouter()
+	rt.SetErrorHandler(writeRouterError)
+	rt.Group(apiPrefix).AddRoute(http.MethodGet, "/words/occurences", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, apiPrefix+"/words/occurences", nil))
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Cannot decode response: %v", err)
+	}
+	if w.Code != http.StatusMethodNotAllowed || problem.Code != CodeMethodNotAllowed {
+		t.Fatalf("Expected a 405 problem, got %d %+v", w.Code, problem)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") == problemContentType {
+		t.Fatalf("Expected a plain 404, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestLegacyAPIServedByDefault(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)}
+
+	disabled := false
+	for _, test := range []struct {
+		legacyAPI  *bool
+		statusCode int
+	}{
+		{nil, http.StatusOK},
+		{&disabled, http.StatusNotFound},
+	} {
+		options := &config.ServiceOptions{ApiOptions: &config.ApiOptions{}, LegacyAPI: test.legacyAPI}
+		handler := NewDBHttpServer(ctx, options, ws).(*DBHttpServer).server.Router
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/words/occurences?terms=apple", nil))
+		if w.Code != test.statusCode {
+			t.Fatalf("Expected %d with legacyApi %v, got %d", test.statusCode, test.legacyAPI, w.Code)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	resp "mem-db/pkg/api/resp"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// every increment is a word of the registered text
+	maxIncrement     = 10000
+	defaultScanCount = 10
+)
+
+// DBRespServer maps the Redis commands onto the words: a key is a word and its value
+// is the number of occurrences, the writes are registered and replicated like texts
+type DBRespServer struct {
+	server  *resp.RESPServer
+	ws      *wordService
+	scans   *scanCursors
+	logger  log.Logger
+	started time.Time
+}
+
+func NewDBRespServer(ctx context.Context, options *config.ServiceOptions, ws *wordService) *DBRespServer {
+	s := &DBRespServer{
+		server:  resp.NewServer(ctx, &options.RESPOptions),
+		ws:      ws,
+		scans:   newScanCursors(),
+		logger:  ctx.Value(log.LoggerKey).(log.Logger),
+		started: time.Now(),
+	}
+
+	s.server.Handle("INCR", 2, s.incr)
+	s.server.Handle("INCRBY", 3, s.incrBy)
+	s.server.Handle("GET", 2, s.get)
+	s.server.Handle("MGET", -2, s.mget)
+	s.server.Handle("DEL", -2, s.del)
+	s.server.Handle("SCAN", -2, s.scan)
+	s.server.Handle("DBSIZE", 1, s.dbSize)
+	s.server.Handle("INFO", -1, s.info)
+	return s
+}
+
+func (s *DBRespServer) Start() error {
+	s.logger.Info("Starting RESP server for WordService")
+	return s.server.Start()
+}
+
+func (s *DBRespServer) Stop(ctx context.Context) error {
+	return s.server.Stop(ctx)
+}
+
+// INCR key
+func (s *DBRespServer) incr(w *resp.Writer, args []string) {
+	s.increment(w, args[1], 1)
+}
+
+// INCRBY key increment, the words can't be decremented
+func (s *DBRespServer) incrBy(w *resp.Writer, args []string) {
+	increment, err := strconv.Atoi(args[2])
+	if err != nil {
+		w.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	if increment < 1 || increment > maxIncrement {
+		w.WriteError(fmt.Sprintf("ERR increment must be between 1 and %d", maxIncrement))
+		return
+	}
+	s.increment(w, args[1], increment)
+}
+
+func (s *DBRespServer) increment(w *resp.Writer, key string, increment int) {
+	word, ok := s.ws.singleWord(key)
+	if !ok {
+		w.WriteError("ERR key must be a single word")
+		return
+	}
+
+	// every increment is a write request, its words are charged to the quota of the client
+	if err := s.ws.allowWrite(w.RemoteAddr); err != nil {
+		w.WriteError("ERR " + err.Error())
+		return
+	}
+
+	// the increments are counted as plain words, even when the same key was incremented just before
+	text := strings.Repeat(word+" ", increment)
+	_, err := s.ws.registerCharged(w.RemoteAddr, &TextInput{Text: text, Force: true})
+	if limited(err) {
+		w.WriteError("ERR " + err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Cannot register increment: ", err)
+		w.WriteError("ERR " + err.Error())
+		return
+	}
+	s.ws.forwardLine(text, nil)
+
+	w.WriteInt(int64(s.ws.occurrencesOf(word)))
+}
+
+// the words which are not counted are missing keys
+func (s *DBRespServer) writeCount(w *resp.Writer, key string) {
+	word, ok := s.ws.singleWord(key)
+	if !ok {
+		w.WriteNull()
+		return
+	}
+
+	count := s.ws.occurrencesOf(word)
+	if count == 0 {
+		w.WriteNull()
+		return
+	}
+	w.WriteBulk(strconv.Itoa(count))
+}
+
+// GET key
+func (s *DBRespServer) get(w *resp.Writer, args []string) {
+	s.writeCount(w, args[1])
+}
+
+// MGET key [key ...]
+func (s *DBRespServer) mget(w *resp.Writer, args []string) {
+	w.WriteArray(len(args) - 1)
+	for _, key := range args[1:] {
+		s.writeCount(w, key)
+	}
+}
+
+// DEL key [key ...], returns the number of words which were counted
+func (s *DBRespServer) del(w *resp.Writer, args []string) {
+	deleted := 0
+	for _, key := range args[1:] {
+		if word, ok := s.ws.singleWord(key); ok && s.ws.DeleteWord(word, "") > 0 {
+			deleted++
+		}
+	}
+	w.WriteInt(int64(deleted))
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func (s *DBRespServer) scan(w *resp.Writer, args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		w.WriteError("ERR invalid cursor")
+		return
+	}
+
+	var pattern *regexp.Regexp
+	count := defaultScanCount
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			w.WriteError("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			if args[i+1] != "*" {
+				pattern = globToRegexp(args[i+1])
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				w.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+		case "TYPE":
+			// every key is a string
+			if !strings.EqualFold(args[i+1], "string") {
+				w.WriteArray(2)
+				w.WriteBulk("0")
+				w.WriteArray(0)
+				return
+			}
+		default:
+			w.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	next, words := s.scans.Scan(s.ws, cursor, count)
+	keys := make([]string, 0, len(words))
+	for _, word := range words {
+		if pattern == nil || pattern.MatchString(word) {
+			keys = append(keys, word)
+		}
+	}
+
+	w.WriteArray(2)
+	w.WriteBulk(strconv.FormatUint(next, 10))
+	w.WriteBulks(keys)
+}
+
+// DBSIZE
+func (s *DBRespServer) dbSize(w *resp.Writer, args []string) {
+	w.WriteInt(int64(s.countWords()))
+}
+
+func (s *DBRespServer) countWords() int {
+	words := 0
+	s.ws.db.Range(func(_ string, count int) bool {
+		if count > 0 {
+			words++
+		}
+		return true
+	})
+	return words
+}
+
+// INFO [section], the sections are ignored
+func (s *DBRespServer) info(w *resp.Writer, args []string) {
+	var info strings.Builder
+	info.WriteString("# Server\r\n")
+	info.WriteString("redis_version:" + resp.Version + "\r\n")
+	info.WriteString("redis_mode:standalone\r\n")
+	info.WriteString(fmt.Sprintf("uptime_in_seconds:%d\r\n", int(time.Since(s.started).Seconds())))
+	info.WriteString("\r\n# Clients\r\n")
+	info.WriteString(fmt.Sprintf("connected_clients:%d\r\n", s.server.Clients.Load()))
+	info.WriteString("\r\n# Stats\r\n")
+	info.WriteString(fmt.Sprintf("total_commands_processed:%d\r\n", s.server.Processed.Load()))
+	info.WriteString("\r\n# Keyspace\r\n")
+	info.WriteString(fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", s.countWords()))
+	w.WriteBulk(info.String())
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	ratelimit "mem-db/pkg/ratelimit"
+	repo "mem-db/pkg/repository"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type respClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// starts the RESP server of a word service on a local port
+func newRespTestClient(t *testing.T) (*respClient, *wordService) {
+	t.Helper()
+	ctx := getLoggerContext()
+
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+		IndexOptions:    config.IndexOptions{Enabled: true},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger)}
+	return dialRespTestServer(t, ws), ws
+}
+
+func dialRespTestServer(t *testing.T, ws *wordService) *respClient {
+	t.Helper()
+	ctx := getLoggerContext()
+
+	server := NewDBRespServer(ctx, &config.ServiceOptions{}, ws)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go server.server.Serve(lis)
+	t.Cleanup(func() { server.Stop(context.Background()) })
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Cannot connect to the RESP server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// sends the command as an array of bulk strings and returns the decoded reply
+func (c *respClient) do(args ...string) interface{} {
+	c.t.Helper()
+	var command strings.Builder
+	command.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		command.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+	if _, err := c.conn.Write([]byte(command.String())); err != nil {
+		c.t.Fatalf("Cannot send %v: %v", args, err)
+	}
+	return c.reply()
+}
+
+// errors are returned as error values, nulls as nil
+func (c *respClient) reply() interface{} {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("Cannot read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return fmt.Errorf("%s", line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		length, _ := strconv.Atoi(line[1:])
+		if length < 0 {
+			return nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			c.t.Fatalf("Cannot read bulk string: %v", err)
+		}
+		return string(data[:length])
+	case '*', '%':
+		length, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			length *= 2
+		}
+		values := make([]interface{}, length)
+		for i := range values {
+			values[i] = c.reply()
+		}
+		return values
+	}
+	c.t.Fatalf("Unexpected reply %q", line)
+	return nil
+}
+
+func TestRespCounters(t *testing.T) {
+	client, ws := newRespTestClient(t)
+
+	if reply := client.do("PING"); reply != "PONG" {
+		t.Fatalf("Expected PONG, got %v", reply)
+	}
+	if reply := client.do("INCR", "Apple"); reply != int64(1) {
+		t.Fatalf("Expected 1, got %v", reply)
+	}
+	if reply := client.do("incrby", "apple", "4"); reply != int64(5) {
+		t.Fatalf("Expected 5, got %v", reply)
+	}
+	if count := ws.occurrencesOf("apple"); count != 5 {
+		t.Fatalf("Expected the increments to be registered, got %d", count)
+	}
+	// the increments are plain words, not documents of the index
+	if postings := ws.db.Index().Postings("", "apple"); len(postings) != 0 {
+		t.Fatalf("Expected the increments not to be indexed, got %v", postings)
+	}
+	client.do("INCR", "banana")
+
+	if reply := client.do("GET", "apple"); reply != "5" {
+		t.Fatalf("Expected \"5\", got %v", reply)
+	}
+	reply := client.do("MGET", "apple", "cherry", "banana")
+	if !reflect.DeepEqual(reply, []interface{}{"5", nil, "1"}) {
+		t.Fatalf("Unexpected MGET reply %v", reply)
+	}
+	if reply := client.do("DBSIZE"); reply != int64(2) {
+		t.Fatalf("Expected 2 keys, got %v", reply)
+	}
+
+	if reply := client.do("DEL", "apple", "cherry"); reply != int64(1) {
+		t.Fatalf("Expected 1 deleted key, got %v", reply)
+	}
+	if reply := client.do("GET", "apple"); reply != nil {
+		t.Fatalf("Expected a null reply, got %v", reply)
+	}
+
+	for _, args := range [][]string{
+		{"INCR", "two words"},
+		{"INCRBY", "apple", "-1"},
+		{"INCR"},
+		{"FLUSHALL"},
+	} {
+		if _, ok := client.do(args...).(error); !ok {
+			t.Fatalf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestRespIncrementLimits(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{
+		db:           db,
+		logger:       ctx.Value(log.LoggerKey).(log.Logger),
+		writeLimiter: ratelimit.NewLimiter(0.001, 3),
+		quotas:       ratelimit.NewQuotas(5, nil),
+	}
+	client := dialRespTestServer(t, ws)
+
+	// the words of every increment are charged to the quota of the client
+	if reply := client.do("INCRBY", "apple", "4"); reply != int64(4) {
+		t.Fatalf("Expected 4, got %v", reply)
+	}
+	if reply, ok := client.do("INCRBY", "apple", "2").(error); !ok || !strings.Contains(reply.Error(), "quota") {
+		t.Fatalf("Expected the quota to be exceeded, got %v", reply)
+	}
+	if reply := client.do("INCR", "apple"); reply != int64(5) {
+		t.Fatalf("Expected 5, got %v", reply)
+	}
+
+	// the reads are not limited
+	if reply := client.do("GET", "apple"); reply != "5" {
+		t.Fatalf("Expected \"5\", got %v", reply)
+	}
+	if reply, ok := client.do("INCR", "apple").(error); !ok || !strings.Contains(reply.Error(), "Too many requests") {
+		t.Fatalf("Expected the increment to be rate limited, got %v", reply)
+	}
+}
+
+func TestRespScan(t *testing.T) {
+	client, ws := newRespTestClient(t)
+
+	expected := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		word := fmt.Sprintf("word%c%c", 'a'+i/10, 'a'+i%10)
+		ws.RegisterWords(word, nil)
+		expected = append(expected, word)
+	}
+	ws.RegisterWords("other", nil)
+
+	var keys []string
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			t.Fatalf("SCAN didn't finish")
+		}
+		reply := client.do("SCAN", cursor, "MATCH", "word*", "COUNT", "7").([]interface{})
+		for _, key := range reply[1].([]interface{}) {
+			keys = append(keys, key.(string))
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Expected every word once, got %v", keys)
+	}
+}
+
+func TestRespScanSnapshots(t *testing.T) {
+	_, ws := newRespTestClient(t)
+	for i := 0; i < 20; i++ {
+		ws.RegisterWords(fmt.Sprintf("word%d", i), nil)
+	}
+
+	scans := newScanCursors()
+	cursor, keys := scans.Scan(ws, 0, 5)
+	if cursor>>32 == 0 || len(keys) < 5 {
+		t.Fatalf("Expected a cursor of a snapshot, got %d %v", cursor, keys)
+	}
+
+	// the next pages come from the snapshot, the deleted words are skipped
+	ws.RegisterWords("added", nil)
+	deleted := scans.snapshots[uint32(cursor>>32)].words[len(keys)].word
+	ws.DeleteWord(deleted, "")
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for cursor != 0 {
+		cursor, keys = scans.Scan(ws, cursor, 5)
+		for _, key := range keys {
+			seen[key] = true
+		}
+	}
+	if len(seen) != 19 || seen["added"] || seen[deleted] {
+		t.Fatalf("Expected the words of the snapshot without the deleted one, got %v", seen)
+	}
+	if len(scans.snapshots) != 0 {
+		t.Fatalf("Expected the finished iteration to drop its snapshot")
+	}
+
+	// a cursor whose snapshot expired continues from its hash
+	now := time.Now()
+	scans.now = func() time.Time { return now }
+	cursor, keys = scans.Scan(ws, 0, 5)
+	now = now.Add(2 * scanSnapshotTTL)
+	scans.Scan(ws, 0, 5)
+	if len(scans.snapshots) != 1 {
+		t.Fatalf("Expected the expired snapshot to be dropped, got %d", len(scans.snapshots))
+	}
+	total := len(keys)
+	for cursor != 0 {
+		cursor, keys = scans.Scan(ws, cursor, 5)
+		total += len(keys)
+	}
+	if total != 20 {
+		t.Fatalf("Expected every word after the snapshot expired, got %d", total)
+	}
+}
+
+func TestRespHello(t *testing.T) {
+	client, _ := newRespTestClient(t)
+
+	reply := client.do("HELLO", "3").([]interface{})
+	if reply[4] != "proto" || reply[5] != int64(3) {
+		t.Fatalf("Unexpected HELLO reply %v", reply)
+	}
+	// RESP3 nulls
+	if _, err := client.conn.Write([]byte("GET missing\r\n")); err != nil {
+		t.Fatalf("Cannot send inline command: %v", err)
+	}
+	line, _ := client.r.ReadString('\n')
+	if line != "_\r\n" {
+		t.Fatalf("Expected a RESP3 null, got %q", line)
+	}
+
+	info := client.do("INFO").(string)
+	if !strings.Contains(info, "db0:keys=0") || !strings.Contains(info, "connected_clients:1") {
+		t.Fatalf("Unexpected INFO reply %q", info)
+	}
+	if reply := client.do("QUIT"); reply != "OK" {
+		t.Fatalf("Expected OK, got %v", reply)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// the iterations which were not continued for this long lose their snapshot
+	scanSnapshotTTL = time.Minute
+	// the least recently used snapshot is dropped when there are more iterations
+	maxScanSnapshots = 16
+)
+
+type hashedWord struct {
+	hash uint32
+	word string
+}
+
+// the words ordered by hash when the iteration started
+type scanSnapshot struct {
+	words []hashedWord
+	used  time.Time
+}
+
+// scanCursors keeps an ordered snapshot of the words for every SCAN iteration, so the next pages
+// cost a binary search instead of sorting every word again. A cursor is the id of the snapshot
+// in its high 32 bits and the hash of the next word in its low 32 bits.
+type scanCursors struct {
+	mutex     sync.Mutex
+	snapshots map[uint32]*scanSnapshot
+	lastID    uint32
+	now       func() time.Time
+}
+
+func newScanCursors() *scanCursors {
+	return &scanCursors{
+		snapshots: make(map[uint32]*scanSnapshot),
+		now:       time.Now,
+	}
+}
+
+// the words are visited in the order of their hash, so a cursor stays valid while words
+// are added or deleted: the words present during the whole iteration are returned at least once.
+// A cursor whose snapshot was dropped continues from its hash with a new snapshot.
+func (c *scanCursors) Scan(ws *wordService, cursor uint64, count int) (uint64, []string) {
+	id, start := uint32(cursor>>32), uint32(cursor)
+
+	snapshot := c.get(id)
+	if cursor == 0 || snapshot == nil {
+		snapshot = newScanSnapshot(ws)
+		id = c.add(snapshot)
+	}
+
+	words := snapshot.words
+	first := sort.Search(len(words), func(i int) bool { return words[i].hash >= start })
+
+	// the words sharing a hash are returned in the same page, the next cursor skips them
+	end := min(first+count, len(words))
+	for end < len(words) && words[end].hash == words[end-1].hash {
+		end++
+	}
+
+	page := make([]string, 0, end-first)
+	for _, candidate := range words[first:end] {
+		// the words deleted since the iteration started
+		if ws.db.Get(candidate.word) > 0 {
+			page = append(page, candidate.word)
+		}
+	}
+	if end == len(words) {
+		c.remove(id)
+		return 0, page
+	}
+	return uint64(id)<<32 | uint64(words[end-1].hash+1), page
+}
+
+func newScanSnapshot(ws *wordService) *scanSnapshot {
+	var words []hashedWord
+	ws.db.Range(func(word string, occurrences int) bool {
+		if occurrences > 0 {
+			words = append(words, hashedWord{wordHash(word), word})
+		}
+		return true
+	})
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].hash != words[j].hash {
+			return words[i].hash < words[j].hash
+		}
+		return words[i].word < words[j].word
+	})
+	return &scanSnapshot{words: words}
+}
+
+func (c *scanCursors) get(id uint32) *scanSnapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snapshot, found := c.snapshots[id]
+	if !found {
+		return nil
+	}
+	snapshot.used = c.now()
+	return snapshot
+}
+
+// returns the id of the new snapshot, the expired snapshots are dropped
+func (c *scanCursors) add(snapshot *scanSnapshot) uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	var oldest uint32
+	for id, other := range c.snapshots {
+		if now.Sub(other.used) >= scanSnapshotTTL {
+			delete(c.snapshots, id)
+		} else if _, found := c.snapshots[oldest]; !found || other.used.Before(c.snapshots[oldest].used) {
+			oldest = id
+		}
+	}
+	if len(c.snapshots) >= maxScanSnapshots {
+		delete(c.snapshots, oldest)
+	}
+
+	// 0 is the cursor which starts an iteration
+	c.lastID++
+	if c.lastID == 0 {
+		c.lastID++
+	}
+	snapshot.used = now
+	c.snapshots[c.lastID] = snapshot
+	return c.lastID
+}
+
+func (c *scanCursors) remove(id uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.snapshots, id)
+}
+
+func wordHash(word string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(word))
+	return h.Sum32()
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type ContentKind int
+
+const (
+	PlainText ContentKind = iota
+	Markdown
+	HTML
+	CSV
+)
+
+type ExtractOptions struct {
+	// header name or zero-based index of the csv column to extract
+	// all the columns are extracted when it's empty
+	CSVColumn string
+}
+
+var (
+	mdCodeFence  = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdRefLink    = regexp.MustCompile(`(?m)^\s*\[[^\]]+\]:\s+\S+.*$`)
+	mdHeading    = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	mdBlockquote = regexp.MustCompile(`(?m)^\s*>+\s?`)
+	mdListMarker = regexp.MustCompile(`(?m)^\s*([*+-]|\d+[.)])\s+`)
+	mdRule       = regexp.MustCompile(`(?m)^\s*([*_-]\s*){3,}$`)
+	mdHTMLTag    = regexp.MustCompile(`<[^>]+>`)
+	mdEmphasis   = regexp.MustCompile("[*~`]+")
+)
+
+// detects the kind of content using the file extension first and the content type as fallback
+func DetectContentKind(filename, contentType string) ContentKind {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return Markdown
+	case ".html", ".htm":
+		return HTML
+	case ".csv":
+		return CSV
+	case ".txt", ".text":
+		return PlainText
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return PlainText
+	}
+
+	switch mediaType {
+	case "text/markdown", "text/x-markdown":
+		return Markdown
+	case "text/html", "application/xhtml+xml":
+		return HTML
+	case "text/csv":
+		return CSV
+	}
+
+	return PlainText
+}
+
+// returns the text of a file without markup, ready to be split into words
+func ExtractText(filename, contentType string, r io.Reader, options *ExtractOptions) (string, error) {
+	if options == nil {
+		options = &ExtractOptions{}
+	}
+
+	switch DetectContentKind(filename, contentType) {
+	case Markdown:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read markdown content: %v", err)
+		}
+		return stripMarkdown(string(data)), nil
+	case HTML:
+		return stripHTML(r)
+	case CSV:
+		return extractCSV(r, options.CSVColumn)
+	default:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read text content: %v", err)
+		}
+		return string(data), nil
+	}
+}
+
+func stripMarkdown(text string) string {
+	text = mdCodeFence.ReplaceAllString(text, "")
+	text = mdImage.ReplaceAllString(text, "$1")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdRefLink.ReplaceAllString(text, "")
+	text = mdRule.ReplaceAllString(text, "")
+	text = mdHeading.ReplaceAllString(text, "")
+	text = mdBlockquote.ReplaceAllString(text, "")
+	text = mdListMarker.ReplaceAllString(text, "")
+	text = mdHTMLTag.ReplaceAllString(text, " ")
+	return mdEmphasis.ReplaceAllString(text, "")
+}
+
+func stripHTML(r io.Reader) (string, error) {
+	var sb strings.Builder
+	tokenizer := html.NewTokenizer(r)
+
+	// text inside script and style elements is not content
+	skipDepth := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				return "", fmt.Errorf("Cannot parse html content: %v", err)
+			}
+			return sb.String(), nil
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if isSkippedTag(string(name)) {
+				skipDepth++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if isSkippedTag(string(name)) && skipDepth > 0 {
+				skipDepth--
+			}
+			// tags like </p> or </td> separate words
+			sb.WriteByte(' ')
+		case html.TextToken:
+			if skipDepth == 0 {
+				sb.Write(tokenizer.Text())
+				sb.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func isSkippedTag(name string) bool {
+	return name == "script" || name == "style" || name == "noscript"
+}
+
+// csv files are expected to have a header row, which is never counted
+func extractCSV(r io.Reader, column string) (string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot read csv header: %v", err)
+	}
+
+	columnIdx := -1
+	if column != "" {
+		columnIdx = findCSVColumn(header, column)
+		if columnIdx < 0 {
+			return "", fmt.Errorf("Column %s does not exist in csv file", column)
+		}
+	}
+
+	var sb strings.Builder
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("Cannot read csv record: %v", err)
+		}
+
+		if columnIdx < 0 {
+			sb.WriteString(strings.Join(record, " "))
+			sb.WriteByte('\n')
+			continue
+		}
+		if columnIdx < len(record) {
+			sb.WriteString(record[columnIdx])
+			sb.WriteByte('\n')
+		}
+	}
+}
+
+func findCSVColumn(header []string, column string) int {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i
+		}
+	}
+
+	if idx, err := strconv.Atoi(column); err == nil && idx >= 0 && idx < len(header) {
+		return idx
+	}
+	return -1
+}
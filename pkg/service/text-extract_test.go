@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	config "mem-db/cmd/config"
+	log "mem-db/cmd/logger"
+	repo "mem-db/pkg/repository"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractTextHTML(t *testing.T) {
+	input := `<html><head><style>body { color: red; }</style><script>var x = 1;</script></head>
+<body><h1>Hello</h1><p>brave <b>new</b> world</p></body></html>`
+
+	text, err := ExtractText("page.html", "", strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+
+	words := splitPhrase(text)
+	expected := []string{"Hello", "brave", "new", "world"}
+	if strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected words %v, got %v", expected, words)
+	}
+}
+
+func TestExtractTextMarkdown(t *testing.T) {
+	input := "# Title\n\n* **bold** item\n> quoted [link](http://example.com)\n\n```go\n```\n"
+
+	text, err := ExtractText("README", "text/markdown", strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+
+	words := splitPhrase(text)
+	expected := []string{"Title", "bold", "item", "quoted", "link"}
+	if strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected words %v, got %v", expected, words)
+	}
+}
+
+func TestExtractTextCSVColumn(t *testing.T) {
+	input := "id,comment\n1,first row\n2,second row\n"
+
+	text, err := ExtractText("data.csv", "", strings.NewReader(input), &ExtractOptions{CSVColumn: "comment"})
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+
+	words := splitPhrase(text)
+	expected := []string{"first", "row", "second", "row"}
+	if strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected words %v, got %v", expected, words)
+	}
+
+	_, err = ExtractText("data.csv", "", strings.NewReader(input), &ExtractOptions{CSVColumn: "missing"})
+	if err == nil {
+		t.Fatalf("Expected an error for a missing column")
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	ctx := getLoggerContext()
+	dir := t.TempDir()
+	db := repo.NewDatabase(ctx, &config.Config{
+		WALOptions:      config.WALOptions{WalFilePath: filepath.Join(dir, "wal"), SyncTimer: 1},
+		SnapshotOptions: config.SnapshotOptions{DirPath: dir, SyncTimer: 3600},
+	}, true)
+	ws := &wordService{db: db, logger: ctx.Value(log.LoggerKey).(log.Logger), maxUploadSize: 4096, maxFileSize: 64}
+	handler := NewDBHttpServer(ctx, &config.ServiceOptions{ApiOptions: &config.ApiOptions{}}, ws).(*DBHttpServer).server.Router
+
+	upload := func(files map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for name, content := range files {
+			part, _ := form.CreateFormFile("file", name)
+			part.Write([]byte(content))
+		}
+		form.Close()
+
+		r := httptest.NewRequest("POST", "/v1/words/upload", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	expectTooLarge := func(w *httptest.ResponseRecorder) {
+		t.Helper()
+		var problem Problem
+		if w.Code != http.StatusRequestEntityTooLarge || json.NewDecoder(w.Body).Decode(&problem) != nil || problem.Code != CodePayloadTooLarge {
+			t.Fatalf("Expected 413, got %d: %+v", w.Code, problem)
+		}
+	}
+
+	if w := upload(map[string]string{"small.txt": "apple banana"}); w.Code != http.StatusOK {
+		t.Fatalf("Expected the small file to be registered, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// a file over its limit rejects the whole upload
+	expectTooLarge(upload(map[string]string{"other.txt": "cherry", "large.txt": strings.Repeat("cherry ", 20)}))
+	if ws.occurrencesOf("cherry") != 0 {
+		t.Fatalf("Expected nothing to be registered from a rejected upload")
+	}
+
+	// the body is cut at its limit
+	expectTooLarge(upload(map[string]string{"huge.txt": strings.Repeat("date ", 2000)}))
+}
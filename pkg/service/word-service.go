@@ -2,22 +2,53 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/sync/errgroup"
 	config "mem-db/cmd/config"
 	log "mem-db/cmd/logger"
 	api "mem-db/pkg/api"
+	auth "mem-db/pkg/auth"
+	ratelimit "mem-db/pkg/ratelimit"
 	repo "mem-db/pkg/repository"
 	util "mem-db/pkg/util"
+	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"unicode"
 )
 
 type wordService struct {
-	db           repo.DBService
-	server       api.Server
+	db     repo.DBService
+	server api.Server
+	// nil when useGRPC is not set
+	grpcServer api.Server
+	// nil when respOptions are not enabled
+	respServer api.Server
+	// nil when lineOptions set neither a port nor a socket
+	lineServer   api.Server
 	logger       log.Logger
 	forwarding   bool
-	forwardingCh chan []byte
+	forwardingCh chan *ForwardedRequest
+	jobs         *JobQueue
+	maxScanWords int
+	// 0 when the default limits of the uploads are used
+	maxUploadSize int64
+	maxFileSize   int64
+	// nil when the near duplicates are counted like any other text
+	dedup *DuplicateDetector
+	// nil when the authentication is disabled
+	auth *auth.Authenticator
+	// nil when the requests of the clients are not limited
+	readLimiter  *ratelimit.Limiter
+	writeLimiter *ratelimit.Limiter
+	// nil when no client has a daily quota
+	quotas *ratelimit.Quotas
+	// sent by the other nodes, their requests are not limited
+	nodeKey string
+	// lines waiting to be forwarded to the workers
+	lines *lineBatch
 }
 
 type WordService interface {
@@ -25,30 +56,128 @@ type WordService interface {
 	Stop(ctx context.Context) error
 	SetForwarding()
 	UnsetForwarding()
-	SetForwardingCh(forwardingCh chan []byte)
+	SetForwardingCh(forwardingCh chan *ForwardedRequest)
+}
+
+// request replicated by the master to every worker
+type ForwardedRequest struct {
+	Method   string
+	Endpoint string
+	Payload  []byte
+	// lets the workers ignore the requests sent again when forwarding is retried
+	IdempotencyKey string
 }
 
 type WordResponse struct {
 	Word        string `json:"word"`
 	Occurrences int    `json:"occurrences"`
+	// known words close to a word which is not counted, when they are requested
+	Suggestions []repo.SimilarWord `json:"suggestions,omitempty"`
+	// occurrences by the values of the grouped labels
+	Groups []repo.LabelGroup `json:"groups,omitempty"`
+}
+
+// outcome of the registration of a text
+type Registration struct {
+	Words int
+	// false when the text was skipped as a near duplicate
+	Counted bool
+	// set when the text is a near duplicate of a recent one
+	Duplicate *DuplicateMatch
 }
 
 func NewWordService(ctx context.Context, config *config.Config, db repo.DBService) WordService {
 	ws := &wordService{
-		db:     db,
-		logger: ctx.Value(log.LoggerKey).(log.Logger),
+		db:            db,
+		logger:        ctx.Value(log.LoggerKey).(log.Logger),
+		maxScanWords:  config.ServiceOptions.MaxScanWords,
+		maxUploadSize: config.ServiceOptions.MaxUploadSize,
+		maxFileSize:   config.ServiceOptions.MaxFileSize,
+	}
+	ws.auth, _ = ctx.Value(auth.AuthenticatorKey).(*auth.Authenticator)
+	ws.lines = newLineBatch(ws.forwardLines)
+
+	limits := &config.ServiceOptions.RateLimitOptions
+	ws.readLimiter = ratelimit.NewLimiter(limits.ReadRate, limits.ReadBurst)
+	ws.writeLimiter = ratelimit.NewLimiter(limits.WriteRate, limits.WriteBurst)
+	ws.quotas = ratelimit.NewQuotas(limits.DailyWordQuota, limits.Quotas)
+	// the authenticator checks the node key when the authentication is enabled
+	ws.nodeKey = config.AuthOptions.NodeKey
+	if ws.auth == nil && ws.nodeKey != "" {
+		if err := auth.CheckKey(ws.nodeKey); err != nil {
+			panic(fmt.Sprintf("Invalid node key: %v", err))
+		}
+	}
+
+	jobs, err := NewJobQueue(ctx, &config.ServiceOptions.JobOptions)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot initialize job queue: %v", err))
+	}
+	ws.jobs = jobs
+
+	if config.ServiceOptions.DedupOptions.Enabled {
+		dedup, err := NewDuplicateDetector(&config.ServiceOptions.DedupOptions)
+		if err != nil {
+			panic(fmt.Sprintf("Cannot initialize duplicate detection: %v", err))
+		}
+		ws.dedup = dedup
+	}
+
+	options := &config.ServiceOptions
+	useLines := options.LineOptions.Port > 0 || options.LineOptions.SocketPath != ""
+	// only the http requests are authenticated
+	if ws.auth != nil && (options.ApiOptions.UseGRPC || options.RESPOptions.Enabled || useLines) {
+		panic("The gRPC, RESP and line listeners don't check the credentials, disable them or the authentication")
 	}
 
-	ws.server = NewDBHttpServer(ctx, &config.ServiceOptions, ws)
+	ws.server = NewDBHttpServer(ctx, options, ws)
+	if options.ApiOptions.UseGRPC {
+		ws.grpcServer = NewDBGrpcServer(ctx, options, ws)
+	}
+	if options.RESPOptions.Enabled {
+		ws.respServer = NewDBRespServer(ctx, options, ws)
+	}
+	if useLines {
+		ws.lineServer = NewDBLineServer(ctx, options, ws)
+	}
 	return ws
 }
 
 func (s *wordService) Start(ctx context.Context) error {
-	return s.server.Start()
+	s.jobs.Start(ctx, s.processJob)
+	go s.lines.Run(ctx)
+
+	var servers errgroup.Group
+	for _, server := range s.servers() {
+		servers.Go(server.Start)
+	}
+	return servers.Wait()
 }
 
 func (s *wordService) Stop(ctx context.Context) error {
-	return s.server.Stop(ctx)
+	servers := s.servers()
+	// the http server is stopped last
+	for i := len(servers) - 1; i >= 0; i-- {
+		if err := servers[i].Stop(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the http server and the optional ones
+func (s *wordService) servers() []api.Server {
+	servers := []api.Server{s.server}
+	if s.grpcServer != nil {
+		servers = append(servers, s.grpcServer)
+	}
+	if s.respServer != nil {
+		servers = append(servers, s.respServer)
+	}
+	if s.lineServer != nil {
+		servers = append(servers, s.lineServer)
+	}
+	return servers
 }
 
 func (s *wordService) SetForwarding() {
@@ -59,57 +188,192 @@ func (s *wordService) UnsetForwarding() {
 	s.forwarding = false
 }
 
-func (s *wordService) SetForwardingCh(forwardingCh chan []byte) {
+func (s *wordService) SetForwardingCh(forwardingCh chan *ForwardedRequest) {
 	s.forwardingCh = forwardingCh
 }
 
+// a new idempotency key is generated when the client didn't provide one
+func (s *wordService) forward(method, endpoint string, payload []byte, idempotencyKey string) {
+	if !s.forwarding {
+		return
+	}
+
+	if idempotencyKey == "" {
+		idempotencyKey = util.NewID()
+	}
+	s.forwardingCh <- &ForwardedRequest{
+		Method:         method,
+		Endpoint:       endpoint,
+		Payload:        payload,
+		IdempotencyKey: idempotencyKey,
+	}
+}
+
+// returns the occurrences of the comma separated terms, in the order of the terms
 func (s *wordService) GetOccurences(terms string) []WordResponse {
-	// terms = strings.ToLower(terms)
 	words := strings.Split(terms, ",")
 
-	var wg sync.WaitGroup
-	wordOccChan := make(chan WordResponse, len(words))
-
+	response := make([]WordResponse, 0, len(words))
 	for _, word := range words {
-		wg.Add(1)
-		go func(word string) {
-			defer wg.Done()
+		word = strings.ToLower(word)
+		response = append(response, WordResponse{
+			Word:        word,
+			Occurrences: s.occurrencesOf(word),
+		})
+	}
+	return response
+}
 
-			word = strings.ToLower(word)
-			occurrences := s.db.Get(word)
+// looks up every term in a single pass, the counts are keyed by the terms as given
+func (s *wordService) GetOccurencesBatch(terms []string) map[string]int {
+	counts := make(map[string]int, len(terms))
+	for _, term := range terms {
+		counts[term] = s.occurrencesOf(strings.ToLower(term))
+	}
+	return counts
+}
 
-			// Send the result to the wordOccChan
-			wordOccChan <- WordResponse{
-				Word:        word,
-				Occurrences: occurrences,
-			}
-		}(word)
+// the counts of the query synonyms are added to the count of the word
+func (s *wordService) occurrencesOf(word string) int {
+	occurrences := 0
+	for _, synonym := range s.db.Synonyms().Group(word) {
+		occurrences += s.db.Get(synonym)
 	}
+	return occurrences
+}
 
-	go func() {
-		wg.Wait()
-		close(wordOccChan)
-	}()
+// same as occurrencesOf, only the words registered with labels matching the query are counted
+func (s *wordService) labelledOccurrencesOf(word string, labels map[string]string, groupBy []string) (int, []repo.LabelGroup) {
+	return s.db.Labels().Count(s.db.Synonyms().Group(word), labels, groupBy)
+}
+
+// splits text into words and applies the ingest synonyms
+func (s *wordService) tokenize(text string) []string {
+	return s.db.Synonyms().Canonicalize(Tokenize(text))
+}
+
+// returns the number of words registered from text, the labels are optional
+func (s *wordService) RegisterWords(text string, labels map[string]string) int {
+	words := s.tokenize(text)
+	s.db.RegisterLabels(labels, histogram(words))
+	s.insertWords(words)
+	s.countCooccurrences("", text)
+	return len(words)
+}
 
-	var response []WordResponse
+// registers the words of a document which can be retracted later by its id
+func (s *wordService) RegisterDocument(id, namespace, text string, labels map[string]string) (int, error) {
+	words := s.tokenize(text)
+
+	doc := &repo.Document{
+		ID:        id,
+		Namespace: namespace,
+		Words:     histogram(words),
+		Tokens:    words,
+		Labels:    labels,
+	}
+	if err := s.db.RegisterDocument(doc); err != nil {
+		return 0, err
+	}
 
-	for {
-		select {
-		case wordOcc, ok := <-wordOccChan:
-			// channel is closed
-			if !ok {
-				return response
+	s.insertWords(words)
+	s.countCooccurrences(id, text)
+	return len(words), nil
+}
+
+// registers the text as a document when it has an id,
+// the near duplicates of recent texts are checked first when the detection is enabled
+func (s *wordService) registerText(input *TextInput) (*Registration, error) {
+	registration := &Registration{Counted: true}
+	if s.dedup != nil && !input.Force {
+		if input.DocumentID != "" {
+			if _, found := s.db.GetDocument(input.DocumentID); found {
+				return nil, repo.ErrDocumentExists
 			}
-			response = append(response, wordOcc)
+		}
+
+		registration.Duplicate, registration.Counted = s.dedup.Check(s.tokenize(input.Text), input.DocumentID)
+		if !registration.Counted {
+			s.logger.Info(fmt.Sprintf("Skipping near duplicate of %q", registration.Duplicate.DocumentID))
+			return registration, nil
 		}
 	}
-	return response
+
+	var err error
+	if input.DocumentID != "" {
+		registration.Words, err = s.RegisterDocument(input.DocumentID, input.Namespace, input.Text, input.Labels)
+	} else {
+		registration.Words = s.RegisterWords(input.Text, input.Labels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return registration, nil
 }
 
-func (s *wordService) RegisterWords(text string) {
+// the lines of the streams are counted as plain words, they are forwarded in batches
+func (s *wordService) forwardLine(line string, labels map[string]string) {
+	if s.forwarding && s.lines != nil {
+		s.lines.Add(line, labels)
+	}
+}
 
-	words := splitPhrase(text)
+// forwards the lines of a stream which ended
+func (s *wordService) flushLines() {
+	if s.forwarding && s.lines != nil {
+		s.lines.Flush()
+	}
+}
+
+// the workers count every text counted by the master, they don't check the duplicates again
+func (s *wordService) forwardText(input *TextInput, idempotencyKey string) {
+	forwarded := *input
+	forwarded.Force = true
+
+	bodyBytes, err := json.Marshal(&forwarded)
+	if err != nil {
+		s.logger.Error("Cannot encode text for workers: ", err)
+		return
+	}
+	s.forward(http.MethodPost, apiPrefix+"/words/register", bodyBytes, idempotencyKey)
+}
+
+// the job id is used as idempotency key, so the workers apply the job once
+func (s *wordService) processJob(job *Job) (*Registration, error) {
+	registration, err := s.registerText(job.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	if registration.Counted {
+		s.forwardText(job.Input, job.ID)
+	}
+	return registration, nil
+}
+
+func (s *wordService) RetractDocument(id string) (map[string]int, error) {
+	return s.db.RetractDocument(id)
+}
+
+// a key names a word when it's tokenized into exactly one word, the ingest synonyms applied
+func (s *wordService) singleWord(key string) (string, bool) {
+	words := s.tokenize(key)
+	if len(words) != 1 {
+		return "", false
+	}
+	return words[0], true
+}
 
+// returns the count the word had, the workers delete it too
+func (s *wordService) DeleteWord(word, idempotencyKey string) int {
+	count := s.db.DeleteWord(word)
+	if count > 0 {
+		s.forward(http.MethodDelete, apiPrefix+"/words/"+url.PathEscape(word), nil, idempotencyKey)
+	}
+	return count
+}
+
+func (s *wordService) insertWords(words []string) {
 	wp := util.NewWorkerPool(15)
 	wp.Start()
 
@@ -129,7 +393,7 @@ func (s *wordService) RegisterWords(text string) {
 
 	for word := range wordChannel {
 		// avoid capturing loop variable
-		word := strings.ToLower(word)
+		word := word
 		wp.Submit(func() {
 			s.db.Insert(word)
 		})
@@ -138,10 +402,58 @@ func (s *wordService) RegisterWords(text string) {
 	wp.Stop()
 }
 
-func splitPhrase(text string) []string {
-	splitter := func(c rune) bool {
-		return unicode.IsSpace(c) || strings.ContainsRune(",.-_", c)
+// the pairs of a document are subtracted when it's retracted
+func (s *wordService) countCooccurrences(id, text string) {
+	if counter := s.db.Cooccurrences(); counter != nil {
+		sentences := splitSentences(text)
+		for i, sentence := range sentences {
+			sentences[i] = s.db.Synonyms().Canonicalize(sentence)
+		}
+		if id != "" {
+			counter.AddDocument(id, sentences)
+		} else {
+			counter.Add(sentences)
+		}
+	}
+}
+
+func histogram(words []string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range words {
+		counts[word]++
 	}
+	return counts
+}
+
+// splits text into lowercase words, the same way RegisterWords stores them
+func Tokenize(text string) []string {
+	words := splitPhrase(text)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// splits text into sentences of lowercase words
+func splitSentences(text string) [][]string {
+	sentences := strings.FieldsFunc(text, func(c rune) bool {
+		return strings.ContainsRune(".!?;", c)
+	})
+
+	result := make([][]string, 0, len(sentences))
+	for _, sentence := range sentences {
+		if words := Tokenize(sentence); len(words) > 0 {
+			result = append(result, words)
+		}
+	}
+	return result
+}
+
+func splitPhrase(text string) []string {
+	return strings.FieldsFunc(text, isSeparator)
+}
 
-	return strings.FieldsFunc(text, splitter)
+// runes between the words
+func isSeparator(c rune) bool {
+	return unicode.IsSpace(c) || strings.ContainsRune(",.-_", c)
 }
@@ -10,7 +10,7 @@ func Retry(ctx context.Context, f func() error, retryAttempts int, interval time
 	var err error
 
 	for i := 0; i < retryAttempts; i++ {
-		err := f()
+		err = f()
 		if err == nil {
 			return nil
 		}